@@ -4,25 +4,8 @@ import (
 	"github.com/grepplabs/tribe/config"
 	"github.com/grepplabs/tribe/database/client"
 	"github.com/grepplabs/tribe/pkg/log"
-	"github.com/pkg/errors"
-	"strings"
 )
 
 func NewDatastoreClient(logger log.Logger, datastoreConfig *config.DatastoreConfig) (client.Client, error) {
-	switch strings.ToLower(datastoreConfig.Provider) {
-	case "db":
-		dbClient, err := client.NewSQLClient(logger, &datastoreConfig.DBConfig)
-		if err != nil {
-			return nil, errors.Wrap(err, "create sql client failed")
-		}
-		return dbClient, nil
-	case "minio":
-		minioClient, err := client.NewMinioClient(logger, &datastoreConfig.MinioConfig)
-		if err != nil {
-			return nil, errors.Wrap(err, "create minio client failed")
-		}
-		return minioClient, nil
-	default:
-		return nil, errors.Errorf("Unsupported datastore provider: %v", datastoreConfig.Provider)
-	}
+	return client.NewClient(logger, datastoreConfig)
 }
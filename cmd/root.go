@@ -2,6 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/kms/vaultkms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/grepplabs/tribe/pkg/secret"
 	"github.com/spf13/cobra"
 	"os"
 
@@ -15,6 +19,14 @@ var cfgFile string
 var rootCmd = &cobra.Command{
 	Use:   "tribe",
 	Short: "User management and identity server",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// the vault:// secret references use the vault flags of the command, if it has them
+		if vaultConfig := config.VaultConfigFromFlags(cmd.Flags()); vaultConfig != nil {
+			secret.SetVaultReader(func() (secret.VaultReader, error) {
+				return vaultkms.NewSecretReader(log.NewDefaultLogger().WithName("secret-vault"), vaultConfig)
+			})
+		}
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
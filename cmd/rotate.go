@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var rotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Key rotation",
+}
+
+func init() {
+	rootCmd.AddCommand(rotateCmd)
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/database/client"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rotateCmd.AddCommand(newRotateRunCmd())
+}
+
+type rotateRunConfig struct {
+	once     bool
+	interval time.Duration
+}
+
+func (c *rotateRunConfig) Validate() error {
+	if !c.once && c.interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	return nil
+}
+
+func newRotateRunCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	kmsConfig := kms.NewConfig(datastoreConfig)
+	cmdConfig := new(rotateRunConfig)
+
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Rotate the OIDC JWKS with periodic rotation mode when the rotation period is over",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return cmdConfig.Validate()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.NewLogger(logConfig.Configuration).WithName("rotate-run")
+			dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+			if err != nil {
+				log.Errorf("create datastore client failed: %v", err)
+				os.Exit(1)
+			}
+			kmsProvider, err := kms.NewProvider(logger, kmsConfig)
+			if err != nil {
+				log.Errorf("create kms provider failed: %v", err)
+				os.Exit(1)
+			}
+			worker, err := newRotateWorker(logger, dsClient, kmsProvider)
+			if err != nil {
+				log.Errorf("datastore provider %s is not supported: %v", datastoreConfig.Provider, err)
+				os.Exit(1)
+			}
+			if cmdConfig.once {
+				if err = worker.rotateDue(context.Background()); err != nil {
+					log.Errorf("rotate run failed: %v", err)
+					os.Exit(1)
+				}
+				return
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			worker.run(ctx, cmdConfig.interval)
+		},
+	}
+
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(kmsConfig.FlagSet())
+
+	cmd.Flags().BoolVar(&cmdConfig.once, "once", false, "Rotate the overdue sets once and exit, e.g. when run by cron")
+	cmd.Flags().DurationVar(&cmdConfig.interval, "interval", time.Minute, "How often the worker checks for overdue sets")
+
+	return cmd
+}
+
+type rotateWorker struct {
+	logger      log.Logger
+	dsClient    client.Client
+	locker      client.Locker
+	kmsProvider kms.Provider
+	now         func() time.Time
+}
+
+// newRotateWorker requires a datastore with locks, so a set is not rotated by several workers at once
+func newRotateWorker(logger log.Logger, dsClient client.Client, kmsProvider kms.Provider) (*rotateWorker, error) {
+	locker, ok := dsClient.(client.Locker)
+	if !ok {
+		return nil, errors.New("rotate run requires a datastore with locks")
+	}
+	return &rotateWorker{
+		logger:      logger,
+		dsClient:    dsClient,
+		locker:      locker,
+		kmsProvider: kmsProvider,
+		now:         time.Now,
+	}, nil
+}
+
+// run rotates the overdue sets every interval until the context is done
+func (w *rotateWorker) run(ctx context.Context, interval time.Duration) {
+	w.logger.Infof("rotation worker started, interval %v", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := w.rotateDue(ctx); err != nil {
+			w.logger.Errorf("rotation failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			w.logger.Infof("rotation worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// rotateDue rotates all overdue sets, the failure of a set does not stop the rotation of the others
+func (w *rotateWorker) rotateDue(ctx context.Context) error {
+	list, err := w.dsClient.API().ListOidcJWKS(ctx, nil, nil)
+	if err != nil {
+		return errors.Wrap(err, "list OIDC JWKS failed")
+	}
+	if list == nil {
+		return nil
+	}
+	var failed int
+	now := w.now()
+	for _, record := range list.List {
+		if !record.RotationDue(now) {
+			continue
+		}
+		if err := w.rotateLocked(ctx, record.ID); err != nil {
+			w.logger.Errorf("rotate OIDC JWKS %s failed: %v", record.ID, err)
+			failed++
+		}
+	}
+	if failed != 0 {
+		return errors.Errorf("rotation of %d OIDC JWKS failed", failed)
+	}
+	return nil
+}
+
+// rotateLocked rotates the set while holding the lock, so the set is rotated by one worker only
+func (w *rotateWorker) rotateLocked(ctx context.Context, oidcJwksID string) error {
+	unlock, locked, err := w.locker.TryLock(ctx, oidcJwksLockName(oidcJwksID))
+	if err != nil {
+		return err
+	}
+	if !locked {
+		w.logger.Infof("OIDC JWKS %s is rotated by another worker", oidcJwksID)
+		return nil
+	}
+	defer func() {
+		if err := unlock(context.Background()); err != nil {
+			w.logger.Warnf("unlock OIDC JWKS %s failed: %v", oidcJwksID, err)
+		}
+	}()
+	// the set could have been rotated while waiting for the lock
+	record, err := w.dsClient.API().GetOidcJWKS(ctx, oidcJwksID)
+	if err != nil {
+		return err
+	}
+	if record == nil || !record.RotationDue(w.now()) {
+		return nil
+	}
+	cmdConfig, err := w.rotateConfig(record)
+	if err != nil {
+		return err
+	}
+	record, err = rotateOidcJwks(NewJwksCreateCmd(w.logger, w.dsClient, w.kmsProvider), w.dsClient, cmdConfig, record)
+	if err != nil {
+		return err
+	}
+	w.logger.Infof("rotated OIDC JWKS %s, version %d, current %s, next %s", record.ID, record.Version, record.CurrentJwksID, record.NextJwksID)
+	return nil
+}
+
+// oidcJwksLockName is the name of the lock held while the set is rotated or rolled back
+func oidcJwksLockName(oidcJwksID string) string {
+	return "oidc-jwks-rotate-" + oidcJwksID
+}
+
+// rotateConfig creates the next key with the alg and key storage of the key becoming current
+func (w *rotateWorker) rotateConfig(record *model.OidcJWKS) (*oidcJwksRotateConfig, error) {
+	jwks, err := getJwksByID(w.dsClient, record.NextJwksID)
+	if err != nil {
+		return nil, err
+	}
+	keyStorage := jwks.KeyStorage
+	if keyStorage == "" {
+		keyStorage = model.JWKSKeyStorageKMS
+	}
+	return &oidcJwksRotateConfig{
+		oidcJwksID: record.ID,
+		alg:        jwks.Alg,
+		keyStorage: keyStorage,
+	}, nil
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/tink/go/aead"
+	"github.com/google/tink/go/keyset"
+	"github.com/google/tink/go/tink"
+	"github.com/grepplabs/tribe/database/client"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/database/service"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type testAPI struct {
+	service.API
+	mu          sync.Mutex
+	jwks        map[string]*model.JWKS
+	oidcJwks    map[string]*model.OidcJWKS
+	keyDestroys map[string]*model.KMSKeyDestroy
+}
+
+func newTestAPI() *testAPI {
+	return &testAPI{jwks: map[string]*model.JWKS{}, oidcJwks: map[string]*model.OidcJWKS{}, keyDestroys: map[string]*model.KMSKeyDestroy{}}
+}
+
+func (a *testAPI) CreateJWKS(_ context.Context, record *model.JWKS) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.jwks[record.ID] = record
+	return nil
+}
+
+func (a *testAPI) GetJWKS(_ context.Context, id string) (*model.JWKS, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.jwks[id], nil
+}
+
+func (a *testAPI) GetJWKSByKidUse(_ context.Context, kid string, use string) (*model.JWKS, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, record := range a.jwks {
+		if record.Kid == kid && record.Use == use {
+			return record, nil
+		}
+	}
+	return nil, nil
+}
+
+func (a *testAPI) UpdateJWKS(_ context.Context, record *model.JWKS) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	copied := *record
+	a.jwks[record.ID] = &copied
+	return nil
+}
+
+func (a *testAPI) DeleteJWKS(_ context.Context, id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.jwks, id)
+	return nil
+}
+
+func (a *testAPI) ListJWKS(_ context.Context, _ *int64, _ *int64) (*model.JWKSList, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := &model.JWKSList{}
+	for _, record := range a.jwks {
+		list.List = append(list.List, *record)
+	}
+	sort.Slice(list.List, func(i, j int) bool { return list.List[i].ID < list.List[j].ID })
+	return list, nil
+}
+
+func (a *testAPI) GetOidcJWKS(_ context.Context, id string) (*model.OidcJWKS, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if record, ok := a.oidcJwks[id]; ok {
+		copied := *record
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (a *testAPI) ListOidcJWKS(_ context.Context, _ *int64, _ *int64) (*model.OidcJWKSList, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := &model.OidcJWKSList{}
+	for _, record := range a.oidcJwks {
+		list.List = append(list.List, *record)
+	}
+	sort.Slice(list.List, func(i, j int) bool { return list.List[i].ID < list.List[j].ID })
+	return list, nil
+}
+
+func (a *testAPI) UpdateOidcJWKS(_ context.Context, record *model.OidcJWKS) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stored, ok := a.oidcJwks[record.ID]
+	if !ok || stored.Version != record.Version-1 {
+		return service.ErrConflict{Reason: record.ID}
+	}
+	copied := *record
+	a.oidcJwks[record.ID] = &copied
+	return nil
+}
+
+func (a *testAPI) CreateKMSKeyDestroy(_ context.Context, record *model.KMSKeyDestroy) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.keyDestroys[record.ID] = record
+	return nil
+}
+
+func (a *testAPI) DeleteKMSKeyDestroy(_ context.Context, id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.keyDestroys, id)
+	return nil
+}
+
+func (a *testAPI) ListKMSKeyDestroys(_ context.Context, _ *int64, _ *int64) (*model.KMSKeyDestroyList, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := &model.KMSKeyDestroyList{}
+	for _, record := range a.keyDestroys {
+		list.List = append(list.List, *record)
+	}
+	sort.Slice(list.List, func(i, j int) bool { return list.List[i].ID < list.List[j].ID })
+	return list, nil
+}
+
+type testClient struct {
+	api *testAPI
+}
+
+func (c *testClient) API() service.API { return c.api }
+
+// testLockingClient holds the locks in memory, the names in held are locked by another worker
+type testLockingClient struct {
+	testClient
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+func (c *testLockingClient) TryLock(_ context.Context, name string) (client.Unlock, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.held[name] {
+		return nil, false, nil
+	}
+	c.held[name] = true
+	return func(context.Context) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.held, name)
+		return nil
+	}, true, nil
+}
+
+type testProvider struct {
+	kms.Provider
+	aead tink.AEAD
+	// deleteErr is returned by DeleteKey
+	deleteErr   error
+	deletedKeys []string
+}
+
+func (p *testProvider) NewAEAD(keyID string) (tink.AEAD, string, error) {
+	return p.aead, "test://" + keyID, nil
+}
+
+func (p *testProvider) DeleteKey(refKeyURI string) error {
+	if p.deleteErr != nil {
+		return p.deleteErr
+	}
+	p.deletedKeys = append(p.deletedKeys, refKeyURI)
+	return nil
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	kh, err := keyset.NewHandle(aead.AES256GCMKeyTemplate())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	primitive, err := aead.New(kh)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return &testProvider{aead: primitive}
+}
+
+func (a *testAPI) addOidcJwks(id string, mode, period int, lastRotated time.Time) *model.OidcJWKS {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, jwksID := range []string{id + "-current", id + "-next"} {
+		a.jwks[jwksID] = &model.JWKS{ID: jwksID, Kid: jwksID, Alg: "ES256", Use: "sig", KeyStorage: model.JWKSKeyStorageKMS}
+	}
+	record := &model.OidcJWKS{
+		ID:             id,
+		CurrentJwksID:  id + "-current",
+		NextJwksID:     id + "-next",
+		RotationMode:   mode,
+		RotationPeriod: period,
+		LastRotated:    lastRotated,
+		Version:        1,
+	}
+	a.oidcJwks[id] = record
+	return record
+}
+
+func TestRotateWorkerRotateDue(t *testing.T) {
+	now := time.Now()
+	api := newTestAPI()
+	api.addOidcJwks("overdue", model.OidcJWKSRotationModePeriodic, 60, now.Add(-time.Hour))
+	api.addOidcJwks("not-due", model.OidcJWKSRotationModePeriodic, 7200, now.Add(-time.Hour))
+	api.addOidcJwks("manual", model.OidcJWKSRotationModeManual, 60, now.Add(-time.Hour))
+	api.addOidcJwks("locked", model.OidcJWKSRotationModePeriodic, 60, now.Add(-time.Hour))
+
+	dsClient := &testLockingClient{testClient: testClient{api: api}, held: map[string]bool{oidcJwksLockName("locked"): true}}
+	worker, err := newRotateWorker(log.NewDefaultLogger(), dsClient, newTestProvider(t))
+	if !assert.NoError(t, err) {
+		return
+	}
+	worker.now = func() time.Time { return now }
+
+	if !assert.NoError(t, worker.rotateDue(context.Background())) {
+		return
+	}
+
+	rotated, _ := api.GetOidcJWKS(context.Background(), "overdue")
+	assert.Equal(t, 2, rotated.Version)
+	assert.Equal(t, "overdue-next", rotated.CurrentJwksID)
+	assert.Equal(t, "overdue-current", *rotated.PreviousJwksID)
+	assert.False(t, rotated.LastRotated.Before(now))
+	next, _ := api.GetJWKS(context.Background(), rotated.NextJwksID)
+	if assert.NotNil(t, next) {
+		assert.Equal(t, "ES256", next.Alg)
+		assert.Equal(t, "test://"+next.ID, next.KMSKeyURI)
+	}
+	assert.Empty(t, dsClient.held[oidcJwksLockName("overdue")])
+
+	for _, id := range []string{"not-due", "manual", "locked"} {
+		record, _ := api.GetOidcJWKS(context.Background(), id)
+		assert.Equal(t, 1, record.Version, id)
+		assert.Equal(t, id+"-current", record.CurrentJwksID, id)
+	}
+
+	// the rotated set is not due again
+	if !assert.NoError(t, worker.rotateDue(context.Background())) {
+		return
+	}
+	rotated, _ = api.GetOidcJWKS(context.Background(), "overdue")
+	assert.Equal(t, 2, rotated.Version)
+}
+
+func TestRotateWorkerRequiresLocker(t *testing.T) {
+	_, err := newRotateWorker(log.NewDefaultLogger(), &testClient{api: newTestAPI()}, newTestProvider(t))
+	assert.Error(t, err)
+}
@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var jweCmd = &cobra.Command{
+	Use:   "jwe",
+	Short: "JWE tools",
+}
+
+func init() {
+	toolsCmd.AddCommand(jweCmd)
+}
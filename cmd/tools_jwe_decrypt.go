@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/jwe"
+	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+)
+
+func init() {
+	jweCmd.AddCommand(newJweDecryptCmd())
+}
+
+type jweDecryptConfig struct {
+	token   string
+	jwksIDs []string
+	keyFile string
+}
+
+func (c *jweDecryptConfig) Validate() error {
+	if (len(c.jwksIDs) == 0) == (c.keyFile == "") {
+		return errors.New("either jwks-id or key-file is required")
+	}
+	return nil
+}
+
+type jweDecryptResult struct {
+	Header  map[string]interface{} `json:"header"`
+	Kid     string                 `json:"kid,omitempty"`
+	Payload string                 `json:"payload"`
+	// JWT is the decoded nested JWT, its signature is not verified
+	JWT *jwtDecodeResult `json:"jwt,omitempty"`
+}
+
+func newJweDecryptCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	kmsConfig := kms.NewConfig(datastoreConfig)
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(jweDecryptConfig)
+
+	cmd := &cobra.Command{
+		Use:   "decrypt [token]",
+		Short: "Decrypt a JWE with stored use=enc JWKS or a private key file",
+		Args:  cobra.MaximumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmdConfig.Validate(); err != nil {
+				return err
+			}
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("jwe-decrypt")
+			token, err := readToken(cmdConfig.token, args)
+			if err != nil {
+				log.Errorf("jwe decrypt command failed: %v", err)
+				os.Exit(1)
+			}
+			decrypter, err := newJweDecrypter(logger, datastoreConfig, kmsConfig, cmdConfig)
+			if err != nil {
+				log.Errorf("load decryption keys failed: %v", err)
+				os.Exit(1)
+			}
+			result, err := runJweDecrypt(decrypter, token)
+			if err != nil {
+				log.Errorf("jwe decrypt command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(kmsConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().StringVar(&cmdConfig.token, "token", "", "Token to decrypt, '-' reads from stdin. The token can be also provided as argument.")
+	cmd.Flags().StringSliceVar(&cmdConfig.jwksIDs, "jwks-id", nil, "Identifier of the use=enc JWKS, can be repeated")
+	cmd.Flags().StringVar(&cmdConfig.keyFile, "key-file", "", "File with the private JWK, JWKS or PEM key (PKCS#1, PKCS#8 or SEC1)")
+
+	return cmd
+}
+
+func newJweDecrypter(logger log.Logger, datastoreConfig *config.DatastoreConfig, kmsConfig *kms.Config, cmdConfig *jweDecryptConfig) (*jwe.Decrypter, error) {
+	if cmdConfig.keyFile != "" {
+		data, err := ioutil.ReadFile(cmdConfig.keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read key file failed")
+		}
+		keys, err := jwk.ParseKeys(data)
+		if err != nil {
+			return nil, err
+		}
+		for i := range keys.Keys {
+			keys.Keys[i].KeyID = jwk.BaseKeyID(keys.Keys[i].KeyID)
+		}
+		return jwe.NewDecrypter(keys), nil
+	}
+	dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+	if err != nil {
+		return nil, err
+	}
+	kmsProvider, err := kms.NewProvider(logger, kmsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return jwe.NewJWKSDecrypter(context.Background(), dsClient, kmsProvider, cmdConfig.jwksIDs...)
+}
+
+func runJweDecrypt(decrypter *jwe.Decrypter, token string) (*jweDecryptResult, error) {
+	result, err := decrypter.Decrypt(token)
+	if err != nil {
+		return nil, err
+	}
+	header := map[string]interface{}{
+		"alg": result.Header.Algorithm,
+	}
+	if result.Header.KeyID != "" {
+		header["kid"] = result.Header.KeyID
+	}
+	for k, v := range result.Header.ExtraHeaders {
+		header[string(k)] = v
+	}
+	decrypted := &jweDecryptResult{
+		Header:  header,
+		Kid:     result.Key.KeyID,
+		Payload: string(result.Payload),
+	}
+	if result.IsNestedJWT() {
+		decoded, err := decodeJwt(decrypted.Payload)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode nested JWT failed")
+		}
+		decoded.Diagnostics = jwtDiagnostics(decoded, time.Now(), josejwt.DefaultLeeway)
+		decrypted.JWT = decoded
+	}
+	return decrypted, nil
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/jwe"
+	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func init() {
+	jweCmd.AddCommand(newJweEncryptCmd())
+}
+
+type jweEncryptConfig struct {
+	jwksID        string
+	recipientFile string
+
+	alg         string
+	enc         string
+	payload     string
+	payloadFile string
+	nested      bool
+}
+
+func (c *jweEncryptConfig) Validate() error {
+	if (c.jwksID == "") == (c.recipientFile == "") {
+		return errors.New("either jwks-id or recipient-file is required")
+	}
+	if (c.payload == "") == (c.payloadFile == "") {
+		return errors.New("either payload or payload-file is required")
+	}
+	return nil
+}
+
+type jweEncryptResult struct {
+	Token string `json:"token"`
+	Kid   string `json:"kid,omitempty"`
+}
+
+func newJweEncryptCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	kmsConfig := kms.NewConfig(datastoreConfig)
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(jweEncryptConfig)
+
+	cmd := &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt a payload or a signed JWT to a stored use=enc JWKS or a client key",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmdConfig.Validate(); err != nil {
+				return err
+			}
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("jwe-encrypt")
+			recipient, err := jweRecipient(logger, datastoreConfig, kmsConfig, cmdConfig)
+			if err != nil {
+				log.Errorf("get recipient key failed: %v", err)
+				os.Exit(1)
+			}
+			result, err := runJweEncrypt(recipient, cmdConfig)
+			if err != nil {
+				log.Errorf("jwe encrypt command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(kmsConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().StringVar(&cmdConfig.jwksID, "jwks-id", "", "Identifier of the use=enc JWKS, the payload is encrypted to its public key")
+	cmd.Flags().StringVar(&cmdConfig.recipientFile, "recipient-file", "", "File with the client JWK, JWKS, PEM certificate or PEM public key")
+	cmd.Flags().StringVar(&cmdConfig.alg, "alg", "", "Key management algorithm, the alg of the key or RSA-OAEP-256 / ECDH-ES+A256KW if not provided")
+	cmd.Flags().StringVar(&cmdConfig.enc, "enc", string(jwe.DefaultContentEncryption), "Content encryption algorithm. One of: [A128CBC-HS256, A192CBC-HS384, A256CBC-HS512, A128GCM, A192GCM, A256GCM]")
+	cmd.Flags().StringVar(&cmdConfig.payload, "payload", "", "Payload to encrypt, '-' reads from stdin")
+	cmd.Flags().StringVar(&cmdConfig.payloadFile, "payload-file", "", "File with the payload to encrypt")
+	cmd.Flags().BoolVar(&cmdConfig.nested, "nested", false, "The payload is a signed JWT, the result is a nested JWT with cty JWT (sign-then-encrypt)")
+
+	return cmd
+}
+
+func jweRecipient(logger log.Logger, datastoreConfig *config.DatastoreConfig, kmsConfig *kms.Config, cmdConfig *jweEncryptConfig) (*jose.JSONWebKey, error) {
+	if cmdConfig.recipientFile != "" {
+		data, err := ioutil.ReadFile(cmdConfig.recipientFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read recipient file failed")
+		}
+		return jwe.RecipientKey(data)
+	}
+	dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+	if err != nil {
+		return nil, err
+	}
+	kmsProvider, err := kms.NewProvider(logger, kmsConfig)
+	if err != nil {
+		return nil, err
+	}
+	record, err := getJwksByID(dsClient, cmdConfig.jwksID)
+	if err != nil {
+		return nil, err
+	}
+	if record.Use != "enc" {
+		return nil, errors.Errorf("JWKS ID %s is not an encryption key: %s", record.ID, record.Use)
+	}
+	keys, err := jwk.DecryptJWKS(kmsProvider, record)
+	if err != nil {
+		return nil, err
+	}
+	return jwk.PublicKey(record.ID, keys)
+}
+
+func runJweEncrypt(recipient *jose.JSONWebKey, cmdConfig *jweEncryptConfig) (*jweEncryptResult, error) {
+	payload, err := readPayload(cmdConfig.payload, cmdConfig.payloadFile)
+	if err != nil {
+		return nil, err
+	}
+	options := []jwe.EncryptOption{
+		jwe.WithContentEncryption(jose.ContentEncryption(cmdConfig.enc)),
+		jwe.WithKeyAlgorithm(jose.KeyAlgorithm(cmdConfig.alg)),
+	}
+	var token string
+	if cmdConfig.nested {
+		token, err = jwe.EncryptJWT(recipient, strings.TrimSpace(string(payload)), options...)
+	} else {
+		token, err = jwe.Encrypt(recipient, payload, options...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &jweEncryptResult{
+		Token: token,
+		Kid:   recipient.KeyID,
+	}, nil
+}
+
+func readPayload(payload string, payloadFile string) ([]byte, error) {
+	if payloadFile != "" {
+		data, err := ioutil.ReadFile(payloadFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read payload file failed")
+		}
+		return data, nil
+	}
+	if payload == "-" {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, errors.Wrap(err, "read payload from stdin failed")
+		}
+		return data, nil
+	}
+	return []byte(payload), nil
+}
@@ -2,18 +2,22 @@ package cmd
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/grepplabs/tribe/config"
 	"github.com/grepplabs/tribe/database/client"
 	"github.com/grepplabs/tribe/database/model"
 	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/jwk/jwkscrypt"
+	"github.com/grepplabs/tribe/pkg/jwk/keygen"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/kms/vaultkms"
 	"github.com/grepplabs/tribe/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"gopkg.in/square/go-jose.v2"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -24,20 +28,86 @@ func init() {
 type jwksCreateConfig struct {
 	jwksID string
 
-	alg string
-	use string
+	alg         string
+	use         string
+	keyStorage  string
+	certificate *config.CertificateConfig
+
+	kidStrategy string
+	keySize     int
+	curve       string
 }
 
 func (c *jwksCreateConfig) Validate() error {
+	if err := jwk.ValidateAlgUse(c.alg, c.use); err != nil {
+		return err
+	}
+	if err := jwk.ValidateKeyIDStrategy(c.kidStrategy, c.jwksID); err != nil {
+		return err
+	}
+	if c.certificate != nil {
+		// the CA flags are rejected without cert
+		if err := c.certificate.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.certificate != nil && c.certificate.Enabled {
+		if strings.HasPrefix(c.alg, "HS") {
+			return errors.Errorf("certificate requires an asymmetric alg, but '%s'", c.alg)
+		}
+		if c.keyStorage == model.JWKSKeyStorageVaultTransit && c.certificate.CAFile == "" {
+			return errors.Errorf("key storage %s requires a CA to issue the certificate", c.keyStorage)
+		}
+	}
+	switch c.keyStorage {
+	case model.JWKSKeyStorageKMS:
+	case model.JWKSKeyStorageVaultTransit:
+		if c.use != "sig" {
+			return errors.Errorf("key storage %s supports only sig keys", c.keyStorage)
+		}
+		if c.kidStrategy == jwk.KeyIDStrategyThumbprint {
+			return errors.Errorf("key storage %s names the key before it is generated, the kid strategy thumbprint is not supported", c.keyStorage)
+		}
+		if c.keySize != 0 || c.curve != "" {
+			return errors.Errorf("key storage %s derives the key type from the alg, key size and curve are not supported", c.keyStorage)
+		}
+		if _, err := vaultkms.SigningKeyType(jose.SignatureAlgorithm(c.alg)); err != nil {
+			return err
+		}
+	default:
+		return errors.Errorf("unsupported key storage %s", c.keyStorage)
+	}
 	return nil
 }
 
+// certificateConfig returns the certificate configuration of the generator or nil if no certificate is requested
+func (c *jwksCreateConfig) certificateConfig() (*jwk.CertificateConfig, error) {
+	if c.certificate == nil || !c.certificate.Enabled {
+		return nil, nil
+	}
+	subject, err := jwk.ParseSubject(c.certificate.Subject)
+	if err != nil {
+		return nil, err
+	}
+	result := &jwk.CertificateConfig{
+		Subject:  subject,
+		Validity: c.certificate.Validity,
+	}
+	if c.certificate.CAFile != "" {
+		result.CA, err = jwk.LoadCertificateAuthority(c.certificate.CAFile, c.certificate.CAKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
 func newJwksCreateCmd() *cobra.Command {
 	logConfig := config.NewLogConfig()
 	datastoreConfig := config.NewDatastoreConfig()
-	kmsConfig := config.NewKMSConfig(datastoreConfig)
+	kmsConfig := kms.NewConfig(datastoreConfig)
 	outputConfig := config.NewOutputConfig()
-	cmdConfig := new(jwksCreateConfig)
+	cmdConfig := &jwksCreateConfig{certificate: config.NewCertificateConfig()}
 
 	cmd := &cobra.Command{
 		Use:   "create",
@@ -60,7 +130,7 @@ func newJwksCreateCmd() *cobra.Command {
 				log.Errorf("create datastore client failed: %v", err)
 				os.Exit(1)
 			}
-			kmsProvider, err := NewKMSProvider(logger, kmsConfig)
+			kmsProvider, err := kms.NewProvider(logger, kmsConfig)
 			if err != nil {
 				log.Errorf("create kms provider failed: %v", err)
 				os.Exit(1)
@@ -70,7 +140,7 @@ func newJwksCreateCmd() *cobra.Command {
 				log.Errorf("jwks create command failed: %v", err)
 				os.Exit(1)
 			}
-			err = producer.Produce(os.Stdout, result)
+			err = producer.Produce(os.Stdout, jwk.JSONKeySet{JSONWebKeySet: result})
 			if err != nil {
 				log.Errorf("failed to write result: %v", err)
 				os.Exit(1)
@@ -82,10 +152,15 @@ func newJwksCreateCmd() *cobra.Command {
 	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
 	cmd.Flags().AddFlagSet(kmsConfig.FlagSet())
 	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+	cmd.Flags().AddFlagSet(cmdConfig.certificate.FlagSet())
 
 	cmd.Flags().StringVar(&cmdConfig.jwksID, "jwks-id", "", "Identifier of the jwks used also a kid")
-	cmd.Flags().StringVar(&cmdConfig.alg, "alg", "RS256", "The specific rfc7518 JWA algorithm to be used to generated the key. One of: [HS256, HS384, HS512, RS256, RS384, RS512, ES256, ES384, ES512, PS256, PS384, PS512]")
+	cmd.Flags().StringVar(&cmdConfig.kidStrategy, "kid-strategy", jwk.KeyIDStrategyUUID, "How the kid is computed. One of: [uuid, thumbprint, custom]. uuid uses the jwks-id or a random UUID, thumbprint uses the RFC 7638 SHA-256 thumbprint of the public key, custom requires the jwks-id")
+	cmd.Flags().IntVar(&cmdConfig.keySize, "key-size", 0, fmt.Sprintf("RSA key size in bits, the default is %d", keygen.RSADefaultKeySize))
+	cmd.Flags().StringVar(&cmdConfig.curve, "curve", "", "Elliptic curve of the EC keys. One of: [P-256, P-384, P-521, secp256k1]. The secp256k1 curve is used by ES256K only. The ES algorithms require the matching curve, the default of ECDH-ES is P-256")
+	cmd.Flags().StringVar(&cmdConfig.alg, "alg", "RS256", "The specific rfc7518 JWA algorithm to be used to generated the key. For use=sig one of: [HS256, HS384, HS512, RS256, RS384, RS512, ES256, ES384, ES512, PS256, PS384, PS512, EdDSA, ES256K], for use=enc one of: [RSA-OAEP, RSA-OAEP-256, ECDH-ES, ECDH-ES+A128KW, ECDH-ES+A192KW, ECDH-ES+A256KW]")
 	cmd.Flags().StringVar(&cmdConfig.use, "use", "sig", "How the key is meant to be used. One of: [sig, enc]")
+	cmd.Flags().StringVar(&cmdConfig.keyStorage, "key-storage", model.JWKSKeyStorageKMS, "Where the private key is held. One of: [kms, vault-transit]. With vault-transit the key pair is generated in vault and only the public key is stored.")
 
 	return cmd
 }
@@ -93,10 +168,10 @@ func newJwksCreateCmd() *cobra.Command {
 type jwksCreateCmd struct {
 	logger      log.Logger
 	dsClient    client.Client
-	kmsProvider KMSProvider
+	kmsProvider kms.Provider
 }
 
-func NewJwksCreateCmd(logger log.Logger, dsClient client.Client, kmsProvider KMSProvider) *jwksCreateCmd {
+func NewJwksCreateCmd(logger log.Logger, dsClient client.Client, kmsProvider kms.Provider) *jwksCreateCmd {
 	return &jwksCreateCmd{
 		logger:      logger,
 		dsClient:    dsClient,
@@ -106,35 +181,112 @@ func NewJwksCreateCmd(logger log.Logger, dsClient client.Client, kmsProvider KMS
 
 func (c *jwksCreateCmd) Run(cmdConfig *jwksCreateConfig) (*jose.JSONWebKeySet, error) {
 	id := cmdConfig.jwksID
-	if id == "" {
+	if id == "" && cmdConfig.kidStrategy != jwk.KeyIDStrategyThumbprint {
 		id = uuid.NewString()
 	}
-	kid := id
-	keys, err := jwk.NewJWKSGenerator().Generate(kid, cmdConfig.alg, cmdConfig.use)
+	if cmdConfig.keyStorage == model.JWKSKeyStorageVaultTransit {
+		return c.createVaultTransit(id, id, cmdConfig)
+	}
+	options := []jwk.GeneratorOption{
+		jwk.WithKeyIDStrategy(cmdConfig.kidStrategy),
+		jwk.WithKeySize(cmdConfig.keySize),
+		jwk.WithCurve(cmdConfig.curve),
+	}
+	certificateConfig, err := cmdConfig.certificateConfig()
 	if err != nil {
 		return nil, err
 	}
+	if certificateConfig != nil {
+		options = append(options, jwk.WithCertificate(certificateConfig))
+	}
+	keys, err := jwk.NewJWKSGenerator(options...).Generate(id, cmdConfig.alg, cmdConfig.use)
+	if err != nil {
+		return nil, err
+	}
+	// the thumbprint of the public key is the kid and the jwks id
+	kid := keys.Keys[0].KeyID
+	if id == "" {
+		id = kid
+	}
 	// persist generated keys
+	err = c.store(id, kid, cmdConfig.alg, cmdConfig.use, keys)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// store encrypts the keys with the KMS key of the new JWKS and stores the record
+func (c *jwksCreateCmd) store(id, kid, alg, use string, keys *jose.JSONWebKeySet) error {
 	aead, keyURI, err := c.kmsProvider.NewAEAD(id)
 	if err != nil {
-		return nil, errors.Wrap(err, "Get AEAD failed")
+		return errors.Wrap(err, "Get AEAD failed")
+	}
+	bytes, err := jwk.MarshalKeySet(keys)
+	if err != nil {
+		return err
+	}
+	jwks := &model.JWKS{
+		ID:         id,
+		CreatedAt:  time.Now(),
+		Kid:        kid,
+		Alg:        alg,
+		Use:        use,
+		KMSKeyURI:  keyURI,
+		KeyStorage: model.JWKSKeyStorageKMS,
+	}
+	err = jwkscrypt.Encrypt(aead, jwks, bytes)
+	if err != nil {
+		return err
+	}
+	return c.dsClient.API().CreateJWKS(context.Background(), jwks)
+}
+
+// createVaultTransit generates the key pair in vault and stores the public key only
+func (c *jwksCreateCmd) createVaultTransit(id, kid string, cmdConfig *jwksCreateConfig) (*jose.JSONWebKeySet, error) {
+	signingKeyProvider, ok := c.kmsProvider.(kms.SigningKeyProvider)
+	if !ok {
+		return nil, errors.Errorf("kms provider does not support key storage %s", cmdConfig.keyStorage)
 	}
-	bytes, err := json.Marshal(keys)
+	publicKey, keyURI, err := signingKeyProvider.NewSigningKey(id, jose.SignatureAlgorithm(cmdConfig.alg))
+	if err != nil {
+		return nil, errors.Wrap(err, "Create signing key failed")
+	}
+	keys := &jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{
+				Algorithm: cmdConfig.alg,
+				Use:       cmdConfig.use,
+				Key:       publicKey,
+				KeyID:     kid,
+			},
+		},
+	}
+	certificateConfig, err := cmdConfig.certificateConfig()
 	if err != nil {
 		return nil, err
 	}
-	encryptedKeys, err := aead.Encrypt(bytes, []byte{})
+	if certificateConfig != nil {
+		// the certificate is issued by the CA, the private key is held by vault
+		chain, err := jwk.NewCertificate(certificateConfig, kid, cmdConfig.use, publicKey, nil)
+		if err != nil {
+			return nil, err
+		}
+		jwk.AttachCertificates(&keys.Keys[0], chain)
+	}
+	bytes, err := jwk.MarshalKeySet(keys)
 	if err != nil {
-		return nil, errors.Wrap(err, "AEAD keys encryption failed")
+		return nil, err
 	}
 	jwks := &model.JWKS{
-		ID:            id,
-		CreatedAt:     time.Now(),
-		Kid:           kid,
-		Alg:           cmdConfig.alg,
-		Use:           cmdConfig.use,
-		KMSKeyURI:     keyURI,
-		EncryptedJwks: base64.StdEncoding.EncodeToString(encryptedKeys),
+		ID:         id,
+		CreatedAt:  time.Now(),
+		Kid:        kid,
+		Alg:        cmdConfig.alg,
+		Use:        cmdConfig.use,
+		KMSKeyURI:  keyURI,
+		KeyStorage: model.JWKSKeyStorageVaultTransit,
+		PublicJwks: string(bytes),
 	}
 	err = c.dsClient.API().CreateJWKS(context.Background(), jwks)
 	if err != nil {
@@ -2,11 +2,16 @@ package cmd
 
 import (
 	"context"
+	"os"
+	"time"
+
 	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/database/client"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/kms"
 	"github.com/grepplabs/tribe/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"os"
 )
 
 func init() {
@@ -18,6 +23,8 @@ type jwksDeleteConfig struct {
 
 	use string
 	kid string
+
+	deleteKMSKey bool
 }
 
 func (c *jwksDeleteConfig) Validate() error {
@@ -30,6 +37,7 @@ func (c *jwksDeleteConfig) Validate() error {
 func newJwksDeleteCmd() *cobra.Command {
 	logConfig := config.NewLogConfig()
 	datastoreConfig := config.NewDatastoreConfig()
+	kmsConfig := kms.NewConfig(datastoreConfig)
 	cmdConfig := new(jwksDeleteConfig)
 
 	cmd := &cobra.Command{
@@ -43,7 +51,20 @@ func newJwksDeleteCmd() *cobra.Command {
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			logger := log.NewLogger(logConfig.Configuration).WithName("jwks-delete")
-			err := runJwksDelete(logger, datastoreConfig, cmdConfig)
+			dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+			if err != nil {
+				log.Errorf("create datastore client failed: %v", err)
+				os.Exit(1)
+			}
+			var kmsProvider kms.Provider
+			if cmdConfig.deleteKMSKey {
+				kmsProvider, err = kms.NewProvider(logger, kmsConfig)
+				if err != nil {
+					log.Errorf("create kms provider failed: %v", err)
+					os.Exit(1)
+				}
+			}
+			err = NewJwksDeleteCmd(logger, dsClient, kmsProvider).Run(cmdConfig)
 			if err != nil {
 				log.Errorf("jwks delete command failed: %v", err)
 				os.Exit(1)
@@ -53,22 +74,100 @@ func newJwksDeleteCmd() *cobra.Command {
 
 	cmd.Flags().AddFlagSet(logConfig.FlagSet())
 	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(kmsConfig.FlagSet())
 
 	cmd.Flags().StringVar(&cmdConfig.jwksID, "jwks-id", "", "Identifier of the jwks, JWKSID")
 	cmd.Flags().StringVar(&cmdConfig.use, "use", "sig", "How the key is meant to be used. One of: [sig, enc]")
 	cmd.Flags().StringVar(&cmdConfig.kid, "kid", "", "Unique key identifier. The Key ID is generated if not specified.")
+	cmd.Flags().BoolVar(&cmdConfig.deleteKMSKey, "delete-kms-key", false, "Delete the KMS key of the JWKS. Only the vault transit keys are deleted, the db master keysets are shared.")
 
 	return cmd
 }
 
-func runJwksDelete(logger log.Logger, datastoreConfig *config.DatastoreConfig, cmdConfig *jwksDeleteConfig) error {
-	dsClient, err := NewDatastoreClient(logger, datastoreConfig)
-	if err != nil {
-		return err
+type jwksDeleteCmd struct {
+	logger      log.Logger
+	dsClient    client.Client
+	kmsProvider kms.Provider
+}
+
+func NewJwksDeleteCmd(logger log.Logger, dsClient client.Client, kmsProvider kms.Provider) *jwksDeleteCmd {
+	return &jwksDeleteCmd{
+		logger:      logger,
+		dsClient:    dsClient,
+		kmsProvider: kmsProvider,
 	}
+}
+
+// Run deletes the JWKS, a JWKS which does not exist is not an error. JWKS referenced by an OIDC JWKS are not deleted.
+func (c *jwksDeleteCmd) Run(cmdConfig *jwksDeleteConfig) error {
+	var (
+		record *model.JWKS
+		err    error
+	)
 	if cmdConfig.jwksID != "" {
-		return dsClient.API().DeleteJWKS(context.Background(), cmdConfig.jwksID)
+		record, err = c.dsClient.API().GetJWKS(context.Background(), cmdConfig.jwksID)
 	} else {
-		return dsClient.API().DeleteJWKSByKidUse(context.Background(), cmdConfig.kid, cmdConfig.use)
+		record, err = c.dsClient.API().GetJWKSByKidUse(context.Background(), cmdConfig.kid, cmdConfig.use)
+	}
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		c.logger.Infof("JWKS not found, nothing to delete")
+		return nil
+	}
+	oidcJwksID, err := oidcJwksReferencingJwks(c.dsClient, record.ID)
+	if err != nil {
+		return err
+	}
+	if oidcJwksID != "" {
+		return errors.Errorf("jwks %s is referenced by the oidc jwks %s", record.ID, oidcJwksID)
+	}
+	return deleteJwks(c.logger, c.dsClient, c.kmsProvider, record, cmdConfig.deleteKMSKey)
+}
+
+// oidcJwksReferencingJwks returns the ID of an OIDC JWKS which references the JWKS or an empty string
+func oidcJwksReferencingJwks(dsClient client.Client, jwksID string) (string, error) {
+	list, err := dsClient.API().ListOidcJWKS(context.Background(), nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if list == nil {
+		return "", nil
+	}
+	for i := range list.List {
+		for _, id := range oidcJwksReferencedIDs(&list.List[i]) {
+			if id == jwksID {
+				return list.List[i].ID, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// deleteJwks deletes the JWKS before its KMS key, so no JWKS is left with a destroyed key.
+// When the KMS key delete fails, the key is recorded as pending destroy.
+func deleteJwks(logger log.Logger, dsClient client.Client, kmsProvider kms.Provider, record *model.JWKS, deleteKMSKey bool) error {
+	err := dsClient.API().DeleteJWKS(context.Background(), record.ID)
+	if err != nil {
+		return err
+	}
+	if !deleteKMSKey || record.KMSKeyURI == "" {
+		return nil
+	}
+	err = kmsProvider.DeleteKey(record.KMSKeyURI)
+	if err == nil {
+		return nil
+	}
+	pending := &model.KMSKeyDestroy{
+		ID:        record.ID,
+		CreatedAt: time.Now(),
+		KMSKeyURI: record.KMSKeyURI,
+		Error:     err.Error(),
+	}
+	if recordErr := dsClient.API().CreateKMSKeyDestroy(context.Background(), pending); recordErr != nil {
+		logger.Errorf("record of pending destroy of kms key %s failed: %v", record.KMSKeyURI, recordErr)
+		return errors.Wrapf(err, "jwks %s deleted, but delete of kms key %s failed and was not recorded", record.ID, record.KMSKeyURI)
 	}
+	return errors.Wrapf(err, "jwks %s deleted, but delete of kms key %s failed and is recorded as pending destroy", record.ID, record.KMSKeyURI)
 }
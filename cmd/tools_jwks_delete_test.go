@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJwksDelete(t *testing.T) {
+	api := newTestAPI()
+	api.addOidcJwks("oidc", model.OidcJWKSRotationModeManual, 0, time.Now())
+	api.jwks["standalone"] = &model.JWKS{ID: "standalone", Kid: "standalone-kid", Use: "sig", KMSKeyURI: "test://standalone"}
+	provider := newTestProvider(t)
+	deleteCmd := NewJwksDeleteCmd(log.NewDefaultLogger(), &testClient{api: api}, provider)
+
+	// the JWKS which does not exist is not an error
+	assert.NoError(t, deleteCmd.Run(&jwksDeleteConfig{jwksID: "missing"}))
+
+	assert.Error(t, deleteCmd.Run(&jwksDeleteConfig{jwksID: "oidc-current", deleteKMSKey: true}))
+	stored, _ := api.GetJWKS(context.Background(), "oidc-current")
+	assert.NotNil(t, stored)
+
+	if !assert.NoError(t, deleteCmd.Run(&jwksDeleteConfig{kid: "standalone-kid", use: "sig", deleteKMSKey: true})) {
+		return
+	}
+	stored, _ = api.GetJWKS(context.Background(), "standalone")
+	assert.Nil(t, stored)
+	assert.Equal(t, []string{"test://standalone"}, provider.deletedKeys)
+	assert.Empty(t, api.keyDestroys)
+}
+
+func TestJwksDeleteRecordsPendingKMSKeyDestroy(t *testing.T) {
+	api := newTestAPI()
+	api.jwks["standalone"] = &model.JWKS{ID: "standalone", Kid: "standalone", Use: "sig", KMSKeyURI: "test://standalone"}
+	provider := newTestProvider(t)
+	provider.deleteErr = errors.New("kms unavailable")
+
+	err := NewJwksDeleteCmd(log.NewDefaultLogger(), &testClient{api: api}, provider).Run(&jwksDeleteConfig{jwksID: "standalone", deleteKMSKey: true})
+	assert.Error(t, err)
+	stored, _ := api.GetJWKS(context.Background(), "standalone")
+	assert.Nil(t, stored)
+	if assert.Contains(t, api.keyDestroys, "standalone") {
+		assert.Equal(t, "test://standalone", api.keyDestroys["standalone"].KMSKeyURI)
+		assert.Equal(t, "kms unavailable", api.keyDestroys["standalone"].Error)
+	}
+}
@@ -0,0 +1,312 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/database/client"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	jwksGCStatusRetired = "retired"
+	jwksGCStatusDeleted = "deleted"
+	jwksGCStatusKept    = "kept"
+	jwksGCStatusFailed  = "failed"
+
+	kmsKeyDestroyStatusPending   = "pending"
+	kmsKeyDestroyStatusDestroyed = "destroyed"
+	kmsKeyDestroyStatusFailed    = "failed"
+)
+
+func init() {
+	jwksCmd.AddCommand(newJwksGCCmd())
+}
+
+type jwksGCConfig struct {
+	olderThan    time.Duration
+	use          string
+	standalone   bool
+	dryRun       bool
+	deleteKMSKey bool
+}
+
+func (c *jwksGCConfig) Validate() error {
+	if c.olderThan < 0 {
+		return errors.Errorf("older than must not be negative, but %v", c.olderThan)
+	}
+	switch c.use {
+	case "", "sig", "enc":
+	default:
+		return errors.Errorf("unsupported intend of use %s", c.use)
+	}
+	return nil
+}
+
+type jwksGCResult struct {
+	ID         string     `json:"id"`
+	Kid        string     `json:"kid"`
+	Use        string     `json:"use"`
+	Alg        string     `json:"alg"`
+	KeyStorage string     `json:"key_storage,omitempty"`
+	KeyURI     string     `json:"kms_key_uri,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// kmsKeyDestroyResult is the retry of a KMS key delete which failed after its JWKS was deleted
+type kmsKeyDestroyResult struct {
+	ID        string    `json:"id"`
+	KeyURI    string    `json:"kms_key_uri"`
+	CreatedAt time.Time `json:"created_at"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type jwksGCReport struct {
+	DryRun         bool                  `json:"dry_run"`
+	Results        []jwksGCResult        `json:"results"`
+	KMSKeyDestroys []kmsKeyDestroyResult `json:"kms_key_destroys"`
+	Failed         int                   `json:"failed"`
+}
+
+func newJwksGCCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	kmsConfig := kms.NewConfig(datastoreConfig)
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(jwksGCConfig)
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete JWKS which were retired from the OIDC JWKS before the grace period",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmdConfig.Validate(); err != nil {
+				return err
+			}
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("jwks-gc")
+			dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+			if err != nil {
+				log.Errorf("create datastore client failed: %v", err)
+				os.Exit(1)
+			}
+			var kmsProvider kms.Provider
+			if cmdConfig.deleteKMSKey {
+				kmsProvider, err = kms.NewProvider(logger, kmsConfig)
+				if err != nil {
+					log.Errorf("create kms provider failed: %v", err)
+					os.Exit(1)
+				}
+			}
+			result, err := NewJwksGCCmd(logger, dsClient, kmsProvider).Run(cmdConfig)
+			if err != nil {
+				log.Errorf("jwks gc command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+			if result.Failed != 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(kmsConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().DurationVar(&cmdConfig.olderThan, "older-than", 720*time.Hour, "Grace period, only JWKS retired before are deleted. The standalone JWKS must be created before.")
+	cmd.Flags().StringVar(&cmdConfig.use, "use", "", "Use of the collected JWKS. One of: [sig, enc] or empty for all")
+	cmd.Flags().BoolVar(&cmdConfig.standalone, "standalone", false, "Collect also the JWKS which were never retired from an OIDC JWKS e.g. used by jwt sign or jwe, or retired before the retired time was recorded")
+	cmd.Flags().BoolVar(&cmdConfig.dryRun, "dry-run", false, "Only report the retired JWKS and the pending KMS key destroys, nothing is deleted")
+	cmd.Flags().BoolVar(&cmdConfig.deleteKMSKey, "delete-kms-key", false, "Delete the KMS keys of the deleted JWKS e.g. destroy the vault transit keys. The db master keysets are shared and kept. The failed deletes are recorded and retried by the next gc.")
+
+	return cmd
+}
+
+type jwksGCCmd struct {
+	logger      log.Logger
+	dsClient    client.Client
+	kmsProvider kms.Provider
+}
+
+func NewJwksGCCmd(logger log.Logger, dsClient client.Client, kmsProvider kms.Provider) *jwksGCCmd {
+	return &jwksGCCmd{
+		logger:      logger,
+		dsClient:    dsClient,
+		kmsProvider: kmsProvider,
+	}
+}
+
+func (c *jwksGCCmd) Run(cmdConfig *jwksGCConfig) (*jwksGCReport, error) {
+	referenced, err := c.referencedJwksIDs()
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.dsClient.API().ListJWKS(context.Background(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	report := &jwksGCReport{DryRun: cmdConfig.dryRun, Results: make([]jwksGCResult, 0), KMSKeyDestroys: make([]kmsKeyDestroyResult, 0)}
+	if err = c.destroyPendingKMSKeys(cmdConfig, report); err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return report, nil
+	}
+	now := time.Now()
+	for _, record := range selectJwksGC(list.List, referenced, cmdConfig, now) {
+		result := jwksGCResult{
+			ID:         record.ID,
+			Kid:        record.Kid,
+			Use:        record.Use,
+			Alg:        record.Alg,
+			KeyStorage: record.KeyStorage,
+			KeyURI:     record.KMSKeyURI,
+			CreatedAt:  record.CreatedAt,
+			RetiredAt:  record.RetiredAt,
+			Status:     jwksGCStatusRetired,
+		}
+		if !cmdConfig.dryRun {
+			result.Status, err = c.delete(record, cmdConfig, now)
+			if err != nil {
+				c.logger.Warnf("JWKS %s delete failed: %v", record.ID, err)
+				result.Status = jwksGCStatusFailed
+				result.Error = err.Error()
+				report.Failed++
+			}
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// destroyPendingKMSKeys retries the KMS key deletes which failed after their JWKS were deleted.
+// The keys are only reported by the dry run or when the KMS keys are not deleted.
+func (c *jwksGCCmd) destroyPendingKMSKeys(cmdConfig *jwksGCConfig, report *jwksGCReport) error {
+	list, err := c.dsClient.API().ListKMSKeyDestroys(context.Background(), nil, nil)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		return nil
+	}
+	for _, pending := range list.List {
+		result := kmsKeyDestroyResult{
+			ID:        pending.ID,
+			KeyURI:    pending.KMSKeyURI,
+			CreatedAt: pending.CreatedAt,
+			Status:    kmsKeyDestroyStatusPending,
+			Error:     pending.Error,
+		}
+		if !cmdConfig.dryRun && cmdConfig.deleteKMSKey {
+			err = c.kmsProvider.DeleteKey(pending.KMSKeyURI)
+			if err == nil {
+				err = c.dsClient.API().DeleteKMSKeyDestroy(context.Background(), pending.ID)
+			}
+			if err != nil {
+				c.logger.Warnf("pending destroy of kms key %s failed: %v", pending.KMSKeyURI, err)
+				result.Status = kmsKeyDestroyStatusFailed
+				result.Error = err.Error()
+				report.Failed++
+			} else {
+				result.Status = kmsKeyDestroyStatusDestroyed
+				result.Error = ""
+			}
+		}
+		report.KMSKeyDestroys = append(report.KMSKeyDestroys, result)
+	}
+	return nil
+}
+
+// selectJwksGC returns the JWKS which are not referenced by any OIDC JWKS and were retired before the grace period.
+// The standalone JWKS have no retired time, they are selected when they were created before the grace period.
+func selectJwksGC(list []model.JWKS, referenced map[string]struct{}, cmdConfig *jwksGCConfig, now time.Time) []*model.JWKS {
+	deadline := now.Add(-cmdConfig.olderThan)
+	selected := make([]*model.JWKS, 0)
+	for i := range list {
+		record := &list[i]
+		if _, ok := referenced[record.ID]; ok {
+			continue
+		}
+		if cmdConfig.use != "" && record.Use != cmdConfig.use {
+			continue
+		}
+		if record.RetiredAt != nil {
+			if !record.RetiredAt.Before(deadline) {
+				continue
+			}
+		} else if !cmdConfig.standalone || !record.CreatedAt.Before(deadline) {
+			continue
+		}
+		selected = append(selected, record)
+	}
+	return selected
+}
+
+// referencedJwksIDs returns the next, current, previous and retired JWKS IDs of all OIDC JWKS
+func (c *jwksGCCmd) referencedJwksIDs() (map[string]struct{}, error) {
+	list, err := c.dsClient.API().ListOidcJWKS(context.Background(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]struct{})
+	if list == nil {
+		return referenced, nil
+	}
+	for i := range list.List {
+		for _, jwksID := range oidcJwksReferencedIDs(&list.List[i]) {
+			referenced[jwksID] = struct{}{}
+		}
+	}
+	return referenced, nil
+}
+
+// delete re-checks the JWKS right before it is deleted, as it could be referenced or retired again since it was selected
+// e.g. by a rollback. The JWKS is deleted before its KMS key, a failed KMS key delete is recorded as pending destroy and
+// retried by the next gc. The provider routes the key URI to the KMS provider of its scheme.
+func (c *jwksGCCmd) delete(record *model.JWKS, cmdConfig *jwksGCConfig, now time.Time) (string, error) {
+	oidcJwksID, err := oidcJwksReferencingJwks(c.dsClient, record.ID)
+	if err != nil {
+		return "", err
+	}
+	if oidcJwksID != "" {
+		c.logger.Infof("JWKS %s is referenced again by the OIDC JWKS %s, it is kept", record.ID, oidcJwksID)
+		return jwksGCStatusKept, nil
+	}
+	current, err := c.dsClient.API().GetJWKS(context.Background(), record.ID)
+	if err != nil {
+		return "", err
+	}
+	if current == nil {
+		return jwksGCStatusDeleted, nil
+	}
+	if len(selectJwksGC([]model.JWKS{*current}, nil, cmdConfig, now)) == 0 {
+		c.logger.Infof("JWKS %s was retired again within the grace period, it is kept", record.ID)
+		return jwksGCStatusKept, nil
+	}
+	if err = deleteJwks(c.logger, c.dsClient, c.kmsProvider, current, cmdConfig.deleteKMSKey); err != nil {
+		return "", err
+	}
+	return jwksGCStatusDeleted, nil
+}
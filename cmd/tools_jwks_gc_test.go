@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/grepplabs/tribe/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectJwksGC(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+	recent := now.Add(-time.Hour)
+	list := []model.JWKS{
+		{ID: "referenced", Use: "sig", CreatedAt: old, RetiredAt: &old},
+		{ID: "retired", Use: "sig", CreatedAt: old, RetiredAt: &old},
+		{ID: "retired-recently", Use: "sig", CreatedAt: old, RetiredAt: &recent},
+		{ID: "retired-enc", Use: "enc", CreatedAt: old, RetiredAt: &old},
+		{ID: "standalone", Use: "sig", CreatedAt: old},
+		{ID: "standalone-recent", Use: "sig", CreatedAt: recent},
+	}
+	referenced := map[string]struct{}{"referenced": {}}
+
+	tests := []struct {
+		name     string
+		config   jwksGCConfig
+		selected []string
+	}{
+		{name: "retired keys", config: jwksGCConfig{olderThan: 24 * time.Hour}, selected: []string{"retired", "retired-enc"}},
+		{name: "use filter", config: jwksGCConfig{olderThan: 24 * time.Hour, use: "sig"}, selected: []string{"retired"}},
+		{name: "standalone keys", config: jwksGCConfig{olderThan: 24 * time.Hour, standalone: true}, selected: []string{"retired", "retired-enc", "standalone"}},
+		{name: "no grace period", config: jwksGCConfig{use: "sig", standalone: true}, selected: []string{"retired", "retired-recently", "standalone", "standalone-recent"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			selected := make([]string, 0)
+			for _, record := range selectJwksGC(list, referenced, &tc.config, now) {
+				selected = append(selected, record.ID)
+			}
+			assert.Equal(t, tc.selected, selected)
+		})
+	}
+}
+
+func TestJwksGCRetriesFailedKMSKeyDelete(t *testing.T) {
+	retiredAt := time.Now().Add(-48 * time.Hour)
+	api := newTestAPI()
+	api.jwks["retired"] = &model.JWKS{ID: "retired", Use: "sig", KMSKeyURI: "test://retired", CreatedAt: retiredAt, RetiredAt: &retiredAt}
+	provider := newTestProvider(t)
+	provider.deleteErr = errors.New("kms unavailable")
+	cmdConfig := &jwksGCConfig{olderThan: 24 * time.Hour, deleteKMSKey: true}
+
+	// the JWKS is deleted before the KMS key, the failed KMS key delete is recorded
+	report, err := NewJwksGCCmd(log.NewDefaultLogger(), &testClient{api: api}, provider).Run(cmdConfig)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1, report.Failed)
+	stored, _ := api.GetJWKS(context.Background(), "retired")
+	assert.Nil(t, stored)
+	if assert.NotNil(t, api.keyDestroys["retired"]) {
+		assert.Equal(t, "test://retired", api.keyDestroys["retired"].KMSKeyURI)
+	}
+
+	// the dry run reports the pending destroy only
+	provider.deleteErr = nil
+	report, err = NewJwksGCCmd(log.NewDefaultLogger(), &testClient{api: api}, provider).Run(&jwksGCConfig{olderThan: 24 * time.Hour, deleteKMSKey: true, dryRun: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	if assert.Len(t, report.KMSKeyDestroys, 1) {
+		assert.Equal(t, kmsKeyDestroyStatusPending, report.KMSKeyDestroys[0].Status)
+	}
+	assert.Empty(t, provider.deletedKeys)
+
+	report, err = NewJwksGCCmd(log.NewDefaultLogger(), &testClient{api: api}, provider).Run(cmdConfig)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 0, report.Failed)
+	assert.Empty(t, report.Results)
+	if assert.Len(t, report.KMSKeyDestroys, 1) {
+		assert.Equal(t, kmsKeyDestroyStatusDestroyed, report.KMSKeyDestroys[0].Status)
+	}
+	assert.Equal(t, []string{"test://retired"}, provider.deletedKeys)
+	assert.Empty(t, api.keyDestroys)
+}
+
+func TestJwksGCDeleteRechecksJwks(t *testing.T) {
+	now := time.Now()
+	retiredAt := now.Add(-48 * time.Hour)
+	api := newTestAPI()
+	api.addOidcJwks("oidc", model.OidcJWKSRotationModeManual, 0, now).PreviousJwksID = utils.String("referenced")
+	for _, id := range []string{"referenced", "retired-again", "retired"} {
+		api.jwks[id] = &model.JWKS{ID: id, Use: "sig", KMSKeyURI: "test://" + id, CreatedAt: retiredAt, RetiredAt: &retiredAt}
+	}
+	provider := newTestProvider(t)
+	gc := NewJwksGCCmd(log.NewDefaultLogger(), &testClient{api: api}, provider)
+	cmdConfig := &jwksGCConfig{olderThan: 24 * time.Hour, deleteKMSKey: true}
+
+	// the records were selected before a rollback referenced one and a rotation retired the other again
+	selected := map[string]model.JWKS{}
+	for id, record := range api.jwks {
+		selected[id] = *record
+	}
+	api.jwks["retired-again"].RetiredAt = &now
+
+	tests := []struct {
+		id     string
+		status string
+	}{
+		{id: "referenced", status: jwksGCStatusKept},
+		{id: "retired-again", status: jwksGCStatusKept},
+		{id: "retired", status: jwksGCStatusDeleted},
+	}
+	for _, tc := range tests {
+		record := selected[tc.id]
+		status, err := gc.delete(&record, cmdConfig, now)
+		if assert.NoError(t, err, tc.id) {
+			assert.Equal(t, tc.status, status, tc.id)
+		}
+	}
+	assert.NotNil(t, api.jwks["referenced"])
+	assert.NotNil(t, api.jwks["retired-again"])
+	assert.Nil(t, api.jwks["retired"])
+	assert.Equal(t, []string{"test://retired"}, provider.deletedKeys)
+}
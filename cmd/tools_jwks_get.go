@@ -2,16 +2,19 @@ package cmd
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
+	"github.com/go-openapi/runtime"
 	"github.com/grepplabs/tribe/config"
 	"github.com/grepplabs/tribe/database/client"
 	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/kms"
 	"github.com/grepplabs/tribe/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"gopkg.in/square/go-jose.v2"
+	"io"
 	"os"
+	"strings"
 )
 
 func init() {
@@ -23,19 +26,80 @@ type jwksGetConfig struct {
 
 	use string
 	kid string
+
+	format     string
+	publicOnly bool
+	outFile    string
 }
 
 func (c *jwksGetConfig) Validate() error {
 	if c.kid == "" && c.jwksID == "" {
 		return errors.New("either jwks-id or kid is required")
 	}
+	if c.format != "" {
+		supported := false
+		for _, format := range jwk.ExportFormats {
+			if c.format == format {
+				supported = true
+			}
+		}
+		if !supported {
+			return errors.Errorf("unsupported format %s. One of: [%s]", c.format, strings.Join(jwk.ExportFormats, ", "))
+		}
+	}
 	return nil
 }
 
+// write writes the keys to the output file or stdout. Without the format the keys are written by the producer.
+func (c *jwksGetConfig) write(producer runtime.Producer, keys *jose.JSONWebKeySet) (err error) {
+	var out io.Writer = os.Stdout
+	if c.outFile != "" {
+		f, err := openKeyFile(c.outFile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil && cerr != nil {
+				err = errors.Wrapf(cerr, "close file %s failed", c.outFile)
+			}
+		}()
+		out = f
+	}
+	if c.format == "" {
+		if c.publicOnly {
+			keys, err = jwk.PublicKeys(keys)
+			if err != nil {
+				return err
+			}
+		}
+		return producer.Produce(out, jwk.JSONKeySet{JSONWebKeySet: keys})
+	}
+	data, err := jwk.Export(keys, c.format, c.publicOnly)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// openKeyFile creates or truncates the file readable by the owner only
+func openKeyFile(filename string) (*os.File, error) {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open file %s failed", filename)
+	}
+	// permissions of an existing file are not changed by open
+	if err = f.Chmod(0600); err != nil {
+		_ = f.Close()
+		return nil, errors.Wrapf(err, "chmod file %s failed", filename)
+	}
+	return f, nil
+}
+
 func newJwksGetCmd() *cobra.Command {
 	logConfig := config.NewLogConfig()
 	datastoreConfig := config.NewDatastoreConfig()
-	kmsConfig := config.NewKMSConfig(datastoreConfig)
+	kmsConfig := kms.NewConfig(datastoreConfig)
 	outputConfig := config.NewOutputConfig()
 	cmdConfig := new(jwksGetConfig)
 
@@ -60,7 +124,7 @@ func newJwksGetCmd() *cobra.Command {
 				log.Errorf("create datastore client failed: %v", err)
 				os.Exit(1)
 			}
-			kmsProvider, err := NewKMSProvider(logger, kmsConfig)
+			kmsProvider, err := kms.NewProvider(logger, kmsConfig)
 			if err != nil {
 				log.Errorf("create kms provider failed: %v", err)
 				os.Exit(1)
@@ -74,7 +138,7 @@ func newJwksGetCmd() *cobra.Command {
 				log.Errorf("jwks get command failed, not found jwksID %s", cmdConfig.jwksID)
 				os.Exit(1)
 			}
-			err = producer.Produce(os.Stdout, result)
+			err = cmdConfig.write(producer, result)
 			if err != nil {
 				log.Errorf("failed to write result: %v", err)
 				os.Exit(1)
@@ -90,6 +154,9 @@ func newJwksGetCmd() *cobra.Command {
 	cmd.Flags().StringVar(&cmdConfig.jwksID, "jwks-id", "", "Identifier of the jwks, JWKSID")
 	cmd.Flags().StringVar(&cmdConfig.use, "use", "sig", "How the key is meant to be used. One of: [sig, enc]")
 	cmd.Flags().StringVar(&cmdConfig.kid, "kid", "", "Unique key identifier. The Key ID is generated if not specified.")
+	cmd.Flags().StringVar(&cmdConfig.format, "format", "", "Export format of the keys instead of the JWKS output. One of: [pem, pkcs8, pkix, ssh, jwk-public]")
+	cmd.Flags().BoolVar(&cmdConfig.publicOnly, "public-only", false, "Export the public keys only")
+	cmd.Flags().StringVar(&cmdConfig.outFile, "out-file", "", "Write the keys to the file with 0600 permissions instead of stdout")
 
 	return cmd
 }
@@ -97,10 +164,10 @@ func newJwksGetCmd() *cobra.Command {
 type jwksCreateGet struct {
 	logger      log.Logger
 	dsClient    client.Client
-	kmsProvider KMSProvider
+	kmsProvider kms.Provider
 }
 
-func NewJwksGetCmd(logger log.Logger, dsClient client.Client, kmsProvider KMSProvider) *jwksCreateGet {
+func NewJwksGetCmd(logger log.Logger, dsClient client.Client, kmsProvider kms.Provider) *jwksCreateGet {
 	return &jwksCreateGet{
 		logger:      logger,
 		dsClient:    dsClient,
@@ -113,24 +180,11 @@ func (c *jwksCreateGet) Run(cmdConfig *jwksGetConfig) (*jose.JSONWebKeySet, erro
 	if err != nil {
 		return nil, err
 	}
-	encryptedKeys, err := base64.StdEncoding.DecodeString(jwks.EncryptedJwks)
-	if err != nil {
-		return nil, errors.Wrapf(err, "base64 decode of JWKS ID failed: %s", cmdConfig.jwksID)
-	}
-	aead, err := c.kmsProvider.AEADFromKeyURI(jwks.KMSKeyURI)
-	if err != nil {
-		return nil, err
-	}
-	decryptedKeys, err := aead.Decrypt(encryptedKeys, []byte{})
-	if err != nil {
-		return nil, errors.Wrap(err, "AEAD keys decryption failed")
-	}
-	var result jose.JSONWebKeySet
-	err = json.Unmarshal(decryptedKeys, &result)
-	if err != nil {
-		return nil, errors.Wrap(err, "Unmarshal JSONWebKeySet failed")
-	}
-	return &result, nil
+	return c.decrypt(jwks)
+}
+
+func (c *jwksCreateGet) decrypt(jwks *model.JWKS) (*jose.JSONWebKeySet, error) {
+	return jwk.DecryptJWKS(c.kmsProvider, jwks)
 }
 
 func (c *jwksCreateGet) getJwks(cmdConfig *jwksGetConfig) (*model.JWKS, error) {
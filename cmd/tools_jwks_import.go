@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/grepplabs/tribe/pkg/secret"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/square/go-jose.v2"
+)
+
+const (
+	jwksImportFormatAuto   = "auto"
+	jwksImportFormatPEM    = "pem"
+	jwksImportFormatJWK    = "jwk"
+	jwksImportFormatPKCS12 = "pkcs12"
+)
+
+func init() {
+	jwksCmd.AddCommand(newJwksImportCmd())
+}
+
+type jwksImportConfig struct {
+	file     string
+	format   string
+	password string
+
+	sourceKid string
+	jwksID    string
+	kid       string
+	alg       string
+	use       string
+}
+
+func (c *jwksImportConfig) Validate() error {
+	switch c.format {
+	case jwksImportFormatAuto, jwksImportFormatPEM, jwksImportFormatJWK, jwksImportFormatPKCS12:
+	default:
+		return errors.Errorf("unsupported format %s", c.format)
+	}
+	if c.use != "sig" && c.use != "enc" {
+		return errors.Errorf("unsupported intend of use %s", c.use)
+	}
+	return nil
+}
+
+type jwksImportResult struct {
+	ID        string       `json:"id"`
+	Kid       string       `json:"kid"`
+	Alg       string       `json:"alg"`
+	Use       string       `json:"use"`
+	PublicKey *jwk.JSONKey `json:"public_key,omitempty"`
+}
+
+func newJwksImportCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	kmsConfig := kms.NewConfig(datastoreConfig)
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(jwksImportConfig)
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import an externally generated private or symmetric key as JWKS",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmdConfig.Validate(); err != nil {
+				return err
+			}
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("jwks-import")
+			dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+			if err != nil {
+				log.Errorf("create datastore client failed: %v", err)
+				os.Exit(1)
+			}
+			kmsProvider, err := kms.NewProvider(logger, kmsConfig)
+			if err != nil {
+				log.Errorf("create kms provider failed: %v", err)
+				os.Exit(1)
+			}
+			result, err := runJwksImport(NewJwksCreateCmd(logger, dsClient, kmsProvider), cmdConfig)
+			if err != nil {
+				log.Errorf("jwks import command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(kmsConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().StringVar(&cmdConfig.file, "file", "", "File with the key to import")
+	cmd.Flags().StringVar(&cmdConfig.format, "format", jwksImportFormatAuto, "Format of the file. One of: [auto, pem, jwk, pkcs12]. PEM supports PKCS#1, PKCS#8 and SEC1 keys, jwk supports JWK and JWKS. With auto .p12 and .pfx files are PKCS#12.")
+	cmd.Flags().StringVar(&cmdConfig.password, "password", "", "Password or secret reference of the PKCS#12 file")
+	cmd.Flags().StringVar(&cmdConfig.sourceKid, "source-kid", "", "Kid of the key to import, required if the file has several private keys")
+	cmd.Flags().StringVar(&cmdConfig.jwksID, "jwks-id", "", "Identifier of the jwks, generated if not provided")
+	cmd.Flags().StringVar(&cmdConfig.kid, "kid", "", "Kid of the imported key. The kid of the source key is kept if not provided, so the issued tokens keep validating.")
+	cmd.Flags().StringVar(&cmdConfig.alg, "alg", "", "The rfc7518 JWA algorithm of the key, the alg of the source JWK is used if not provided")
+	cmd.Flags().StringVar(&cmdConfig.use, "use", "sig", "How the key is meant to be used. One of: [sig, enc]")
+
+	_ = cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+func runJwksImport(jwksCreate *jwksCreateCmd, cmdConfig *jwksImportConfig) (*jwksImportResult, error) {
+	source, err := readImportKeys(cmdConfig)
+	if err != nil {
+		return nil, err
+	}
+	sourceKey, err := jwk.SelectImportKey(source, cmdConfig.sourceKid)
+	if err != nil {
+		return nil, err
+	}
+	alg := cmdConfig.alg
+	if alg == "" {
+		alg = sourceKey.Algorithm
+	}
+	if alg == "" {
+		return nil, errors.New("alg is required, the imported key has no alg")
+	}
+	id := cmdConfig.jwksID
+	if id == "" {
+		id = uuid.NewString()
+	}
+	kid := cmdConfig.kid
+	if kid == "" && sourceKey.KeyID == "" {
+		// e.g. PEM and PKCS#12 keys have no kid
+		kid = id
+	}
+	keys, err := jwk.ImportKey(source, cmdConfig.sourceKid, kid, alg, cmdConfig.use)
+	if err != nil {
+		return nil, err
+	}
+	kid = keys.Keys[0].KeyID
+	existing, err := jwksCreate.dsClient.API().GetJWKSByKidUse(context.Background(), kid, cmdConfig.use)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.Errorf("JWKS with kid %s and use %s already exists: %s", kid, cmdConfig.use, existing.ID)
+	}
+	if err = jwksCreate.store(id, kid, alg, cmdConfig.use, keys); err != nil {
+		return nil, err
+	}
+	result := &jwksImportResult{
+		ID:  id,
+		Kid: kid,
+		Alg: alg,
+		Use: cmdConfig.use,
+	}
+	if publicKey, err := jwk.PublicKey(id, keys); err == nil {
+		result.PublicKey = &jwk.JSONKey{JSONWebKey: publicKey}
+	}
+	return result, nil
+}
+
+func readImportKeys(cmdConfig *jwksImportConfig) (*jose.JSONWebKeySet, error) {
+	data, err := ioutil.ReadFile(cmdConfig.file)
+	if err != nil {
+		return nil, errors.Wrap(err, "read key file failed")
+	}
+	format := cmdConfig.format
+	if format == jwksImportFormatAuto {
+		switch strings.ToLower(filepath.Ext(cmdConfig.file)) {
+		case ".p12", ".pfx":
+			format = jwksImportFormatPKCS12
+		}
+	}
+	if format == jwksImportFormatPKCS12 {
+		password, err := secret.Resolve("password", cmdConfig.password)
+		if err != nil {
+			return nil, errors.Wrap(err, "resolve password failed")
+		}
+		return jwk.ParsePKCS12(data, password)
+	}
+	return jwk.ParseKeys(data)
+}
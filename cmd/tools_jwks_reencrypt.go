@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/google/tink/go/tink"
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/database/client"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/jwk/jwkscrypt"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	jwksReencryptStatusOK          = "ok"
+	jwksReencryptStatusReencrypted = "reencrypted"
+	jwksReencryptStatusOutdated    = "outdated"
+	jwksReencryptStatusMismatch    = "mismatch"
+	jwksReencryptStatusSkipped     = "skipped"
+	jwksReencryptStatusRewrapped   = "rewrapped"
+	jwksReencryptStatusError       = "error"
+)
+
+func init() {
+	jwksCmd.AddCommand(newJwksReencryptCmd())
+}
+
+type jwksReencryptConfig struct {
+	jwksID string
+	verify bool
+	rewrap bool
+}
+
+func (c *jwksReencryptConfig) Validate() error {
+	if c.verify && c.rewrap {
+		return errors.New("verify and rewrap are mutually exclusive")
+	}
+	return nil
+}
+
+type jwksReencryptResult struct {
+	ID     string `json:"id"`
+	Kid    string `json:"kid"`
+	Use    string `json:"use"`
+	Alg    string `json:"alg"`
+	Format int    `json:"format"`
+	KeyURI string `json:"kms_key_uri,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type jwksReencryptReport struct {
+	Results    []jwksReencryptResult `json:"results"`
+	Mismatches int                   `json:"mismatches"`
+	Errors     int                   `json:"errors"`
+}
+
+func newJwksReencryptCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	kmsConfig := kms.NewConfig(datastoreConfig)
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(jwksReencryptConfig)
+
+	cmd := &cobra.Command{
+		Use:   "reencrypt",
+		Short: "Re-encrypt JWKS bound to their records or verify the binding",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmdConfig.Validate(); err != nil {
+				return err
+			}
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("jwks-reencrypt")
+			dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+			if err != nil {
+				log.Errorf("create datastore client failed: %v", err)
+				os.Exit(1)
+			}
+			kmsProvider, err := kms.NewProvider(logger, kmsConfig)
+			if err != nil {
+				log.Errorf("create kms provider failed: %v", err)
+				os.Exit(1)
+			}
+			result, err := NewJwksReencryptCmd(logger, dsClient, kmsProvider).Run(cmdConfig)
+			if err != nil {
+				log.Errorf("jwks reencrypt command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+			if result.Mismatches != 0 || result.Errors != 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(kmsConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().StringVar(&cmdConfig.jwksID, "jwks-id", "", "Identifier of the jwks, all JWKS are processed if not provided")
+	cmd.Flags().BoolVar(&cmdConfig.verify, "verify", false, "Only verify the records and report mismatches, nothing is re-encrypted")
+	cmd.Flags().BoolVar(&cmdConfig.rewrap, "rewrap", false, "Encrypt the data encryption keys with the configured KMS key e.g. after a kms keyset change, the JWKS are not re-encrypted. Records in older formats are re-encrypted with the configured KMS key.")
+
+	return cmd
+}
+
+type jwksReencryptCmd struct {
+	logger      log.Logger
+	dsClient    client.Client
+	kmsProvider kms.Provider
+}
+
+func NewJwksReencryptCmd(logger log.Logger, dsClient client.Client, kmsProvider kms.Provider) *jwksReencryptCmd {
+	return &jwksReencryptCmd{
+		logger:      logger,
+		dsClient:    dsClient,
+		kmsProvider: kmsProvider,
+	}
+}
+
+func (c *jwksReencryptCmd) Run(cmdConfig *jwksReencryptConfig) (*jwksReencryptReport, error) {
+	var records []model.JWKS
+	if cmdConfig.jwksID != "" {
+		record, err := getJwksByID(c.dsClient, cmdConfig.jwksID)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *record)
+	} else {
+		list, err := c.dsClient.API().ListJWKS(context.Background(), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if list != nil {
+			records = list.List
+		}
+	}
+	report := &jwksReencryptReport{Results: make([]jwksReencryptResult, 0, len(records))}
+	for i := range records {
+		result := c.process(&records[i], cmdConfig)
+		switch result.Status {
+		case jwksReencryptStatusMismatch:
+			report.Mismatches++
+			c.logger.Warnf("JWKS %s binding mismatch: %s", result.ID, result.Error)
+		case jwksReencryptStatusError:
+			report.Errors++
+			c.logger.Warnf("JWKS %s reencrypt failed: %s", result.ID, result.Error)
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+func (c *jwksReencryptCmd) process(record *model.JWKS, cmdConfig *jwksReencryptConfig) jwksReencryptResult {
+	result := jwksReencryptResult{
+		ID:     record.ID,
+		Kid:    record.Kid,
+		Use:    record.Use,
+		Alg:    record.Alg,
+		Format: jwkscrypt.FormatVersion(record.EncryptedJwks),
+	}
+	if record.IsVaultTransit() {
+		// only the public keys are stored
+		result.Status = jwksReencryptStatusSkipped
+		return result
+	}
+	mismatch := func(err error) jwksReencryptResult {
+		result.Status = jwksReencryptStatusMismatch
+		result.Error = err.Error()
+		return result
+	}
+	failure := func(err error) jwksReencryptResult {
+		result.Status = jwksReencryptStatusError
+		result.Error = err.Error()
+		return result
+	}
+	aead, err := c.kmsProvider.AEADFromKeyURI(record.KMSKeyURI)
+	if err != nil {
+		return failure(err)
+	}
+	if cmdConfig.rewrap && result.Format == jwkscrypt.FormatCurrent {
+		// only the DEK is decrypted and encrypted again
+		return c.rewrap(record, aead, result)
+	}
+	plaintext, err := decryptAndCheckJwks(aead, record)
+	if err != nil {
+		return mismatch(err)
+	}
+	if result.Format == jwkscrypt.FormatCurrent {
+		result.Status = jwksReencryptStatusOK
+		return result
+	}
+	if cmdConfig.verify {
+		result.Status = jwksReencryptStatusOutdated
+		return result
+	}
+	if cmdConfig.rewrap {
+		newAEAD, keyURI, err := c.kmsProvider.NewAEAD(record.ID)
+		if err != nil {
+			return failure(err)
+		}
+		aead = newAEAD
+		record.KMSKeyURI = keyURI
+		result.KeyURI = keyURI
+	}
+	if err = jwkscrypt.Encrypt(aead, record, plaintext); err == nil {
+		err = c.dsClient.API().UpdateJWKS(context.Background(), record)
+	}
+	if err != nil {
+		return failure(err)
+	}
+	result.Format = jwkscrypt.FormatCurrent
+	result.Status = jwksReencryptStatusReencrypted
+	return result
+}
+
+func (c *jwksReencryptCmd) rewrap(record *model.JWKS, aead tink.AEAD, result jwksReencryptResult) jwksReencryptResult {
+	newAEAD, keyURI, err := c.kmsProvider.NewAEAD(record.ID)
+	if err != nil {
+		result.Status = jwksReencryptStatusError
+		result.Error = err.Error()
+		return result
+	}
+	// the DEK does not decrypt when the record binding does not match
+	if err = jwkscrypt.Rewrap(aead, newAEAD, record); err != nil {
+		result.Status = jwksReencryptStatusMismatch
+		result.Error = err.Error()
+		return result
+	}
+	record.KMSKeyURI = keyURI
+	if err = c.dsClient.API().UpdateJWKS(context.Background(), record); err != nil {
+		result.Status = jwksReencryptStatusError
+		result.Error = err.Error()
+		return result
+	}
+	result.KeyURI = keyURI
+	result.Status = jwksReencryptStatusRewrapped
+	return result
+}
+
+// decryptAndCheckJwks decrypts the record and checks the keys belong to it, which also detects swapped legacy ciphertexts
+func decryptAndCheckJwks(aead tink.AEAD, record *model.JWKS) ([]byte, error) {
+	plaintext, err := jwkscrypt.Decrypt(aead, record)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := jwk.UnmarshalKeySet(plaintext)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unmarshal JSONWebKeySet failed")
+	}
+	if len(keys.Keys) == 0 {
+		return nil, errors.New("JWKS has no keys")
+	}
+	for _, key := range keys.Keys {
+		if jwk.BaseKeyID(key.KeyID) != record.Kid || key.Use != record.Use || key.Algorithm != record.Alg {
+			return nil, errors.Errorf("key %s/%s/%s does not match the record", key.KeyID, key.Use, key.Algorithm)
+		}
+	}
+	return plaintext, nil
+}
@@ -1,31 +1,38 @@
 package cmd
 
 import (
-	"github.com/grepplabs/tribe/config"
-	"github.com/grepplabs/tribe/pkg/log"
+	"io/ioutil"
+	"os"
+	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
-func init() {
-	toolsCmd.AddCommand(newJwtCmd())
+var jwtCmd = &cobra.Command{
+	Use:   "jwt",
+	Short: "JWT tools",
 }
 
-func newJwtCmd() *cobra.Command {
-	logConfig := config.NewLogConfig()
-	dbConfig := config.NewDBConfig()
+func init() {
+	toolsCmd.AddCommand(jwtCmd)
+}
 
-	cmd := &cobra.Command{
-		Use:   "jwt",
-		Short: "JWT tools",
-		Run: func(cmd *cobra.Command, args []string) {
-			logger := log.NewLogger(logConfig.Configuration).WithName("jwt")
-			logger.Infof("jwt called")
-		},
+// readToken returns the token of the flag or argument, the token is read from stdin when it is "-"
+func readToken(token string, args []string) (string, error) {
+	if token == "" && len(args) != 0 {
+		token = args[0]
 	}
-
-	cmd.Flags().AddFlagSet(logConfig.FlagSet())
-	cmd.Flags().AddFlagSet(dbConfig.FlagSet())
-
-	return cmd
+	if token == "-" {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return "", errors.Wrap(err, "read token from stdin failed")
+		}
+		token = string(data)
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", errors.New("token is required")
+	}
+	return token, nil
 }
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+)
+
+func init() {
+	jwtCmd.AddCommand(newJwtDecodeCmd())
+}
+
+type jwtDecodeConfig struct {
+	token  string
+	leeway time.Duration
+}
+
+// jwtDecodeResult is the decoded token, the signature is not verified
+type jwtDecodeResult struct {
+	Header      map[string]interface{} `json:"header"`
+	Claims      map[string]interface{} `json:"claims"`
+	Diagnostics []string               `json:"diagnostics"`
+}
+
+func newJwtDecodeCmd() *cobra.Command {
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(jwtDecodeConfig)
+
+	cmd := &cobra.Command{
+		Use:   "decode [token]",
+		Short: "Decode the JWT header and claims without verifying the signature",
+		Args:  cobra.MaximumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			token, err := readToken(cmdConfig.token, args)
+			if err != nil {
+				log.Errorf("jwt decode command failed: %v", err)
+				os.Exit(1)
+			}
+			result, err := decodeJwt(token)
+			if err != nil {
+				log.Errorf("jwt decode command failed: %v", err)
+				os.Exit(1)
+			}
+			result.Diagnostics = jwtDiagnostics(result, time.Now(), cmdConfig.leeway)
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().StringVar(&cmdConfig.token, "token", "", "Token to decode, '-' reads from stdin. The token can be also provided as argument.")
+	cmd.Flags().DurationVar(&cmdConfig.leeway, "leeway", josejwt.DefaultLeeway, "Allowed clock skew of the exp, nbf and iat claims")
+
+	return cmd
+}
+
+func decodeJwt(token string) (*jwtDecodeResult, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) == 5 {
+		return nil, errors.New("token is encrypted (JWE), only signed tokens can be decoded")
+	}
+	if len(parts) != 3 {
+		return nil, errors.Errorf("token must have 3 parts, got %d", len(parts))
+	}
+	result := &jwtDecodeResult{}
+	if err := decodeJwtPart(parts[0], &result.Header); err != nil {
+		return nil, errors.Wrap(err, "decode header failed")
+	}
+	if err := decodeJwtPart(parts[1], &result.Claims); err != nil {
+		return nil, errors.Wrap(err, "decode claims failed")
+	}
+	return result, nil
+}
+
+func decodeJwtPart(part string, v *map[string]interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// jwtDiagnostics reports the problems the verification would find without the keys
+func jwtDiagnostics(decoded *jwtDecodeResult, now time.Time, leeway time.Duration) []string {
+	diagnostics := []string{"signature is not verified"}
+	alg, _ := decoded.Header["alg"].(string)
+	switch {
+	case alg == "":
+		diagnostics = append(diagnostics, "missing alg header")
+	case strings.EqualFold(alg, "none"):
+		diagnostics = append(diagnostics, "alg 'none' is insecure and never accepted")
+	}
+	if kid, _ := decoded.Header["kid"].(string); kid == "" {
+		diagnostics = append(diagnostics, "missing kid header, the verification key cannot be resolved")
+	}
+	claimTime := func(name string) (time.Time, bool, error) {
+		value, ok := decoded.Claims[name]
+		if !ok {
+			return time.Time{}, false, nil
+		}
+		number, ok := value.(json.Number)
+		if !ok {
+			return time.Time{}, true, errors.Errorf("%s claim is not a number", name)
+		}
+		seconds, err := number.Float64()
+		if err != nil {
+			return time.Time{}, true, errors.Errorf("%s claim is not a number", name)
+		}
+		return time.Unix(int64(seconds), 0), true, nil
+	}
+	if exp, ok, err := claimTime("exp"); err != nil {
+		diagnostics = append(diagnostics, err.Error())
+	} else if !ok {
+		diagnostics = append(diagnostics, "missing exp claim")
+	} else if now.Add(-leeway).After(exp) {
+		diagnostics = append(diagnostics, fmt.Sprintf("token expired at %s", exp.UTC().Format(time.RFC3339)))
+	}
+	if nbf, ok, err := claimTime("nbf"); err != nil {
+		diagnostics = append(diagnostics, err.Error())
+	} else if ok && now.Add(leeway).Before(nbf) {
+		diagnostics = append(diagnostics, fmt.Sprintf("token is not valid before %s", nbf.UTC().Format(time.RFC3339)))
+	}
+	if iat, ok, err := claimTime("iat"); err != nil {
+		diagnostics = append(diagnostics, err.Error())
+	} else if ok && now.Add(leeway).Before(iat) {
+		diagnostics = append(diagnostics, fmt.Sprintf("token is issued in the future at %s", iat.UTC().Format(time.RFC3339)))
+	}
+	return diagnostics
+}
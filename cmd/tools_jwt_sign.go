@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/jwt"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+)
+
+func init() {
+	jwtCmd.AddCommand(newJwtSignCmd())
+}
+
+type jwtSignConfig struct {
+	jwksID     string
+	oidcJwksID string
+
+	issuer     string
+	subject    string
+	audience   []string
+	expiresIn  time.Duration
+	notBefore  time.Duration
+	jwtID      bool
+	claims     []string
+	claimsFile string
+}
+
+func (c *jwtSignConfig) Validate() error {
+	if (c.jwksID == "") == (c.oidcJwksID == "") {
+		return errors.New("either jwks-id or oidc-jwks-id is required")
+	}
+	if c.expiresIn <= 0 {
+		return errors.New("expires-in must be positive")
+	}
+	for _, claim := range c.claims {
+		if !strings.Contains(claim, "=") {
+			return errors.Errorf("claim %s must be in the format name=value", claim)
+		}
+	}
+	return nil
+}
+
+type jwtSignResult struct {
+	Token     string    `json:"token"`
+	Kid       string    `json:"kid"`
+	Alg       string    `json:"alg"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func newJwtSignCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	kmsConfig := kms.NewConfig(datastoreConfig)
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(jwtSignConfig)
+
+	cmd := &cobra.Command{
+		Use:   "sign",
+		Short: "Sign JWT claims with a JWKS or the current key of an OIDC JWKS",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmdConfig.Validate(); err != nil {
+				return err
+			}
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("jwt-sign")
+			dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+			if err != nil {
+				log.Errorf("create datastore client failed: %v", err)
+				os.Exit(1)
+			}
+			kmsProvider, err := kms.NewProvider(logger, kmsConfig)
+			if err != nil {
+				log.Errorf("create kms provider failed: %v", err)
+				os.Exit(1)
+			}
+			var signer *jwt.Signer
+			if cmdConfig.jwksID != "" {
+				signer = jwt.NewJWKSSigner(dsClient, kmsProvider, cmdConfig.jwksID)
+			} else {
+				signer = jwt.NewSigner(dsClient, kmsProvider, cmdConfig.oidcJwksID)
+			}
+			result, err := runJwtSign(signer, cmdConfig, time.Now())
+			if err != nil {
+				log.Errorf("jwt sign command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(kmsConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().StringVar(&cmdConfig.jwksID, "jwks-id", "", "Identifier of the JWKS signing the token")
+	cmd.Flags().StringVar(&cmdConfig.oidcJwksID, "oidc-jwks-id", "", "Identifier of the OIDC JWKS, the token is signed by its current key")
+	cmd.Flags().StringVar(&cmdConfig.issuer, "iss", "", "Issuer claim")
+	cmd.Flags().StringVar(&cmdConfig.subject, "sub", "", "Subject claim")
+	cmd.Flags().StringSliceVar(&cmdConfig.audience, "aud", nil, "Audience claim, can be repeated")
+	cmd.Flags().DurationVar(&cmdConfig.expiresIn, "expires-in", time.Hour, "Lifetime of the token, sets the exp claim")
+	cmd.Flags().DurationVar(&cmdConfig.notBefore, "not-before", 0, "Delay after which the token is valid, sets the nbf claim when not zero")
+	cmd.Flags().BoolVar(&cmdConfig.jwtID, "jti", false, "Set a random jti claim")
+	cmd.Flags().StringArrayVar(&cmdConfig.claims, "claim", nil, "Custom claim name=value, the value is used as JSON when valid otherwise as string. Can be repeated.")
+	cmd.Flags().StringVar(&cmdConfig.claimsFile, "claims-file", "", "JSON file with the claims, '-' reads from stdin. The claim flags take precedence.")
+
+	return cmd
+}
+
+func runJwtSign(signer *jwt.Signer, cmdConfig *jwtSignConfig, now time.Time) (*jwtSignResult, error) {
+	claims, err := jwtSignClaims(cmdConfig)
+	if err != nil {
+		return nil, err
+	}
+	registered := josejwt.Claims{
+		Issuer:   cmdConfig.issuer,
+		Subject:  cmdConfig.subject,
+		Audience: cmdConfig.audience,
+		Expiry:   josejwt.NewNumericDate(now.Add(cmdConfig.expiresIn)),
+		IssuedAt: josejwt.NewNumericDate(now),
+	}
+	if cmdConfig.notBefore != 0 {
+		registered.NotBefore = josejwt.NewNumericDate(now.Add(cmdConfig.notBefore))
+	}
+	if cmdConfig.jwtID {
+		registered.ID = uuid.NewString()
+	}
+	// the registered claims of the flags override the custom claims
+	token, err := signer.Sign(context.Background(), claims, registered)
+	if err != nil {
+		return nil, err
+	}
+	kid, alg, err := signer.KeyID(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &jwtSignResult{
+		Token:     token,
+		Kid:       kid,
+		Alg:       string(alg),
+		ExpiresAt: registered.Expiry.Time().UTC(),
+	}, nil
+}
+
+func jwtSignClaims(cmdConfig *jwtSignConfig) (map[string]interface{}, error) {
+	claims := make(map[string]interface{})
+	if cmdConfig.claimsFile != "" {
+		var (
+			data []byte
+			err  error
+		)
+		if cmdConfig.claimsFile == "-" {
+			data, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			data, err = ioutil.ReadFile(cmdConfig.claimsFile)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "read claims file failed")
+		}
+		if err = json.Unmarshal(data, &claims); err != nil {
+			return nil, errors.Wrap(err, "claims file must be a JSON object")
+		}
+	}
+	for _, claim := range cmdConfig.claims {
+		kv := strings.SplitN(claim, "=", 2)
+		var value interface{}
+		if err := json.Unmarshal([]byte(kv[1]), &value); err != nil {
+			value = kv[1]
+		}
+		claims[kv[0]] = value
+	}
+	// registered claims are set by the flags
+	for _, name := range []string{"exp", "iat", "nbf"} {
+		delete(claims, name)
+	}
+	return claims, nil
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/jwt"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+)
+
+func init() {
+	jwtCmd.AddCommand(newJwtVerifyCmd())
+}
+
+type jwtVerifyConfig struct {
+	token string
+
+	jwksID     string
+	oidcJwksID string
+	jwksFile   string
+	jwksURL    string
+
+	issuer     string
+	audience   []string
+	leeway     time.Duration
+	algorithms []string
+}
+
+func (c *jwtVerifyConfig) Validate() error {
+	sources := 0
+	for _, source := range []string{c.jwksID, c.oidcJwksID, c.jwksFile, c.jwksURL} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return errors.New("exactly one of jwks-id, oidc-jwks-id, jwks-file or jwks-url is required")
+	}
+	return nil
+}
+
+func (c *jwtVerifyConfig) usesDatastore() bool {
+	return c.jwksID != "" || c.oidcJwksID != ""
+}
+
+type jwtVerifyResult struct {
+	Valid  bool                   `json:"valid"`
+	Header map[string]interface{} `json:"header"`
+	Claims map[string]interface{} `json:"claims"`
+}
+
+func newJwtVerifyCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	kmsConfig := kms.NewConfig(datastoreConfig)
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(jwtVerifyConfig)
+
+	cmd := &cobra.Command{
+		Use:   "verify [token]",
+		Short: "Verify the JWT signature and claims against a JWKS from the datastore, a file or an URL",
+		Args:  cobra.MaximumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmdConfig.Validate(); err != nil {
+				return err
+			}
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("jwt-verify")
+			token, err := readToken(cmdConfig.token, args)
+			if err != nil {
+				log.Errorf("jwt verify command failed: %v", err)
+				os.Exit(1)
+			}
+			keySource, err := newJwtVerifyKeySource(logger, datastoreConfig, kmsConfig, cmdConfig)
+			if err != nil {
+				log.Errorf("create key source failed: %v", err)
+				os.Exit(1)
+			}
+			result, err := runJwtVerify(keySource, cmdConfig, token)
+			if err != nil {
+				log.Errorf("jwt verify command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(kmsConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().StringVar(&cmdConfig.token, "token", "", "Token to verify, '-' reads from stdin. The token can be also provided as argument.")
+	cmd.Flags().StringVar(&cmdConfig.jwksID, "jwks-id", "", "Identifier of the JWKS in the datastore")
+	cmd.Flags().StringVar(&cmdConfig.oidcJwksID, "oidc-jwks-id", "", "Identifier of the OIDC JWKS in the datastore, the current, next and previous keys are accepted")
+	cmd.Flags().StringVar(&cmdConfig.jwksFile, "jwks-file", "", "File with the JWKS or a single JWK")
+	cmd.Flags().StringVar(&cmdConfig.jwksURL, "jwks-url", "", "URL of the JWKS e.g. https://issuer/.well-known/jwks.json")
+	cmd.Flags().StringVar(&cmdConfig.issuer, "iss", "", "Expected issuer, not checked if empty")
+	cmd.Flags().StringSliceVar(&cmdConfig.audience, "aud", nil, "Accepted audiences, the token must have one of them. Not checked if empty.")
+	cmd.Flags().DurationVar(&cmdConfig.leeway, "leeway", josejwt.DefaultLeeway, "Allowed clock skew of the exp, nbf and iat claims")
+	cmd.Flags().StringSliceVar(&cmdConfig.algorithms, "alg", nil, "Allowed signing algorithms, all tribe algorithms are allowed if empty")
+
+	return cmd
+}
+
+func newJwtVerifyKeySource(logger log.Logger, datastoreConfig *config.DatastoreConfig, kmsConfig *kms.Config, cmdConfig *jwtVerifyConfig) (jwk.KeySource, error) {
+	if cmdConfig.jwksURL != "" {
+		return jwk.NewRemoteKeySource(cmdConfig.jwksURL), nil
+	}
+	if cmdConfig.jwksFile != "" {
+		keys, err := readJwksFile(cmdConfig.jwksFile)
+		if err != nil {
+			return nil, err
+		}
+		return jwk.NewStaticKeySource(keys), nil
+	}
+	dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+	if err != nil {
+		return nil, err
+	}
+	kmsProvider, err := kms.NewProvider(logger, kmsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if cmdConfig.oidcJwksID != "" {
+		return jwk.NewOidcJWKSKeySource(dsClient, kmsProvider, cmdConfig.oidcJwksID), nil
+	}
+	record, err := getJwksByID(dsClient, cmdConfig.jwksID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := jwk.DecryptJWKS(kmsProvider, record)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwk.VerificationKey(record.ID, keys)
+	if err != nil {
+		return nil, err
+	}
+	return jwk.NewStaticKeySource(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*key}}), nil
+}
+
+// readJwksFile reads a JWKS or a single JWK, the public keys of the private keys are added
+func readJwksFile(filename string) (*jose.JSONWebKeySet, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "read JWKS file failed")
+	}
+	keys, err := jwk.ParseJSONKeys(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "JWKS file must contain a JWKS or a JWK")
+	}
+	for _, key := range keys.Keys {
+		if jwk.IsPrivate(&key) && len(keys.Key(jwk.BaseKeyID(key.KeyID))) == 0 {
+			public := key.Public()
+			public.KeyID = jwk.BaseKeyID(key.KeyID)
+			keys.Keys = append(keys.Keys, public)
+		}
+	}
+	return keys, nil
+}
+
+func runJwtVerify(keySource jwk.KeySource, cmdConfig *jwtVerifyConfig, token string) (*jwtVerifyResult, error) {
+	options := []jwk.JWTMiddlewareOption{
+		jwk.WithIssuer(cmdConfig.issuer),
+		jwk.WithAudience(cmdConfig.audience...),
+		jwk.WithLeeway(cmdConfig.leeway),
+	}
+	if len(cmdConfig.algorithms) != 0 {
+		algorithms := make([]jose.SignatureAlgorithm, 0, len(cmdConfig.algorithms))
+		for _, alg := range cmdConfig.algorithms {
+			algorithms = append(algorithms, jose.SignatureAlgorithm(alg))
+		}
+		options = append(options, jwk.WithAllowedAlgorithms(algorithms...))
+	}
+	claims, err := jwt.NewKeySourceVerifier(keySource, options...).Verify(context.Background(), token)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := decodeJwt(token)
+	if err != nil {
+		return nil, err
+	}
+	return &jwtVerifyResult{
+		Valid:  true,
+		Header: decoded.Header,
+		Claims: claims.Raw,
+	}, nil
+}
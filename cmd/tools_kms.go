@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var kmsCmd = &cobra.Command{
+	Use:   "kms",
+	Short: "KMS provider tools",
+}
+
+var kmsKeyfileCmd = &cobra.Command{
+	Use:   "keyfile",
+	Short: "Keyfile of the file KMS provider",
+}
+
+func init() {
+	toolsCmd.AddCommand(kmsCmd)
+	kmsCmd.AddCommand(kmsKeyfileCmd)
+}
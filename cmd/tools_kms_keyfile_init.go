@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/kms/filekms"
+	"github.com/grepplabs/tribe/pkg/kms/masterkey"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	kmsKeyfileCmd.AddCommand(newKmsKeyfileInitCmd())
+}
+
+type kmsKeyfileInitConfig struct {
+	force bool
+}
+
+type kmsKeyfileInitResult struct {
+	Path         string `json:"path"`
+	KeyURI       string `json:"key_uri"`
+	PrimaryKeyID uint32 `json:"primary_key_id"`
+}
+
+func newKmsKeyfileInitCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	keyfileConfig := filekms.NewConfig()
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(kmsKeyfileInitConfig)
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create a passphrase encrypted keyfile for the file KMS provider",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("kms-keyfile-init")
+			result, err := runKmsKeyfileInit(logger, keyfileConfig, cmdConfig)
+			if err != nil {
+				log.Errorf("kms keyfile init command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(keyfileConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().BoolVar(&cmdConfig.force, "force", false, "Overwrite an existing keyfile, JWKS encrypted with the previous keyfile cannot be decrypted anymore")
+
+	return cmd
+}
+
+func runKmsKeyfileInit(logger log.Logger, keyfileConfig *filekms.Config, cmdConfig *kmsKeyfileInitConfig) (*kmsKeyfileInitResult, error) {
+	passphrase, err := keyfileConfig.GetPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, errors.New("kms-keyfile-passphrase is required")
+	}
+	kh, err := filekms.CreateKeyfile(keyfileConfig.Path, []byte(passphrase), masterkey.DefaultKDFParams, cmdConfig.force)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("keyfile %s created", keyfileConfig.Path)
+	return &kmsKeyfileInitResult{
+		Path:         keyfileConfig.Path,
+		KeyURI:       filekms.KeyURI(keyfileConfig.Path),
+		PrimaryKeyID: kh.KeysetInfo().PrimaryKeyId,
+	}, nil
+}
@@ -12,6 +12,7 @@ import (
 	dtomodel "github.com/grepplabs/tribe/database/model"
 	"github.com/grepplabs/tribe/pkg/kms/masterkey"
 	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/grepplabs/tribe/pkg/secret"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
@@ -68,7 +69,7 @@ func newMkCreateCmd() *cobra.Command {
 	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
 
 	cmd.Flags().StringVar(&cmdConfig.keysetID, "keyset-id", "", "Identifier of the keyset")
-	cmd.Flags().StringVar(&cmdConfig.masterSecret, "master-secret", "", "Master secret")
+	cmd.Flags().StringVar(&cmdConfig.masterSecret, "master-secret", "", "Master secret or secret reference e.g. file:///path, env://VAR, stdin://, prompt://label, vault://path#field")
 
 	_ = cmd.MarkFlagRequired("master-secret")
 
@@ -76,12 +77,20 @@ func newMkCreateCmd() *cobra.Command {
 }
 
 func runMkCreate(logger log.Logger, datastoreConfig *config.DatastoreConfig, cmdConfig *mkCreateCmdConfig) (*dtomodel.KMSKeyset, error) {
-	id := cmdConfig.keysetID
+	masterSecret, err := secret.Resolve("master-secret", cmdConfig.masterSecret)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve master secret failed")
+	}
+	return createKMSKeyset(logger, datastoreConfig, cmdConfig.keysetID, masterSecret)
+}
+
+func createKMSKeyset(logger log.Logger, datastoreConfig *config.DatastoreConfig, keysetID string, masterSecret string) (*dtomodel.KMSKeyset, error) {
+	id := keysetID
 	if id == "" {
 		id = uuid.NewString()
 	}
 
-	mk, err := masterkey.NewMasterKeyset([]byte(cmdConfig.masterSecret))
+	mk, err := masterkey.NewMasterKeyset([]byte(masterSecret))
 	if err != nil {
 		return nil, errors.Wrap(err, "create master keyset failed")
 	}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"time"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/database/client"
+	"github.com/grepplabs/tribe/pkg/kms/dbkms"
+	"github.com/grepplabs/tribe/pkg/kms/masterkey"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/grepplabs/tribe/pkg/secret"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	mkCmd.AddCommand(newMkInspectCmd())
+}
+
+type mkInspectCmdConfig struct {
+	keysetID     string
+	masterSecret string
+}
+
+type mkInspectKeyInfo struct {
+	KeyID            uint32 `json:"key_id"`
+	Status           string `json:"status"`
+	OutputPrefixType string `json:"output_prefix_type"`
+	TypeURL          string `json:"type_url"`
+	Primary          bool   `json:"primary"`
+}
+
+// mkInspectResult describes the keyset without the key material
+type mkInspectResult struct {
+	ID           string             `json:"id"`
+	CreatedAt    time.Time          `json:"created_at"`
+	Description  string             `json:"description"`
+	Version      int                `json:"version"`
+	Format       int                `json:"format"`
+	PrimaryKeyID uint32             `json:"primary_key_id"`
+	Keys         []mkInspectKeyInfo `json:"keys"`
+	JwksCount    int                `json:"jwks_count"`
+}
+
+func newMkInspectCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(mkInspectCmdConfig)
+
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Decrypt the master key and show the keyset info and the number of JWKS using it",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("mk-inspect")
+			result, err := runMkInspect(logger, datastoreConfig, cmdConfig)
+			if err != nil {
+				log.Errorf("mk inspect command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().StringVar(&cmdConfig.keysetID, "keyset-id", "", "Identifier of the keyset")
+	cmd.Flags().StringVar(&cmdConfig.masterSecret, "master-secret", "", "Master secret or secret reference")
+
+	_ = cmd.MarkFlagRequired("keyset-id")
+	_ = cmd.MarkFlagRequired("master-secret")
+
+	return cmd
+}
+
+func runMkInspect(logger log.Logger, datastoreConfig *config.DatastoreConfig, cmdConfig *mkInspectCmdConfig) (*mkInspectResult, error) {
+	masterSecret, err := secret.Resolve("master-secret", cmdConfig.masterSecret)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve master secret failed")
+	}
+	dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+	if err != nil {
+		return nil, err
+	}
+	keyset, err := dsClient.API().GetKMSKeyset(context.Background(), cmdConfig.keysetID)
+	if err != nil {
+		return nil, err
+	}
+	if keyset == nil {
+		return nil, errors.Errorf("not found keysetID %s", cmdConfig.keysetID)
+	}
+	encryptedKeyset, err := base64.StdEncoding.DecodeString(keyset.EncryptedKeyset)
+	if err != nil {
+		return nil, errors.Wrap(err, "base64 decode of encrypted keyset failed")
+	}
+	mk, err := masterkey.DecryptKeyset(encryptedKeyset, []byte(masterSecret))
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypt master keyset failed")
+	}
+	defer mk.Destroy()
+
+	info := mk.GetKeyset().KeysetInfo()
+	result := &mkInspectResult{
+		ID:           keyset.ID,
+		CreatedAt:    keyset.CreatedAt,
+		Description:  keyset.Description,
+		Version:      keyset.Version,
+		Format:       mk.FormatVersion(),
+		PrimaryKeyID: info.GetPrimaryKeyId(),
+		Keys:         make([]mkInspectKeyInfo, 0, len(info.GetKeyInfo())),
+	}
+	for _, keyInfo := range info.GetKeyInfo() {
+		result.Keys = append(result.Keys, mkInspectKeyInfo{
+			KeyID:            keyInfo.GetKeyId(),
+			Status:           keyInfo.GetStatus().String(),
+			OutputPrefixType: keyInfo.GetOutputPrefixType().String(),
+			TypeURL:          keyInfo.GetTypeUrl(),
+			Primary:          keyInfo.GetKeyId() == info.GetPrimaryKeyId(),
+		})
+	}
+	result.JwksCount, err = countJwksByKeysetID(dsClient, keyset.ID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// countJwksByKeysetID counts the JWKS records encrypted with the db master keyset
+func countJwksByKeysetID(dsClient client.Client, keysetID string) (int, error) {
+	list, err := dsClient.API().ListJWKS(context.Background(), nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	if list == nil {
+		return 0, nil
+	}
+	count := 0
+	for _, jwks := range list.List {
+		id, err := dbkms.KeysetID(jwks.KMSKeyURI)
+		if err != nil {
+			// other kms provider
+			continue
+		}
+		if id == keysetID {
+			count++
+		}
+	}
+	return count, nil
+}
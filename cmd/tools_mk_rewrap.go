@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/database/client"
+	dtomodel "github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/kms/masterkey"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/grepplabs/tribe/pkg/secret"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	mkRewrapStatusRewrapped = "rewrapped"
+	mkRewrapStatusSkipped   = "skipped"
+	mkRewrapStatusFailed    = "failed"
+)
+
+func init() {
+	mkCmd.AddCommand(newMkRewrapCmd())
+}
+
+type mkRewrapCmdConfig struct {
+	keysetID        string
+	all             bool
+	masterSecret    string
+	newMasterSecret string
+}
+
+func (c *mkRewrapCmdConfig) Validate() error {
+	if c.keysetID == "" && !c.all {
+		return errors.New("either keyset-id or all is required")
+	}
+	if c.keysetID != "" && c.all {
+		return errors.New("keyset-id and all are mutually exclusive")
+	}
+	return nil
+}
+
+type mkRewrapResult struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+	Format  int    `json:"format"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+type mkRewrapReport struct {
+	Results  []mkRewrapResult `json:"results"`
+	Failures int              `json:"failures"`
+}
+
+func newMkRewrapCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(mkRewrapCmdConfig)
+
+	cmd := &cobra.Command{
+		Use:   "rewrap",
+		Short: "Re-encrypt master key with a new salt in the current format, optionally with a new master secret",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmdConfig.Validate(); err != nil {
+				return err
+			}
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("mk-rewrap")
+			result, err := runMkRewrap(logger, datastoreConfig, cmdConfig)
+			if err != nil {
+				log.Errorf("mk rewrap command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+			if result.Failures != 0 {
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().StringVar(&cmdConfig.keysetID, "keyset-id", "", "Identifier of the keyset")
+	cmd.Flags().BoolVar(&cmdConfig.all, "all", false, "Upgrade all keysets stored in the legacy format")
+	cmd.Flags().StringVar(&cmdConfig.masterSecret, "master-secret", "", "Master secret or secret reference")
+	cmd.Flags().StringVar(&cmdConfig.newMasterSecret, "new-master-secret", "", "New master secret or secret reference. The master secret is kept if not provided")
+
+	_ = cmd.MarkFlagRequired("master-secret")
+
+	return cmd
+}
+
+func runMkRewrap(logger log.Logger, datastoreConfig *config.DatastoreConfig, cmdConfig *mkRewrapCmdConfig) (*mkRewrapReport, error) {
+	masterSecret, err := secret.Resolve("master-secret", cmdConfig.masterSecret)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve master secret failed")
+	}
+	newMasterSecret := masterSecret
+	if cmdConfig.newMasterSecret != "" {
+		newMasterSecret, err = secret.Resolve("new-master-secret", cmdConfig.newMasterSecret)
+		if err != nil {
+			return nil, errors.Wrap(err, "resolve new master secret failed")
+		}
+	}
+	dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+	if err != nil {
+		return nil, err
+	}
+	var keysets []dtomodel.KMSKeyset
+	if cmdConfig.all {
+		list, err := dsClient.API().ListKMSKeysets(context.Background(), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if list != nil {
+			keysets = list.List
+		}
+	} else {
+		keyset, err := dsClient.API().GetKMSKeyset(context.Background(), cmdConfig.keysetID)
+		if err != nil {
+			return nil, err
+		}
+		if keyset == nil {
+			return nil, errors.Errorf("kms keyset not found: %s", cmdConfig.keysetID)
+		}
+		keysets = append(keysets, *keyset)
+	}
+	// keysets are processed independently, a failure does not stop the remaining keysets
+	report := &mkRewrapReport{Results: make([]mkRewrapResult, 0, len(keysets))}
+	for i := range keysets {
+		keyset := &keysets[i]
+		result := mkRewrapResult{ID: keyset.ID, Version: keyset.Version, Format: kmsKeysetFormat(keyset)}
+		updated, err := rewrapKMSKeyset(dsClient, keyset, masterSecret, newMasterSecret, !cmdConfig.all)
+		switch {
+		case err != nil:
+			logger.Warnf("rewrap kms keyset %s failed: %v", keyset.ID, err)
+			result.Status = mkRewrapStatusFailed
+			result.Error = err.Error()
+			report.Failures++
+		case updated:
+			logger.Infof("kms keyset %s rewrapped", keyset.ID)
+			result.Status = mkRewrapStatusRewrapped
+			result.Version = keyset.Version
+			result.Format = masterkey.FormatCurrent
+		default:
+			result.Status = mkRewrapStatusSkipped
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+func kmsKeysetFormat(keyset *dtomodel.KMSKeyset) int {
+	encryptedKeyset, err := base64.StdEncoding.DecodeString(keyset.EncryptedKeyset)
+	if err != nil {
+		return 0
+	}
+	return masterkey.FormatVersion(encryptedKeyset)
+}
+
+// rewrapKMSKeyset re-encrypts the keyset, when force is false only legacy keysets are updated
+func rewrapKMSKeyset(dsClient client.Client, keyset *dtomodel.KMSKeyset, masterSecret, newMasterSecret string, force bool) (bool, error) {
+	encryptedKeyset, err := base64.StdEncoding.DecodeString(keyset.EncryptedKeyset)
+	if err != nil {
+		return false, errors.Wrap(err, "base64 decode of encrypted keyset failed")
+	}
+	if !force && masterSecret == newMasterSecret && masterkey.FormatVersion(encryptedKeyset) == masterkey.FormatCurrent {
+		return false, nil
+	}
+	mk, err := masterkey.DecryptKeyset(encryptedKeyset, []byte(masterSecret))
+	if err != nil {
+		return false, errors.Wrap(err, "decrypt master keyset failed")
+	}
+	mk, err = masterkey.Rewrap(mk, []byte(newMasterSecret))
+	if err != nil {
+		return false, err
+	}
+	encryptedKeyset, err = mk.EncryptKeyset()
+	if err != nil {
+		return false, errors.Wrap(err, "encrypt master keyset failed")
+	}
+	keyset.EncryptedKeyset = base64.StdEncoding.EncodeToString(encryptedKeyset)
+	keyset.Version = keyset.Version + 1
+	err = dsClient.API().UpdateKMSKeyset(context.Background(), keyset)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
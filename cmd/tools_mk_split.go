@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/grepplabs/tribe/config"
+	dtomodel "github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/kms/shamir"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	mkSplitSecretSize = 32
+)
+
+func init() {
+	mkCmd.AddCommand(newMkSplitCmd())
+}
+
+type mkSplitCmdConfig struct {
+	keysetID  string
+	shares    int
+	threshold int
+	sharesDir string
+	// insecureStdout prints all shares in the output, so whoever reads it holds the master secret
+	insecureStdout bool
+}
+
+func (c *mkSplitCmdConfig) Validate() error {
+	if c.threshold < 2 {
+		return errors.New("threshold must be at least 2")
+	}
+	if c.shares < c.threshold {
+		return errors.New("number of shares cannot be less than threshold")
+	}
+	if c.shares > shamir.MaxShares {
+		return errors.Errorf("number of shares cannot exceed %d", shamir.MaxShares)
+	}
+	if c.sharesDir == "" && !c.insecureStdout {
+		return errors.New("shares-dir is required, every share is written into a separate file for its custodian. Use insecure-stdout to print all shares in the output")
+	}
+	if c.sharesDir != "" && c.insecureStdout {
+		return errors.New("shares-dir and insecure-stdout are mutually exclusive")
+	}
+	return nil
+}
+
+type mkSplitResult struct {
+	Keyset    *dtomodel.KMSKeyset `json:"keyset"`
+	Threshold int                 `json:"threshold"`
+	Shares    []string            `json:"shares,omitempty"`
+	Files     []string            `json:"files,omitempty"`
+}
+
+func newMkSplitCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(mkSplitCmdConfig)
+
+	cmd := &cobra.Command{
+		Use:   "split",
+		Short: "Create master key with a generated master secret split into Shamir shares",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmdConfig.Validate(); err != nil {
+				return err
+			}
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("mk-split")
+			result, err := runMkSplit(logger, datastoreConfig, cmdConfig)
+			if err != nil {
+				log.Errorf("mk split command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().StringVar(&cmdConfig.keysetID, "keyset-id", "", "Identifier of the keyset")
+	cmd.Flags().IntVar(&cmdConfig.shares, "shares", 5, "Number of shares to split the master secret into")
+	cmd.Flags().IntVar(&cmdConfig.threshold, "threshold", 3, "Number of shares required to unseal the master secret")
+	cmd.Flags().StringVar(&cmdConfig.sharesDir, "shares-dir", "", "Directory to write each share into a separate file, which is handed to its custodian")
+	cmd.Flags().BoolVar(&cmdConfig.insecureStdout, "insecure-stdout", false, "Print all shares in the output instead of the shares-dir files. Whoever reads the output holds the master secret, use it for tests only.")
+
+	return cmd
+}
+
+func runMkSplit(logger log.Logger, datastoreConfig *config.DatastoreConfig, cmdConfig *mkSplitCmdConfig) (*mkSplitResult, error) {
+	id := cmdConfig.keysetID
+	if id == "" {
+		id = uuid.NewString()
+	}
+	secret := make([]byte, mkSplitSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, errors.Wrap(err, "generate master secret failed")
+	}
+	masterSecret := base64.StdEncoding.EncodeToString(secret)
+	for i := range secret {
+		secret[i] = 0
+	}
+
+	shares, err := shamir.Split([]byte(masterSecret), cmdConfig.shares, cmdConfig.threshold)
+	if err != nil {
+		return nil, errors.Wrap(err, "split master secret failed")
+	}
+	result := &mkSplitResult{
+		Threshold: cmdConfig.threshold,
+	}
+	// shares are persisted before the keyset, so a failure never leaves a keyset nobody can unseal
+	for i, share := range shares {
+		encoded := base64.StdEncoding.EncodeToString(share)
+		if cmdConfig.insecureStdout {
+			result.Shares = append(result.Shares, encoded)
+			continue
+		}
+		filename := filepath.Join(cmdConfig.sharesDir, fmt.Sprintf("%s.share-%d", id, i+1))
+		if err := ioutil.WriteFile(filename, []byte(encoded+"\n"), 0600); err != nil {
+			return nil, errors.Wrapf(err, "write share file %s failed", filename)
+		}
+		result.Files = append(result.Files, filename)
+	}
+	keyset, err := createKMSKeyset(logger, datastoreConfig, id, masterSecret)
+	if err != nil {
+		return nil, err
+	}
+	result.Keyset = keyset
+	return result, nil
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMkSplitConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config mkSplitCmdConfig
+		hasErr bool
+	}{
+		{name: "shares dir", config: mkSplitCmdConfig{shares: 5, threshold: 3, sharesDir: "/tmp/shares"}},
+		{name: "insecure stdout", config: mkSplitCmdConfig{shares: 5, threshold: 3, insecureStdout: true}},
+		{name: "all shares in the output", config: mkSplitCmdConfig{shares: 5, threshold: 3}, hasErr: true},
+		{name: "shares dir and insecure stdout", config: mkSplitCmdConfig{shares: 5, threshold: 3, sharesDir: "/tmp/shares", insecureStdout: true}, hasErr: true},
+		{name: "threshold too low", config: mkSplitCmdConfig{shares: 5, threshold: 1, sharesDir: "/tmp/shares"}, hasErr: true},
+		{name: "less shares than threshold", config: mkSplitCmdConfig{shares: 2, threshold: 3, sharesDir: "/tmp/shares"}, hasErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.hasErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
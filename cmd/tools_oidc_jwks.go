@@ -1,6 +1,10 @@
 package cmd
 
 import (
+	"time"
+
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -12,3 +16,43 @@ var oidcJwksCmd = &cobra.Command{
 func init() {
 	oidcCmd.AddCommand(oidcJwksCmd)
 }
+
+const (
+	oidcJwksRotationModeManual   = "manual"
+	oidcJwksRotationModePeriodic = "periodic"
+
+	oidcJwksDescriptionMaxLength = 255
+)
+
+// parseRotationMode returns the stored rotation mode of the flag value
+func parseRotationMode(mode string) (int, error) {
+	switch mode {
+	case oidcJwksRotationModeManual:
+		return model.OidcJWKSRotationModeManual, nil
+	case oidcJwksRotationModePeriodic:
+		return model.OidcJWKSRotationModePeriodic, nil
+	default:
+		return 0, errors.Errorf("unsupported rotation mode %s. One of: [manual, periodic]", mode)
+	}
+}
+
+// validateRotationPolicy checks the rotation period, which is stored in seconds
+func validateRotationPolicy(mode int, period time.Duration) error {
+	if period < 0 {
+		return errors.Errorf("rotation period must not be negative, but %v", period)
+	}
+	if period%time.Second != 0 {
+		return errors.Errorf("rotation period must be whole seconds, but %v", period)
+	}
+	if mode == model.OidcJWKSRotationModePeriodic && period == 0 {
+		return errors.New("rotation mode periodic requires the rotation period")
+	}
+	return nil
+}
+
+func validateDescription(description string) error {
+	if len(description) > oidcJwksDescriptionMaxLength {
+		return errors.Errorf("description must not be longer than %d characters", oidcJwksDescriptionMaxLength)
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/grepplabs/tribe/config"
 	"github.com/grepplabs/tribe/database/client"
 	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/kms"
 	"github.com/grepplabs/tribe/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -23,17 +24,29 @@ type oidcJwksCreateConfig struct {
 	currentJwksID string
 	nextJwksID    string
 
-	alg string
+	alg        string
+	keyStorage string
+
+	rotationMode   string
+	rotationPeriod time.Duration
+	description    string
 }
 
 func (c *oidcJwksCreateConfig) Validate() error {
-	return nil
+	mode, err := parseRotationMode(c.rotationMode)
+	if err != nil {
+		return err
+	}
+	if err = validateRotationPolicy(mode, c.rotationPeriod); err != nil {
+		return err
+	}
+	return validateDescription(c.description)
 }
 
 func newOidcJwksCreateCmd() *cobra.Command {
 	logConfig := config.NewLogConfig()
 	datastoreConfig := config.NewDatastoreConfig()
-	kmsConfig := config.NewKMSConfig(datastoreConfig)
+	kmsConfig := kms.NewConfig(datastoreConfig)
 	outputConfig := config.NewOutputConfig()
 	cmdConfig := new(oidcJwksCreateConfig)
 
@@ -74,26 +87,30 @@ func newOidcJwksCreateCmd() *cobra.Command {
 	cmd.Flags().StringVar(&cmdConfig.oidcJwksID, "oidc-jwks-id", "", "Identifier of the oidc jwks")
 	cmd.Flags().StringVar(&cmdConfig.currentJwksID, "current-jwks-id", "", "Current JWKS ID")
 	cmd.Flags().StringVar(&cmdConfig.nextJwksID, "next-jwks-id", "", "Next JWKS ID to use")
-	cmd.Flags().StringVar(&cmdConfig.alg, "alg", "RS256", "The specific asymmetric rfc7518 JWA algorithm to be used to generated the key. One of: [RS256, RS384, RS512, ES256, ES384, ES512, PS256, PS384, PS512]")
+	cmd.Flags().StringVar(&cmdConfig.alg, "alg", "RS256", "The specific asymmetric rfc7518 JWA algorithm to be used to generated the key. One of: [RS256, RS384, RS512, ES256, ES384, ES512, PS256, PS384, PS512, EdDSA, ES256K]")
+	cmd.Flags().StringVar(&cmdConfig.keyStorage, "key-storage", model.JWKSKeyStorageKMS, "Where the private keys of the created JWKS are held. One of: [kms, vault-transit]")
+	cmd.Flags().StringVar(&cmdConfig.rotationMode, "rotation-mode", oidcJwksRotationModeManual, "How the keys are rotated. One of: [manual, periodic]. Periodic sets are rotated by the rotate run worker.")
+	cmd.Flags().DurationVar(&cmdConfig.rotationPeriod, "rotation-period", 0, "Time between the periodic rotations e.g. 720h")
+	cmd.Flags().StringVar(&cmdConfig.description, "description", "", "Description of the oidc jwks")
 
 	return cmd
 }
 
-func runOidcJwksCreate(logger log.Logger, datastoreConfig *config.DatastoreConfig, kmsConfig *config.KMSConfig, cmdConfig *oidcJwksCreateConfig) (interface{}, error) {
+func runOidcJwksCreate(logger log.Logger, datastoreConfig *config.DatastoreConfig, kmsConfig *kms.Config, cmdConfig *oidcJwksCreateConfig) (interface{}, error) {
 	dsClient, err := NewDatastoreClient(logger, datastoreConfig)
 	if err != nil {
 		return nil, err
 	}
-	kmsProvider, err := NewKMSProvider(logger, kmsConfig)
+	kmsProvider, err := kms.NewProvider(logger, kmsConfig)
 	if err != nil {
 		return nil, err
 	}
 	jwksCreate := NewJwksCreateCmd(logger, dsClient, kmsProvider)
-	currentJwksID, err := oidcJwksCreateOrGet(cmdConfig.currentJwksID, cmdConfig.alg, jwksCreate, dsClient)
+	currentJwksID, err := oidcJwksCreateOrGet(cmdConfig.currentJwksID, cmdConfig.alg, cmdConfig.keyStorage, jwksCreate, dsClient)
 	if err != nil {
 		return nil, err
 	}
-	nextJwksID, err := oidcJwksCreateOrGet(cmdConfig.nextJwksID, cmdConfig.alg, jwksCreate, dsClient)
+	nextJwksID, err := oidcJwksCreateOrGet(cmdConfig.nextJwksID, cmdConfig.alg, cmdConfig.keyStorage, jwksCreate, dsClient)
 	if err != nil {
 		return nil, err
 	}
@@ -101,6 +118,10 @@ func runOidcJwksCreate(logger log.Logger, datastoreConfig *config.DatastoreConfi
 		return nil, errors.Errorf("current and next OIDC jwksID must be different: %s", currentJwksID)
 	}
 
+	rotationMode, err := parseRotationMode(cmdConfig.rotationMode)
+	if err != nil {
+		return nil, err
+	}
 	id := cmdConfig.oidcJwksID
 	if id == "" {
 		id = uuid.NewString()
@@ -111,59 +132,83 @@ func runOidcJwksCreate(logger log.Logger, datastoreConfig *config.DatastoreConfi
 		CreatedAt:      now,
 		CurrentJwksID:  currentJwksID,
 		NextJwksID:     nextJwksID,
-		RotationMode:   0,
-		RotationPeriod: 0,
+		RotationMode:   rotationMode,
+		RotationPeriod: int(cmdConfig.rotationPeriod / time.Second),
 		LastRotated:    now,
+		Description:    cmdConfig.description,
 		Version:        0,
 	}
 	err = dsClient.API().CreateOidcJWKS(context.Background(), oidcJWKS)
 	if err != nil {
 		return nil, err
 	}
+	if err = unretireJwks(dsClient, currentJwksID, nextJwksID); err != nil {
+		logger.Warnf("OIDC JWKS %s created, but %v", oidcJWKS.ID, err)
+	}
 	return oidcJWKS, nil
 }
 
-func oidcJwksCreateOrGet(jwksID string, alg string, jwksCreate *jwksCreateCmd, dsClient client.Client) (string, error) {
+func oidcJwksCreateOrGet(jwksID string, alg string, keyStorage string, jwksCreate *jwksCreateCmd, dsClient client.Client) (string, error) {
 	if jwksID == "" {
-		if err := checkAllowedOidcJwks(alg); err != nil {
-			return "", err
-		}
-		jwksID = uuid.NewString()
-		_, err := jwksCreate.Run(&jwksCreateConfig{
-			jwksID: jwksID,
-			alg:    alg,
-			use:    "sig",
-		})
+		jwksConfig, err := newOidcJwksCreateConfig(alg, keyStorage)
 		if err != nil {
 			return "", err
 		}
-		return jwksID, nil
-	} else {
-		key, err := getJwksByID(dsClient, jwksID)
+		_, err = jwksCreate.Run(jwksConfig)
 		if err != nil {
 			return "", err
 		}
-		if key.Use != "sig" {
-			return "", errors.Errorf("OIDC JWKS requires use 'sig', but got '%s'", key.Use)
-		}
-		if err := checkAllowedOidcJwks(key.Alg); err != nil {
-			return "", err
-		}
-		return jwksID, nil
+		return jwksConfig.jwksID, nil
+	} else {
+		return oidcJwksGet(jwksID, dsClient)
+	}
+}
+
+// newOidcJwksCreateConfig returns the validated configuration of a new OIDC signing key
+func newOidcJwksCreateConfig(alg string, keyStorage string) (*jwksCreateConfig, error) {
+	if err := checkAllowedOidcJwks(alg); err != nil {
+		return nil, err
+	}
+	jwksConfig := &jwksCreateConfig{
+		jwksID:     uuid.NewString(),
+		alg:        alg,
+		use:        "sig",
+		keyStorage: keyStorage,
+	}
+	if err := jwksConfig.Validate(); err != nil {
+		return nil, err
+	}
+	return jwksConfig, nil
+}
+
+// oidcJwksGet checks the existing JWKS can be used as OIDC signing key
+func oidcJwksGet(jwksID string, dsClient client.Client) (string, error) {
+	key, err := getJwksByID(dsClient, jwksID)
+	if err != nil {
+		return "", err
+	}
+	if key.Use != "sig" {
+		return "", errors.Errorf("OIDC JWKS requires use 'sig', but got '%s'", key.Use)
+	}
+	if err := checkAllowedOidcJwks(key.Alg); err != nil {
+		return "", err
 	}
+	return jwksID, nil
 }
 
 var (
 	allowedOidcAlgos = map[string]struct{}{
-		"RS256": {},
-		"RS384": {},
-		"RS512": {},
-		"ES256": {},
-		"ES384": {},
-		"ES512": {},
-		"PS256": {},
-		"PS384": {},
-		"PS512": {},
+		"RS256":  {},
+		"RS384":  {},
+		"RS512":  {},
+		"ES256":  {},
+		"ES384":  {},
+		"ES512":  {},
+		"PS256":  {},
+		"PS384":  {},
+		"PS512":  {},
+		"EdDSA":  {},
+		"ES256K": {},
 	}
 )
 
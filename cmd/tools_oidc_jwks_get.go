@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/grepplabs/tribe/config"
 	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/kms"
 	"github.com/grepplabs/tribe/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -26,7 +27,7 @@ func (c *oidcjwksGetConfig) Validate() error {
 func newOidcjwksGetCmd() *cobra.Command {
 	logConfig := config.NewLogConfig()
 	datastoreConfig := config.NewDatastoreConfig()
-	kmsConfig := config.NewKMSConfig(datastoreConfig)
+	kmsConfig := kms.NewConfig(datastoreConfig)
 	outputConfig := config.NewOutputConfig()
 	cmdConfig := new(oidcjwksGetConfig)
 
@@ -70,12 +71,12 @@ func newOidcjwksGetCmd() *cobra.Command {
 	return cmd
 }
 
-func runOidcjwksGet(logger log.Logger, datastoreConfig *config.DatastoreConfig, kmsConfig *config.KMSConfig, cmdConfig *oidcjwksGetConfig) (interface{}, error) {
+func runOidcjwksGet(logger log.Logger, datastoreConfig *config.DatastoreConfig, kmsConfig *kms.Config, cmdConfig *oidcjwksGetConfig) (interface{}, error) {
 	dsClient, err := NewDatastoreClient(logger, datastoreConfig)
 	if err != nil {
 		return nil, err
 	}
-	kmsProvider, err := NewKMSProvider(logger, kmsConfig)
+	kmsProvider, err := kms.NewProvider(logger, kmsConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +103,7 @@ func runOidcjwksGet(logger log.Logger, datastoreConfig *config.DatastoreConfig,
 			return nil, err
 		}
 	}
-	return result, nil
+	return jwk.JSONKeySet{JSONWebKeySet: result}, nil
 }
 func appendPublicKey(jwksID string, jwksGetCmd *jwksCreateGet, result *jose.JSONWebKeySet) error {
 	jsoNWebKeySet, err := jwksGetCmd.Run(&jwksGetConfig{
@@ -111,15 +112,10 @@ func appendPublicKey(jwksID string, jwksGetCmd *jwksCreateGet, result *jose.JSON
 	if err != nil {
 		return err
 	}
-	if len(jsoNWebKeySet.Keys) != 2 {
-		return errors.Errorf("JWKS ID %s should have private and public key: %v", jwksID, len(jsoNWebKeySet.Keys))
-	}
-	if jwk.IsPublic(&jsoNWebKeySet.Keys[0]) && jwk.IsPrivate(&jsoNWebKeySet.Keys[1]) {
-		result.Keys = append(result.Keys, jsoNWebKeySet.Keys[0])
-	} else if jwk.IsPublic(&jsoNWebKeySet.Keys[1]) && jwk.IsPrivate(&jsoNWebKeySet.Keys[0]) {
-		result.Keys = append(result.Keys, jsoNWebKeySet.Keys[1])
-	} else {
-		return errors.Errorf("JWKS ID %s with invalid private and public keys set", jwksID)
+	publicKey, err := jwk.PublicKey(jwksID, jsoNWebKeySet)
+	if err != nil {
+		return err
 	}
+	result.Keys = append(result.Keys, *publicKey)
 	return nil
 }
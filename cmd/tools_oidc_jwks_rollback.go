@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/database/client"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/grepplabs/tribe/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	oidcJwksCmd.AddCommand(newOidcJwksRollbackCmd())
+}
+
+type oidcJwksRollbackConfig struct {
+	oidcJwksID string
+	dryRun     bool
+}
+
+func (c *oidcJwksRollbackConfig) Validate() error {
+	return nil
+}
+
+func newOidcJwksRollbackCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(oidcJwksRollbackConfig)
+
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Roll back the last OIDC JWKS rotation, the previous key becomes current, the current key becomes next and the retired key becomes previous",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmdConfig.Validate(); err != nil {
+				return err
+			}
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("oidc-jwks-rollback")
+			result, err := runOidcJwksRollback(logger, datastoreConfig, cmdConfig)
+			if err != nil {
+				log.Errorf("oidc jwks rollback command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().StringVar(&cmdConfig.oidcJwksID, "oidc-jwks-id", "", "Identifier of the oidc jwks")
+	cmd.Flags().BoolVar(&cmdConfig.dryRun, "dry-run", false, "Print the state before and after the rollback without changing anything")
+
+	_ = cmd.MarkFlagRequired("oidc-jwks-id")
+
+	return cmd
+}
+
+// oidcJwksRollbackResult is the rolled back record and the next key of the rolled back rotation, which is no longer referenced
+type oidcJwksRollbackResult struct {
+	OidcJWKS       model.OidcJWKS `json:"oidc_jwks"`
+	OrphanedJwksID string         `json:"orphaned_jwks_id"`
+}
+
+// oidcJwksRollbackPlan is the dry run result of the rollback
+type oidcJwksRollbackPlan struct {
+	Before         model.OidcJWKS `json:"before"`
+	After          model.OidcJWKS `json:"after"`
+	OrphanedJwksID string         `json:"orphaned_jwks_id"`
+}
+
+func runOidcJwksRollback(logger log.Logger, datastoreConfig *config.DatastoreConfig, cmdConfig *oidcJwksRollbackConfig) (interface{}, error) {
+	dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+	if err != nil {
+		return nil, err
+	}
+	return rollbackOidcJwks(logger, dsClient, cmdConfig)
+}
+
+func rollbackOidcJwks(logger log.Logger, dsClient client.Client, cmdConfig *oidcJwksRollbackConfig) (interface{}, error) {
+	if !cmdConfig.dryRun {
+		unlock, err := lockOidcJwks(dsClient, cmdConfig.oidcJwksID)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := unlock(context.Background()); err != nil {
+				logger.Warnf("unlock OIDC JWKS %s failed: %v", cmdConfig.oidcJwksID, err)
+			}
+		}()
+	}
+	record, err := dsClient.API().GetOidcJWKS(context.Background(), cmdConfig.oidcJwksID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, errors.Errorf("OIDC jwksID not found: %s", cmdConfig.oidcJwksID)
+	}
+	after, err := rollbackJwksIDs(dsClient, record)
+	if err != nil {
+		return nil, err
+	}
+	if cmdConfig.dryRun {
+		return &oidcJwksRollbackPlan{
+			Before:         *record,
+			After:          *after,
+			OrphanedJwksID: record.NextJwksID,
+		}, nil
+	}
+	err = dsClient.API().UpdateOidcJWKS(context.Background(), after)
+	if err != nil {
+		return nil, err
+	}
+	if err = retireJwks(dsClient, record, after, time.Now()); err != nil {
+		logger.Warnf("OIDC JWKS %s rolled back, but %v", record.ID, err)
+	}
+	return &oidcJwksRollbackResult{
+		OidcJWKS:       *after,
+		OrphanedJwksID: record.NextJwksID,
+	}, nil
+}
+
+// lockOidcJwks acquires the rotation lock of the set, so the set is not rotated by the rotation worker at the same time.
+// The datastores without locks are not used by the rotation worker, there the version check of the update is sufficient.
+func lockOidcJwks(dsClient client.Client, oidcJwksID string) (client.Unlock, error) {
+	locker, ok := dsClient.(client.Locker)
+	if !ok {
+		return func(context.Context) error { return nil }, nil
+	}
+	unlock, locked, err := locker.TryLock(context.Background(), oidcJwksLockName(oidcJwksID))
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, errors.Errorf("OIDC jwksID %s is being rotated, retry later", oidcJwksID)
+	}
+	return unlock, nil
+}
+
+// rollbackJwksIDs reverts the shift retired<-previous<-current<-next of the rotation. The key generated as next
+// by the rotation is no longer referenced.
+func rollbackJwksIDs(dsClient client.Client, record *model.OidcJWKS) (*model.OidcJWKS, error) {
+	previousJwksID := utils.StringValue(record.PreviousJwksID)
+	if previousJwksID == "" {
+		return nil, errors.Errorf("OIDC jwksID %s has no previous key, the last rotation was a revoke or there was no rotation", record.ID)
+	}
+	if _, err := oidcJwksGet(previousJwksID, dsClient); err != nil {
+		return nil, errors.Wrapf(err, "previous key %s cannot be restored", previousJwksID)
+	}
+	if retiredJwksID := utils.StringValue(record.RetiredJwksID); retiredJwksID != "" {
+		if _, err := oidcJwksGet(retiredJwksID, dsClient); err != nil {
+			return nil, errors.Wrapf(err, "retired key %s cannot be restored", retiredJwksID)
+		}
+	}
+	after := *record
+	after.CurrentJwksID = previousJwksID
+	after.NextJwksID = record.CurrentJwksID
+	after.PreviousJwksID = record.RetiredJwksID
+	after.RetiredJwksID = nil
+	after.Version = record.Version + 1
+	return &after, nil
+}
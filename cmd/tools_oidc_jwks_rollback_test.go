@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/grepplabs/tribe/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOidcJwksRollback(t *testing.T) {
+	api := newTestAPI()
+	api.addOidcJwks("oidc", model.OidcJWKSRotationModeManual, 0, time.Now()).PreviousJwksID = utils.String("oidc-previous")
+	api.jwks["oidc-previous"] = &model.JWKS{ID: "oidc-previous", Kid: "oidc-previous", Alg: "ES256", Use: "sig", KeyStorage: model.JWKSKeyStorageKMS}
+	record, _ := api.GetOidcJWKS(context.Background(), "oidc")
+
+	dsClient := &testLockingClient{testClient: testClient{api: api}, held: map[string]bool{}}
+	jwksCreate := NewJwksCreateCmd(log.NewDefaultLogger(), dsClient, newTestProvider(t))
+	rotateConfig := &oidcJwksRotateConfig{oidcJwksID: "oidc", alg: "ES256", keyStorage: model.JWKSKeyStorageKMS}
+	rotated, err := rotateOidcJwks(jwksCreate, dsClient, rotateConfig, record)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "oidc-previous", utils.StringValue(rotated.RetiredJwksID))
+	generatedJwksID := rotated.NextJwksID
+
+	rollbackConfig := &oidcJwksRollbackConfig{oidcJwksID: "oidc", dryRun: true}
+	result, err := rollbackOidcJwks(log.NewDefaultLogger(), dsClient, rollbackConfig)
+	if !assert.NoError(t, err) {
+		return
+	}
+	plan, ok := result.(*oidcJwksRollbackPlan)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, generatedJwksID, plan.OrphanedJwksID)
+	assert.Equal(t, "oidc-previous", utils.StringValue(plan.After.PreviousJwksID))
+	stored, _ := api.GetOidcJWKS(context.Background(), "oidc")
+	assert.Equal(t, rotated.Version, stored.Version)
+
+	// the rotation worker holds the lock
+	dsClient.held[oidcJwksLockName("oidc")] = true
+	rollbackConfig.dryRun = false
+	_, err = rollbackOidcJwks(log.NewDefaultLogger(), dsClient, rollbackConfig)
+	assert.Error(t, err)
+	delete(dsClient.held, oidcJwksLockName("oidc"))
+
+	result, err = rollbackOidcJwks(log.NewDefaultLogger(), dsClient, rollbackConfig)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rolledBack, ok := result.(*oidcJwksRollbackResult)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, generatedJwksID, rolledBack.OrphanedJwksID)
+	orphaned, _ := api.GetJWKS(context.Background(), generatedJwksID)
+	if assert.NotNil(t, orphaned) {
+		assert.NotNil(t, orphaned.RetiredAt)
+	}
+	stored, _ = api.GetOidcJWKS(context.Background(), "oidc")
+	assert.Equal(t, "oidc-current", stored.CurrentJwksID)
+	assert.Equal(t, "oidc-next", stored.NextJwksID)
+	assert.Equal(t, "oidc-previous", utils.StringValue(stored.PreviousJwksID))
+	assert.Nil(t, stored.RetiredJwksID)
+	assert.Equal(t, rotated.Version+1, stored.Version)
+	assert.Empty(t, dsClient.held)
+
+	// the first rollback restored no retired key, so only one more rollback is possible
+	_, err = rollbackOidcJwks(log.NewDefaultLogger(), dsClient, rollbackConfig)
+	assert.NoError(t, err)
+	stored, _ = api.GetOidcJWKS(context.Background(), "oidc")
+	assert.Nil(t, stored.PreviousJwksID)
+	_, err = rollbackOidcJwks(log.NewDefaultLogger(), dsClient, rollbackConfig)
+	assert.Error(t, err)
+}
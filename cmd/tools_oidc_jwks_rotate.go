@@ -5,6 +5,7 @@ import (
 	"github.com/grepplabs/tribe/config"
 	"github.com/grepplabs/tribe/database/client"
 	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/kms"
 	"github.com/grepplabs/tribe/pkg/log"
 	"github.com/grepplabs/tribe/pkg/utils"
 	"github.com/pkg/errors"
@@ -22,11 +23,31 @@ type oidcJwksRotateConfig struct {
 
 	nextJwksID string
 	alg        string
+	keyStorage string
 
 	currentJwksID string
 	revoke        bool
+	dryRun        bool
 }
 
+// oidcJwksPlannedKey is the JWKS which would be generated by the rotation
+type oidcJwksPlannedKey struct {
+	ID         string `json:"id"`
+	Alg        string `json:"alg"`
+	Use        string `json:"use"`
+	KeyStorage string `json:"key_storage"`
+}
+
+// oidcJwksRotatePlan is the dry run result of the rotation
+type oidcJwksRotatePlan struct {
+	Before        model.OidcJWKS       `json:"before"`
+	After         model.OidcJWKS       `json:"after"`
+	GeneratedKeys []oidcJwksPlannedKey `json:"generated_keys"`
+}
+
+// jwksIDResolver returns the given JWKS ID or the ID of the new JWKS if the ID is empty
+type jwksIDResolver func(jwksID string) (string, error)
+
 func (c *oidcJwksRotateConfig) Validate() error {
 	return nil
 }
@@ -34,7 +55,7 @@ func (c *oidcJwksRotateConfig) Validate() error {
 func newOidcJwksRotateCmd() *cobra.Command {
 	logConfig := config.NewLogConfig()
 	datastoreConfig := config.NewDatastoreConfig()
-	kmsConfig := config.NewKMSConfig(datastoreConfig)
+	kmsConfig := kms.NewConfig(datastoreConfig)
 	outputConfig := config.NewOutputConfig()
 	cmdConfig := new(oidcJwksRotateConfig)
 
@@ -73,25 +94,43 @@ func newOidcJwksRotateCmd() *cobra.Command {
 	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
 
 	cmd.Flags().BoolVar(&cmdConfig.revoke, "revoke", false, "Rotate the currently used key")
+	cmd.Flags().BoolVar(&cmdConfig.dryRun, "dry-run", false, "Print the state before and after the rotation and the keys which would be generated without changing anything")
 	cmd.Flags().StringVar(&cmdConfig.oidcJwksID, "oidc-jwks-id", "", "Identifier of the oidc jwks")
 	cmd.Flags().StringVar(&cmdConfig.nextJwksID, "next-jwks-id", "", "Next JWKS ID to use")
 	cmd.Flags().StringVar(&cmdConfig.currentJwksID, "current-jwks-id", "", "Current JWKS ID used with revoke option")
-	cmd.Flags().StringVar(&cmdConfig.alg, "alg", "RS256", "The specific asymmetric rfc7518 JWA algorithm to be used to generated the key. One of: [RS256, RS384, RS512, ES256, ES384, ES512, PS256, PS384, PS512]")
+	cmd.Flags().StringVar(&cmdConfig.alg, "alg", "RS256", "The specific asymmetric rfc7518 JWA algorithm to be used to generated the key. One of: [RS256, RS384, RS512, ES256, ES384, ES512, PS256, PS384, PS512, EdDSA, ES256K]")
+	cmd.Flags().StringVar(&cmdConfig.keyStorage, "key-storage", model.JWKSKeyStorageKMS, "Where the private keys of the created JWKS are held. One of: [kms, vault-transit]")
 
 	_ = cmd.MarkFlagRequired("oidc-jwks-id")
 
 	return cmd
 }
 
-func runOidcJwksRotate(logger log.Logger, datastoreConfig *config.DatastoreConfig, kmsConfig *config.KMSConfig, cmdConfig *oidcJwksRotateConfig) (interface{}, error) {
+func runOidcJwksRotate(logger log.Logger, datastoreConfig *config.DatastoreConfig, kmsConfig *kms.Config, cmdConfig *oidcJwksRotateConfig) (interface{}, error) {
 	dsClient, err := NewDatastoreClient(logger, datastoreConfig)
 	if err != nil {
 		return nil, err
 	}
-	kmsProvider, err := NewKMSProvider(logger, kmsConfig)
+	kmsProvider, err := kms.NewProvider(logger, kmsConfig)
 	if err != nil {
 		return nil, err
 	}
+	return rotateOidcJwksManually(logger, dsClient, kmsProvider, cmdConfig)
+}
+
+// rotateOidcJwksManually rotates the set under the rotation lock, so the rotation worker does not rotate it at the same time
+func rotateOidcJwksManually(logger log.Logger, dsClient client.Client, kmsProvider kms.Provider, cmdConfig *oidcJwksRotateConfig) (interface{}, error) {
+	if !cmdConfig.dryRun {
+		unlock, err := lockOidcJwks(dsClient, cmdConfig.oidcJwksID)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := unlock(context.Background()); err != nil {
+				logger.Warnf("unlock OIDC JWKS %s failed: %v", cmdConfig.oidcJwksID, err)
+			}
+		}()
+	}
 	record, err := dsClient.API().GetOidcJWKS(context.Background(), cmdConfig.oidcJwksID)
 	if err != nil {
 		return nil, err
@@ -99,16 +138,75 @@ func runOidcJwksRotate(logger log.Logger, datastoreConfig *config.DatastoreConfi
 	if record == nil {
 		return nil, errors.Errorf("OIDC jwksID not found: %s", cmdConfig.oidcJwksID)
 	}
-	err = validateRotateJwksIDs(cmdConfig, record)
+	if cmdConfig.dryRun {
+		return planOidcJwksRotate(dsClient, cmdConfig, record)
+	}
+	jwksCreate := NewJwksCreateCmd(logger, dsClient, kmsProvider)
+	return rotateOidcJwks(jwksCreate, dsClient, cmdConfig, record)
+}
+
+// planOidcJwksRotate returns the rotation result without generating keys and storing the record
+func planOidcJwksRotate(dsClient client.Client, cmdConfig *oidcJwksRotateConfig, record *model.OidcJWKS) (*oidcJwksRotatePlan, error) {
+	err := validateRotateJwksIDs(cmdConfig, record)
 	if err != nil {
 		return nil, err
 	}
-	jwksCreate := NewJwksCreateCmd(logger, dsClient, kmsProvider)
-	nextJwksID, currentJwksID, previousJwksID, err := rotateJwksIDs(jwksCreate, dsClient, cmdConfig, record)
+	plan := &oidcJwksRotatePlan{
+		Before:        *record,
+		GeneratedKeys: make([]oidcJwksPlannedKey, 0),
+	}
+	resolve := func(jwksID string) (string, error) {
+		if jwksID != "" {
+			return oidcJwksGet(jwksID, dsClient)
+		}
+		jwksConfig, err := newOidcJwksCreateConfig(cmdConfig.alg, cmdConfig.keyStorage)
+		if err != nil {
+			return "", err
+		}
+		plan.GeneratedKeys = append(plan.GeneratedKeys, oidcJwksPlannedKey{
+			ID:         jwksConfig.jwksID,
+			Alg:        jwksConfig.alg,
+			Use:        jwksConfig.use,
+			KeyStorage: jwksConfig.keyStorage,
+		})
+		return jwksConfig.jwksID, nil
+	}
+	nextJwksID, currentJwksID, previousJwksID, err := rotateJwksIDs(resolve, cmdConfig, record)
 	if err != nil {
 		return nil, err
 	}
+	plan.After = *record
+	plan.After.RetiredJwksID = retiredJwksID(cmdConfig, record)
+	plan.After.CurrentJwksID = currentJwksID
+	plan.After.NextJwksID = nextJwksID
+	plan.After.PreviousJwksID = previousJwksID
+	plan.After.LastRotated = time.Now()
+	plan.After.Version = record.Version + 1
+	return plan, nil
+}
 
+// rotateOidcJwks rotates the jwks ids of the record and stores it. The JWKS generated by a failed rotation are deleted.
+func rotateOidcJwks(jwksCreate *jwksCreateCmd, dsClient client.Client, cmdConfig *oidcJwksRotateConfig, record *model.OidcJWKS) (*model.OidcJWKS, error) {
+	err := validateRotateJwksIDs(cmdConfig, record)
+	if err != nil {
+		return nil, err
+	}
+	var generatedJwksIDs []string
+	resolve := func(jwksID string) (string, error) {
+		resolved, err := oidcJwksCreateOrGet(jwksID, cmdConfig.alg, cmdConfig.keyStorage, jwksCreate, dsClient)
+		if err == nil && jwksID == "" {
+			generatedJwksIDs = append(generatedJwksIDs, resolved)
+		}
+		return resolved, err
+	}
+	nextJwksID, currentJwksID, previousJwksID, err := rotateJwksIDs(resolve, cmdConfig, record)
+	if err != nil {
+		deleteGeneratedJwks(jwksCreate, record.ID, generatedJwksIDs)
+		return nil, err
+	}
+
+	before := *record
+	record.RetiredJwksID = retiredJwksID(cmdConfig, record)
 	record.CurrentJwksID = currentJwksID
 	record.NextJwksID = nextJwksID
 	record.PreviousJwksID = previousJwksID
@@ -117,37 +215,129 @@ func runOidcJwksRotate(logger log.Logger, datastoreConfig *config.DatastoreConfi
 
 	err = dsClient.API().UpdateOidcJWKS(context.Background(), record)
 	if err != nil {
+		deleteGeneratedJwks(jwksCreate, record.ID, generatedJwksIDs)
 		return nil, err
 	}
+	if err = retireJwks(dsClient, &before, record, record.LastRotated); err != nil {
+		jwksCreate.logger.Warnf("OIDC JWKS %s rotated, but %v", record.ID, err)
+	}
 	return record, nil
 }
 
+// deleteGeneratedJwks deletes the JWKS and the KMS keys generated by a rotation which was not stored
+func deleteGeneratedJwks(jwksCreate *jwksCreateCmd, oidcJwksID string, jwksIDs []string) {
+	for _, jwksID := range jwksIDs {
+		jwks, err := jwksCreate.dsClient.API().GetJWKS(context.Background(), jwksID)
+		if err == nil && jwks != nil {
+			err = deleteJwks(jwksCreate.logger, jwksCreate.dsClient, jwksCreate.kmsProvider, jwks, true)
+		}
+		if err != nil {
+			jwksCreate.logger.Warnf("OIDC JWKS %s was not rotated, delete of the generated JWKS %s failed: %v", oidcJwksID, jwksID, err)
+		}
+	}
+}
+
+// oidcJwksReferencedIDs returns the IDs of the next, current, previous and retired JWKS of the record
+func oidcJwksReferencedIDs(record *model.OidcJWKS) []string {
+	ids := []string{record.NextJwksID, record.CurrentJwksID}
+	for _, id := range []*string{record.PreviousJwksID, record.RetiredJwksID} {
+		if jwksID := utils.StringValue(id); jwksID != "" {
+			ids = append(ids, jwksID)
+		}
+	}
+	return ids
+}
+
+// retireJwks sets the retired time of the JWKS which are no longer referenced by the record, jwks gc measures the grace period from it.
+// The retired time of the JWKS which are referenced again e.g. by a rollback is cleared.
+func retireJwks(dsClient client.Client, before *model.OidcJWKS, after *model.OidcJWKS, retiredAt time.Time) error {
+	referenced := make(map[string]struct{})
+	for _, jwksID := range oidcJwksReferencedIDs(after) {
+		referenced[jwksID] = struct{}{}
+	}
+	referencedBefore := make(map[string]struct{})
+	for _, jwksID := range oidcJwksReferencedIDs(before) {
+		referencedBefore[jwksID] = struct{}{}
+	}
+	for _, jwksID := range oidcJwksReferencedIDs(after) {
+		if _, ok := referencedBefore[jwksID]; ok {
+			continue
+		}
+		if err := unretireJwks(dsClient, jwksID); err != nil {
+			return err
+		}
+	}
+	for _, jwksID := range oidcJwksReferencedIDs(before) {
+		if _, ok := referenced[jwksID]; ok {
+			continue
+		}
+		jwks, err := dsClient.API().GetJWKS(context.Background(), jwksID)
+		if err != nil {
+			return errors.Wrapf(err, "retire JWKS %s failed", jwksID)
+		}
+		if jwks == nil {
+			continue
+		}
+		jwks.RetiredAt = &retiredAt
+		if err = dsClient.API().UpdateJWKS(context.Background(), jwks); err != nil {
+			return errors.Wrapf(err, "retire JWKS %s failed", jwksID)
+		}
+	}
+	return nil
+}
+
+// unretireJwks clears the retired time of the referenced JWKS, so jwks gc does not count the grace period from an earlier retirement
+func unretireJwks(dsClient client.Client, jwksIDs ...string) error {
+	for _, jwksID := range jwksIDs {
+		jwks, err := dsClient.API().GetJWKS(context.Background(), jwksID)
+		if err != nil {
+			return errors.Wrapf(err, "unretire JWKS %s failed", jwksID)
+		}
+		if jwks == nil || jwks.RetiredAt == nil {
+			continue
+		}
+		jwks.RetiredAt = nil
+		if err = dsClient.API().UpdateJWKS(context.Background(), jwks); err != nil {
+			return errors.Wrapf(err, "unretire JWKS %s failed", jwksID)
+		}
+	}
+	return nil
+}
+
 func validateRotateJwksIDs(cmdConfig *oidcJwksRotateConfig, record *model.OidcJWKS) error {
 	if cmdConfig.nextJwksID != "" && cmdConfig.currentJwksID == cmdConfig.nextJwksID {
 		return errors.Errorf("OIDC jwksID must be different: next %s , current %s", cmdConfig.nextJwksID, cmdConfig.currentJwksID)
 	}
 	if cmdConfig.nextJwksID != "" && (cmdConfig.nextJwksID == record.NextJwksID || cmdConfig.nextJwksID == record.CurrentJwksID || cmdConfig.nextJwksID == utils.StringValue(record.PreviousJwksID)) {
-		return errors.Errorf("Next OIDC jwksID must be different from stored keys : next %s , current %s, previous %s", cmdConfig.nextJwksID, record.NextJwksID, record.CurrentJwksID, utils.StringValue(record.PreviousJwksID))
+		return errors.Errorf("Next OIDC jwksID %s must be different from stored keys : next %s , current %s, previous %s", cmdConfig.nextJwksID, record.NextJwksID, record.CurrentJwksID, utils.StringValue(record.PreviousJwksID))
 	}
 	if cmdConfig.currentJwksID != "" && (cmdConfig.currentJwksID == record.NextJwksID || cmdConfig.currentJwksID == record.CurrentJwksID || cmdConfig.currentJwksID == utils.StringValue(record.PreviousJwksID)) {
-		return errors.Errorf("Current OIDC jwksID must be different from stored keys : next %s , current %s, previous %s", cmdConfig.currentJwksID, record.NextJwksID, record.CurrentJwksID, utils.StringValue(record.PreviousJwksID))
+		return errors.Errorf("Current OIDC jwksID %s must be different from stored keys : next %s , current %s, previous %s", cmdConfig.currentJwksID, record.NextJwksID, record.CurrentJwksID, utils.StringValue(record.PreviousJwksID))
 	}
 	return nil
 }
 
-func rotateJwksIDs(jwksCreate *jwksCreateCmd, dsClient client.Client, cmdConfig *oidcJwksRotateConfig, record *model.OidcJWKS) (string, string, *string, error) {
+// retiredJwksID returns the previous key dropped by the rotation, it is kept so that the rotation can be rolled back
+func retiredJwksID(cmdConfig *oidcJwksRotateConfig, record *model.OidcJWKS) *string {
+	if cmdConfig.revoke {
+		return nil
+	}
+	return record.PreviousJwksID
+}
+
+func rotateJwksIDs(resolve jwksIDResolver, cmdConfig *oidcJwksRotateConfig, record *model.OidcJWKS) (string, string, *string, error) {
 	if cmdConfig.revoke {
-		currentJwksID, err := oidcJwksCreateOrGet(cmdConfig.currentJwksID, cmdConfig.alg, jwksCreate, dsClient)
+		currentJwksID, err := resolve(cmdConfig.currentJwksID)
 		if err != nil {
 			return "", "", nil, err
 		}
-		nextJwksID, err := oidcJwksCreateOrGet(cmdConfig.nextJwksID, cmdConfig.alg, jwksCreate, dsClient)
+		nextJwksID, err := resolve(cmdConfig.nextJwksID)
 		if err != nil {
 			return "", "", nil, err
 		}
 		return nextJwksID, currentJwksID, nil, nil
 	} else {
-		nextJwksID, err := oidcJwksCreateOrGet(cmdConfig.nextJwksID, cmdConfig.alg, jwksCreate, dsClient)
+		nextJwksID, err := resolve(cmdConfig.nextJwksID)
 		if err != nil {
 			return "", "", nil, err
 		}
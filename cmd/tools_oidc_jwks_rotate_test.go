@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/grepplabs/tribe/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOidcJwksRotateManually(t *testing.T) {
+	api := newTestAPI()
+	api.addOidcJwks("oidc", model.OidcJWKSRotationModePeriodic, 60, time.Now().Add(-time.Hour))
+
+	dsClient := &testLockingClient{testClient: testClient{api: api}, held: map[string]bool{}}
+	rotateConfig := &oidcJwksRotateConfig{oidcJwksID: "oidc", alg: "ES256", keyStorage: model.JWKSKeyStorageKMS, dryRun: true}
+
+	// the rotation worker holds the lock, the dry run does not need it
+	dsClient.held[oidcJwksLockName("oidc")] = true
+	result, err := rotateOidcJwksManually(log.NewDefaultLogger(), dsClient, newTestProvider(t), rotateConfig)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, ok := result.(*oidcJwksRotatePlan)
+	assert.True(t, ok)
+
+	rotateConfig.dryRun = false
+	_, err = rotateOidcJwksManually(log.NewDefaultLogger(), dsClient, newTestProvider(t), rotateConfig)
+	assert.Error(t, err)
+	stored, _ := api.GetOidcJWKS(context.Background(), "oidc")
+	assert.Equal(t, 1, stored.Version)
+	assert.Equal(t, 2, len(api.jwks))
+	delete(dsClient.held, oidcJwksLockName("oidc"))
+
+	result, err = rotateOidcJwksManually(log.NewDefaultLogger(), dsClient, newTestProvider(t), rotateConfig)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rotated, ok := result.(*model.OidcJWKS)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, 2, rotated.Version)
+	assert.Equal(t, "oidc-next", rotated.CurrentJwksID)
+	assert.Empty(t, dsClient.held)
+}
+
+func TestOidcJwksRotateConflictDeletesGeneratedJwks(t *testing.T) {
+	api := newTestAPI()
+	api.addOidcJwks("oidc", model.OidcJWKSRotationModeManual, 0, time.Now())
+	record, _ := api.GetOidcJWKS(context.Background(), "oidc")
+	// another rotation stored the set after it was read
+	api.oidcJwks["oidc"].Version = 2
+
+	provider := newTestProvider(t)
+	dsClient := &testClient{api: api}
+	jwksCreate := NewJwksCreateCmd(log.NewDefaultLogger(), dsClient, provider)
+	rotateConfig := &oidcJwksRotateConfig{oidcJwksID: "oidc", alg: "ES256", keyStorage: model.JWKSKeyStorageKMS, revoke: true}
+	_, err := rotateOidcJwks(jwksCreate, dsClient, rotateConfig, record)
+	assert.Error(t, err)
+
+	// both keys generated by the revoke are deleted with their KMS keys
+	assert.Equal(t, 2, len(api.jwks))
+	assert.NotNil(t, api.jwks["oidc-current"])
+	assert.NotNil(t, api.jwks["oidc-next"])
+	assert.Equal(t, 2, len(provider.deletedKeys))
+	stored, _ := api.GetOidcJWKS(context.Background(), "oidc")
+	assert.Equal(t, "oidc-current", stored.CurrentJwksID)
+	assert.Equal(t, "oidc-next", stored.NextJwksID)
+}
+
+func TestOidcJwksRotateUnretiresJwks(t *testing.T) {
+	retiredAt := time.Now().Add(-48 * time.Hour)
+	api := newTestAPI()
+	api.addOidcJwks("oidc", model.OidcJWKSRotationModeManual, 0, time.Now()).PreviousJwksID = utils.String("oidc-previous")
+	api.jwks["oidc-previous"] = &model.JWKS{ID: "oidc-previous", Kid: "oidc-previous", Alg: "ES256", Use: "sig", KeyStorage: model.JWKSKeyStorageKMS}
+	api.jwks["reused"] = &model.JWKS{ID: "reused", Kid: "reused", Alg: "ES256", Use: "sig", KeyStorage: model.JWKSKeyStorageKMS, RetiredAt: &retiredAt}
+	record, _ := api.GetOidcJWKS(context.Background(), "oidc")
+
+	dsClient := &testClient{api: api}
+	jwksCreate := NewJwksCreateCmd(log.NewDefaultLogger(), dsClient, newTestProvider(t))
+	rotateConfig := &oidcJwksRotateConfig{oidcJwksID: "oidc", nextJwksID: "reused", revoke: true, alg: "ES256", keyStorage: model.JWKSKeyStorageKMS}
+	rotated, err := rotateOidcJwks(jwksCreate, dsClient, rotateConfig, record)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "reused", rotated.NextJwksID)
+	reused, _ := api.GetJWKS(context.Background(), "reused")
+	assert.Nil(t, reused.RetiredAt)
+	for _, id := range []string{"oidc-current", "oidc-next", "oidc-previous"} {
+		dropped, _ := api.GetJWKS(context.Background(), id)
+		assert.NotNil(t, dropped.RetiredAt, id)
+	}
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	oidcJwksCmd.AddCommand(newOidcJwksUpdateCmd())
+}
+
+type oidcJwksUpdateConfig struct {
+	oidcJwksID string
+	version    int
+
+	rotationMode   string
+	rotationPeriod time.Duration
+	description    string
+
+	// set flags, only the set fields are updated
+	versionSet        bool
+	rotationModeSet   bool
+	rotationPeriodSet bool
+	descriptionSet    bool
+}
+
+func (c *oidcJwksUpdateConfig) Validate() error {
+	if !c.rotationModeSet && !c.rotationPeriodSet && !c.descriptionSet {
+		return errors.New("at least one of rotation-mode, rotation-period or description is required")
+	}
+	if c.rotationModeSet {
+		if _, err := parseRotationMode(c.rotationMode); err != nil {
+			return err
+		}
+	}
+	if c.rotationPeriodSet {
+		// the periodic mode is checked against the stored period on update
+		if err := validateRotationPolicy(model.OidcJWKSRotationModeManual, c.rotationPeriod); err != nil {
+			return err
+		}
+	}
+	return validateDescription(c.description)
+}
+
+func newOidcJwksUpdateCmd() *cobra.Command {
+	logConfig := config.NewLogConfig()
+	datastoreConfig := config.NewDatastoreConfig()
+	outputConfig := config.NewOutputConfig()
+	cmdConfig := new(oidcJwksUpdateConfig)
+
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update the rotation policy and description of the OIDC JWKS",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			cmdConfig.versionSet = cmd.Flags().Changed("version")
+			cmdConfig.rotationModeSet = cmd.Flags().Changed("rotation-mode")
+			cmdConfig.rotationPeriodSet = cmd.Flags().Changed("rotation-period")
+			cmdConfig.descriptionSet = cmd.Flags().Changed("description")
+			if err := cmdConfig.Validate(); err != nil {
+				return err
+			}
+			if err := outputConfig.Validate(); err != nil {
+				return err
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			producer := outputConfig.MustGetProducer()
+
+			logger := log.NewLogger(logConfig.Configuration).WithName("oidc-jwks-update")
+			result, err := runOidcJwksUpdate(logger, datastoreConfig, cmdConfig)
+			if err != nil {
+				log.Errorf("oidc jwks update command failed: %v", err)
+				os.Exit(1)
+			}
+			err = producer.Produce(os.Stdout, result)
+			if err != nil {
+				log.Errorf("failed to write result: %v", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().AddFlagSet(logConfig.FlagSet())
+	cmd.Flags().AddFlagSet(datastoreConfig.FlagSet())
+	cmd.Flags().AddFlagSet(outputConfig.FlagSet())
+
+	cmd.Flags().StringVar(&cmdConfig.oidcJwksID, "oidc-jwks-id", "", "Identifier of the oidc jwks")
+	cmd.Flags().IntVar(&cmdConfig.version, "version", 0, "Expected version of the oidc jwks, the update fails if the stored version differs")
+	cmd.Flags().StringVar(&cmdConfig.rotationMode, "rotation-mode", oidcJwksRotationModeManual, "How the keys are rotated. One of: [manual, periodic]. Periodic sets are rotated by the rotate run worker.")
+	cmd.Flags().DurationVar(&cmdConfig.rotationPeriod, "rotation-period", 0, "Time between the periodic rotations e.g. 720h")
+	cmd.Flags().StringVar(&cmdConfig.description, "description", "", "Description of the oidc jwks")
+
+	_ = cmd.MarkFlagRequired("oidc-jwks-id")
+
+	return cmd
+}
+
+func runOidcJwksUpdate(logger log.Logger, datastoreConfig *config.DatastoreConfig, cmdConfig *oidcJwksUpdateConfig) (interface{}, error) {
+	dsClient, err := NewDatastoreClient(logger, datastoreConfig)
+	if err != nil {
+		return nil, err
+	}
+	record, err := dsClient.API().GetOidcJWKS(context.Background(), cmdConfig.oidcJwksID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, errors.Errorf("OIDC jwksID not found: %s", cmdConfig.oidcJwksID)
+	}
+	if cmdConfig.versionSet && record.Version != cmdConfig.version {
+		return nil, errors.Errorf("OIDC jwksID %s version mismatch: expected %d, stored %d", record.ID, cmdConfig.version, record.Version)
+	}
+	if cmdConfig.rotationModeSet {
+		record.RotationMode, err = parseRotationMode(cmdConfig.rotationMode)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cmdConfig.rotationPeriodSet {
+		record.RotationPeriod = int(cmdConfig.rotationPeriod / time.Second)
+	}
+	if cmdConfig.descriptionSet {
+		record.Description = cmdConfig.description
+	}
+	err = validateRotationPolicy(record.RotationMode, time.Duration(record.RotationPeriod)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	// the datastore rejects the update if the record was modified concurrently
+	record.Version = record.Version + 1
+	err = dsClient.API().UpdateOidcJWKS(context.Background(), record)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// CertificateConfig configures the X.509 certificates of the generated key pairs
+type CertificateConfig struct {
+	flagBase
+	Enabled   bool
+	Subject   string
+	Validity  time.Duration
+	CAFile    string
+	CAKeyFile string
+}
+
+func NewCertificateConfig() *CertificateConfig {
+	return &CertificateConfig{}
+}
+
+func (c *CertificateConfig) FlagSet() *pflag.FlagSet {
+	if c.initFlagSet() {
+		c.flagSet.BoolVar(&c.Enabled, "cert", false, "Issue a X.509 certificate for the key pair, exposed as x5c, x5t and x5t#S256. The certificate is self-signed unless a CA is provided.")
+		c.flagSet.StringVar(&c.Subject, "cert-subject", "", "Certificate subject e.g. 'CN=tribe,O=grepplabs'. The common name is the kid if not provided.")
+		c.flagSet.DurationVar(&c.Validity, "cert-validity", 365*24*time.Hour, "Certificate validity")
+		c.flagSet.StringVar(&c.CAFile, "cert-ca-file", "", "PEM file with the CA certificate chain issuing the certificate")
+		c.flagSet.StringVar(&c.CAKeyFile, "cert-ca-key-file", "", "PEM file with the private key of the CA")
+	}
+	return c.flagSet
+}
+
+func (c *CertificateConfig) Validate() error {
+	if (c.CAFile == "") != (c.CAKeyFile == "") {
+		return errors.New("cert-ca-file and cert-ca-key-file must be provided together")
+	}
+	if !c.Enabled && c.CAFile != "" {
+		return errors.New("cert-ca-file requires cert")
+	}
+	if c.Validity <= 0 {
+		return errors.New("cert-validity must be positive")
+	}
+	return nil
+}
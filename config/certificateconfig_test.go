@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificateConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *CertificateConfig
+		hasErr bool
+	}{
+		{name: "disabled", config: &CertificateConfig{Validity: time.Hour}},
+		{name: "self-signed", config: &CertificateConfig{Enabled: true, Validity: time.Hour}},
+		{name: "ca", config: &CertificateConfig{Enabled: true, Validity: time.Hour, CAFile: "ca.pem", CAKeyFile: "ca-key.pem"}},
+		{name: "ca without cert", config: &CertificateConfig{Validity: time.Hour, CAFile: "ca.pem", CAKeyFile: "ca-key.pem"}, hasErr: true},
+		{name: "ca without key", config: &CertificateConfig{Enabled: true, Validity: time.Hour, CAFile: "ca.pem"}, hasErr: true},
+		{name: "no validity", config: &CertificateConfig{Enabled: true}, hasErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.hasErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
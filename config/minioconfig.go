@@ -1,6 +1,10 @@
 package config
 
-import "github.com/spf13/pflag"
+import (
+	"github.com/grepplabs/tribe/pkg/secret"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
 
 type MinioConfig struct {
 	flagBase
@@ -22,9 +26,18 @@ func (c *MinioConfig) FlagSet() *pflag.FlagSet {
 		c.flagSet.StringVar(&c.Endpoint, "minio-endpoint", "localhost:9000", "Service endpoint")
 		c.flagSet.BoolVar(&c.UseSSL, "minio-use-ssl", false, "Set this value to true to enable secure (HTTPS) access")
 		c.flagSet.StringVar(&c.AccessKeyID, "minio-access-key-id", "minioadmin", "Access key is like user ID that uniquely identifies your account")
-		c.flagSet.StringVar(&c.SecretAccessKey, "minio-secret-access-key", "minioadmin123", "Secret key is the password to your account")
+		c.flagSet.StringVar(&c.SecretAccessKey, "minio-secret-access-key", "minioadmin123", "Secret key is the password to your account or secret reference")
 		c.flagSet.StringVar(&c.BucketName, "minio-bucket-name", "tribe", "Bucket name")
 		c.flagSet.StringVar(&c.BucketLocation, "minio-bucket-location", "eu-central-1", "Region the bucket resides in")
 	}
 	return c.flagSet
 }
+
+// GetSecretAccessKey resolves the secret key reference
+func (c *MinioConfig) GetSecretAccessKey() (string, error) {
+	secretAccessKey, err := secret.Resolve("minio-secret-access-key", c.SecretAccessKey)
+	if err != nil {
+		return "", errors.Wrap(err, "resolve minio secret access key failed")
+	}
+	return secretAccessKey, nil
+}
@@ -2,19 +2,55 @@ package config
 
 import (
 	"crypto/tls"
+	"sync"
+
+	"github.com/grepplabs/tribe/pkg/secret"
 	tlsconfig "github.com/grepplabs/tribe/pkg/tls"
+	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 )
 
 type VaultConfig struct {
 	flagBase
 
-	Address string
-	Token   string
+	Address   string
+	Token     string
+	Namespace string
+
+	AuthMethod string
+	AppRole    VaultAppRoleConfig
+	Kubernetes VaultKubernetesConfig
+	Cert       VaultCertConfig
+
+	Transit VaultTransitConfig
 
 	TLSConfig VaultTLSConfig
 }
 
+type VaultAppRoleConfig struct {
+	MountPath string
+	RoleID    string
+	SecretID  string
+}
+
+type VaultKubernetesConfig struct {
+	MountPath string
+	Role      string
+	JWTPath   string
+}
+
+type VaultCertConfig struct {
+	MountPath string
+	Role      string
+}
+
+type VaultTransitConfig struct {
+	MountPath     string
+	KeyType       string
+	KeyDerived    bool
+	AutoCreateKey bool
+}
+
 type VaultTLSConfig struct {
 	Cert               string
 	Key                string
@@ -24,6 +60,9 @@ type VaultTLSConfig struct {
 	InsecureSkipVerify bool
 }
 
+// vaultConfigs maps the vault-addr flag to its configuration, so the configuration of the executed command can be found
+var vaultConfigs sync.Map
+
 func NewVaultConfig() *VaultConfig {
 	return &VaultConfig{}
 }
@@ -31,17 +70,62 @@ func NewVaultConfig() *VaultConfig {
 func (c *VaultConfig) FlagSet() *pflag.FlagSet {
 	if c.initFlagSet() {
 		c.flagSet.StringVar(&c.Address, "vault-addr", "https://localhost:8201", "Vault server address")
-		c.flagSet.StringVar(&c.Token, "vault-token", "tribe-root-token", "Vault authentication token")
+		c.flagSet.StringVar(&c.Token, "vault-token", "tribe-root-token", "Vault authentication token or secret reference")
+		c.flagSet.StringVar(&c.Namespace, "vault-namespace", "", "Vault namespace")
+		c.flagSet.StringVar(&c.AuthMethod, "vault-auth-method", "token", "Vault authentication method. One of: [token, approle, kubernetes, cert]")
+		c.flagSet.StringVar(&c.AppRole.MountPath, "vault-approle-mount-path", "approle", "Mount path of the AppRole auth method")
+		c.flagSet.StringVar(&c.AppRole.RoleID, "vault-approle-role-id", "", "AppRole role ID")
+		c.flagSet.StringVar(&c.AppRole.SecretID, "vault-approle-secret-id", "", "AppRole secret ID or secret reference")
+		c.flagSet.StringVar(&c.Kubernetes.MountPath, "vault-kubernetes-mount-path", "kubernetes", "Mount path of the Kubernetes auth method")
+		c.flagSet.StringVar(&c.Kubernetes.Role, "vault-kubernetes-role", "", "Kubernetes auth role")
+		c.flagSet.StringVar(&c.Kubernetes.JWTPath, "vault-kubernetes-jwt-path", "/var/run/secrets/kubernetes.io/serviceaccount/token", "Path to the service account token")
+		c.flagSet.StringVar(&c.Cert.MountPath, "vault-cert-mount-path", "cert", "Mount path of the TLS certificate auth method")
+		c.flagSet.StringVar(&c.Cert.Role, "vault-cert-role", "", "TLS certificate auth role, the client certificate is set with vault-tls-cert and vault-tls-key")
+		c.flagSet.StringVar(&c.Transit.MountPath, "vault-transit-mount-path", "transit", "Mount path of the transit secrets engine")
+		c.flagSet.StringVar(&c.Transit.KeyType, "vault-transit-key-type", "aes256-gcm96", "Type of the auto created transit keys. One of: [aes128-gcm96, aes256-gcm96, chacha20-poly1305]")
+		c.flagSet.BoolVar(&c.Transit.KeyDerived, "vault-transit-key-derived", true, "Create transit keys with key derivation, the derivation context binds the ciphertext to the JWKS record")
+		c.flagSet.BoolVar(&c.Transit.AutoCreateKey, "vault-transit-auto-create-key", true, "Create the transit key if it does not exist")
 		c.flagSet.StringVar(&c.TLSConfig.Cert, "vault-tls-cert", "", "Client cert file")
 		c.flagSet.StringVar(&c.TLSConfig.Key, "vault-tls-key", "", "Client key file")
 		c.flagSet.StringVar(&c.TLSConfig.CaCert, "vault-tls-ca-cert", "", "CA cert file")
 		c.flagSet.StringVar(&c.TLSConfig.ServerName, "vault-tls-servername", "", "Server name to verify the hostname on the returned certificates")
 		c.flagSet.BoolVar(&c.TLSConfig.UseSystemCertPool, "vault-tls-use-system-cert-pool", true, "Use system cert pool which system default locations can be overridden with SSL_CERT_FILE/SSL_CERT_DIR env")
 		c.flagSet.BoolVar(&c.TLSConfig.InsecureSkipVerify, "vault-tls-insecure-skip-verify", false, "Disables SSL certificate verification")
+		vaultConfigs.Store(c.flagSet.Lookup("vault-addr"), c)
 	}
 	return c.flagSet
 }
 
+// VaultConfigFromFlags returns the vault configuration whose flags were added to the flag set or nil
+func VaultConfigFromFlags(flagSet *pflag.FlagSet) *VaultConfig {
+	flag := flagSet.Lookup("vault-addr")
+	if flag == nil {
+		return nil
+	}
+	if c, ok := vaultConfigs.Load(flag); ok {
+		return c.(*VaultConfig)
+	}
+	return nil
+}
+
 func (c *VaultTLSConfig) NewClientConfig() (*tls.Config, error) {
 	return tlsconfig.NewClientConfig(c.Cert, c.Key, c.CaCert, c.ServerName, c.UseSystemCertPool, c.InsecureSkipVerify)
 }
+
+// GetToken resolves the vault token reference
+func (c *VaultConfig) GetToken() (string, error) {
+	token, err := secret.Resolve("vault-token", c.Token)
+	if err != nil {
+		return "", errors.Wrap(err, "resolve vault token failed")
+	}
+	return token, nil
+}
+
+// GetSecretID resolves the AppRole secret ID reference
+func (c *VaultAppRoleConfig) GetSecretID() (string, error) {
+	secretID, err := secret.Resolve("vault-approle-secret-id", c.SecretID)
+	if err != nil {
+		return "", errors.Wrap(err, "resolve vault approle secret id failed")
+	}
+	return secretID, nil
+}
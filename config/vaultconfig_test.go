@@ -0,0 +1,25 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultConfigFromFlags(t *testing.T) {
+	vaultConfig := NewVaultConfig()
+	other := NewVaultConfig()
+	_ = other.FlagSet()
+
+	flagSet := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flagSet.AddFlagSet(vaultConfig.FlagSet())
+	assert.Nil(t, flagSet.Parse([]string{"--vault-addr", "https://vault.example.com:8200"}))
+
+	found := VaultConfigFromFlags(flagSet)
+	if assert.NotNil(t, found) {
+		assert.Same(t, vaultConfig, found)
+		assert.Equal(t, "https://vault.example.com:8200", found.Address)
+	}
+	assert.Nil(t, VaultConfigFromFlags(pflag.NewFlagSet("empty", pflag.ContinueOnError)))
+}
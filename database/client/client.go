@@ -1,7 +1,34 @@
 package client
 
-import "github.com/grepplabs/tribe/database/service"
+import (
+	"strings"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/database/service"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+)
 
 type Client interface {
 	API() service.API
 }
+
+// NewClient creates the client of the configured datastore provider
+func NewClient(logger log.Logger, datastoreConfig *config.DatastoreConfig) (Client, error) {
+	switch strings.ToLower(datastoreConfig.Provider) {
+	case "db":
+		dbClient, err := NewSQLClient(logger, &datastoreConfig.DBConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "create sql client failed")
+		}
+		return dbClient, nil
+	case "minio":
+		minioClient, err := NewMinioClient(logger, &datastoreConfig.MinioConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "create minio client failed")
+		}
+		return minioClient, nil
+	default:
+		return nil, errors.Errorf("Unsupported datastore provider: %v", datastoreConfig.Provider)
+	}
+}
@@ -20,8 +20,12 @@ type minioClient struct {
 }
 
 func NewMinioClient(logger log.Logger, config *config.MinioConfig) (Client, error) {
-	var mc, err = minio.New(config.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(config.AccessKeyID, config.SecretAccessKey, ""),
+	secretAccessKey, err := config.GetSecretAccessKey()
+	if err != nil {
+		return nil, err
+	}
+	mc, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKeyID, secretAccessKey, ""),
 		Secure: config.UseSSL,
 	})
 	if err != nil {
@@ -40,7 +40,11 @@ func (c sqlClient) API() service.API {
 
 func newDBSession(logger log.Logger, config *config.DBConfig) (db.Session, error) {
 	//TODO: dbx with tracing and profiling ()
-	dsnUrl, err := url.Parse(config.ConnectionURL)
+	connectionURL, err := config.GetConnectionURL()
+	if err != nil {
+		return nil, err
+	}
+	dsnUrl, err := url.Parse(connectionURL)
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +53,7 @@ func newDBSession(logger log.Logger, config *config.DBConfig) (db.Session, error
 		return nil, errors.Errorf("'%s' DNS schema expected, but got '%s'", postgresql.Adapter, dsnUrl.Scheme)
 	}
 
-	dbx, err := connectWithRetry(logger, "postgres", config.ConnectionURL)
+	dbx, err := connectWithRetry(logger, "postgres", connectionURL)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,15 @@
+package client
+
+import (
+	"context"
+)
+
+// Unlock releases the lock
+type Unlock func(ctx context.Context) error
+
+// Locker is implemented by the clients which can acquire locks shared by all processes using the datastore.
+// The object store has no conditional writes, so the minio client does not implement it.
+type Locker interface {
+	// TryLock acquires the named lock without waiting. The lock is held until Unlock is called or the holder is gone.
+	TryLock(ctx context.Context, name string) (Unlock, bool, error)
+}
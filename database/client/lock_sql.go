@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+var _ Locker = (*sqlClient)(nil)
+
+// TryLock acquires the postgres session advisory lock. The lock is bound to a dedicated connection, which is closed
+// by Unlock; the lock is released by the server when the connection is lost.
+func (c sqlClient) TryLock(ctx context.Context, name string) (Unlock, bool, error) {
+	sqlDB, ok := c.dbs.Driver().(*sql.DB)
+	if !ok {
+		return nil, false, errors.Errorf("unsupported database driver %T", c.dbs.Driver())
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "get database connection failed")
+	}
+	var locked bool
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", name).Scan(&locked)
+	if err != nil {
+		_ = conn.Close()
+		return nil, false, errors.Wrapf(err, "advisory lock %s", name)
+	}
+	if !locked {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+	unlock := func(ctx context.Context) error {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", name)
+		if cerr := conn.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+		return errors.Wrapf(err, "advisory unlock %s", name)
+	}
+	return unlock, true, nil
+}
@@ -0,0 +1 @@
+ALTER TABLE tribe_kms_keyset DROP COLUMN IF EXISTS version;
@@ -0,0 +1 @@
+ALTER TABLE tribe_kms_keyset ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 0;
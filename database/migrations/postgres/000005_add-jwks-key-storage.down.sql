@@ -0,0 +1,2 @@
+ALTER TABLE tribe_jwks DROP COLUMN IF EXISTS public_jwks;
+ALTER TABLE tribe_jwks DROP COLUMN IF EXISTS key_storage;
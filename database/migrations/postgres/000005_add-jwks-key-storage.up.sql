@@ -0,0 +1,2 @@
+ALTER TABLE tribe_jwks ADD COLUMN IF NOT EXISTS key_storage varchar(32) NOT NULL DEFAULT 'kms';
+ALTER TABLE tribe_jwks ADD COLUMN IF NOT EXISTS public_jwks TEXT NOT NULL DEFAULT '';
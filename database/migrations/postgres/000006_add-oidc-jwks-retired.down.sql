@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS tribe_oidc_jwks_retired;
+ALTER TABLE tribe_oidc_jwks DROP COLUMN IF EXISTS retired_jwks_id;
@@ -0,0 +1,2 @@
+ALTER TABLE tribe_oidc_jwks ADD COLUMN IF NOT EXISTS retired_jwks_id varchar(255) NULL CONSTRAINT fk_tribe_oidc_jwks_retired REFERENCES tribe_jwks (id);
+CREATE INDEX IF NOT EXISTS tribe_oidc_jwks_retired ON tribe_oidc_jwks (retired_jwks_id);
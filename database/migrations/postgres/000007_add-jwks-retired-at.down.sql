@@ -0,0 +1 @@
+ALTER TABLE tribe_jwks DROP COLUMN IF EXISTS retired_at;
@@ -0,0 +1 @@
+ALTER TABLE tribe_jwks ADD COLUMN IF NOT EXISTS retired_at timestamp NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS tribe_kms_key_destroy;
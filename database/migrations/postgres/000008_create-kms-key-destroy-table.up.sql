@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS tribe_kms_key_destroy
+(
+    id               varchar(255)  NOT NULL,
+    created_at       timestamp     NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    kms_key_uri      varchar(255)  NOT NULL,
+    error            TEXT          NOT NULL,
+    CONSTRAINT pk_tribe_kms_key_destroy PRIMARY KEY (id)
+);
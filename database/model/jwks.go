@@ -2,21 +2,36 @@ package model
 
 import "time"
 
+const (
+	// JWKSKeyStorageKMS keeps the key pair in EncryptedJwks, encrypted with the KMS key
+	JWKSKeyStorageKMS = "kms"
+	// JWKSKeyStorageVaultTransit keeps the key pair in the vault transit key KMSKeyURI, only the public keys are stored in PublicJwks
+	JWKSKeyStorageVaultTransit = "vault-transit"
+)
+
 type JWKS struct {
-	ID            string    `db:"id" json:"id"`
-	CreatedAt     time.Time `db:"created_at" json:"created_at"`
-	Kid           string    `db:"kid" json:"kid"`
-	Alg           string    `db:"alg" json:"alg"`
-	Use           string    `db:"use" json:"use"`
-	KMSKeyURI     string    `db:"kms_key_uri" json:"kms_key_uri"`
-	EncryptedJwks string    `db:"encrypted_jwks" json:"encrypted_jwks"`
-	Description   string    `db:"description" json:"description"`
+	ID            string     `db:"id" json:"id"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	Kid           string     `db:"kid" json:"kid"`
+	Alg           string     `db:"alg" json:"alg"`
+	Use           string     `db:"use" json:"use"`
+	KMSKeyURI     string     `db:"kms_key_uri" json:"kms_key_uri"`
+	EncryptedJwks string     `db:"encrypted_jwks" json:"encrypted_jwks"`
+	KeyStorage    string     `db:"key_storage" json:"key_storage,omitempty"`
+	PublicJwks    string     `db:"public_jwks" json:"public_jwks,omitempty"`
+	Description   string     `db:"description" json:"description"`
+	RetiredAt     *time.Time `db:"retired_at" json:"retired_at,omitempty"` // set when the JWKS left the OIDC JWKS, deleted by jwks gc after the grace period
 }
 
 func (JWKS) TableName() string {
 	return "tribe_jwks"
 }
 
+// IsVaultTransit returns true if the private key is held by the vault transit engine
+func (j JWKS) IsVaultTransit() bool {
+	return j.KeyStorage == JWKSKeyStorageVaultTransit
+}
+
 type JWKSList struct {
 	List []JWKS `json:"list"`
 	Page Page   `json:"page"`
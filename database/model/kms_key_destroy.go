@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// KMSKeyDestroy records the KMS key of a deleted JWKS whose delete failed, jwks gc retries the delete
+type KMSKeyDestroy struct {
+	ID        string    `db:"id" json:"id"` // ID of the deleted JWKS
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	KMSKeyURI string    `db:"kms_key_uri" json:"kms_key_uri"`
+	Error     string    `db:"error" json:"error"`
+}
+
+func (KMSKeyDestroy) TableName() string {
+	return "tribe_kms_key_destroy"
+}
+
+type KMSKeyDestroyList struct {
+	List []KMSKeyDestroy `json:"list"`
+	Page Page            `json:"page"`
+}
@@ -9,6 +9,7 @@ type KMSKeyset struct {
 	CreatedAt       time.Time `db:"created_at" json:"created_at"`
 	EncryptedKeyset string    `db:"encrypted_keyset" json:"encrypted_keyset"`
 	Description     string    `db:"description" json:"description"`
+	Version         int       `db:"version" json:"version"`
 }
 
 func (KMSKeyset) TableName() string {
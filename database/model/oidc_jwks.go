@@ -2,14 +2,22 @@ package model
 
 import "time"
 
+const (
+	// OidcJWKSRotationModeManual sets are rotated by the rotate command only
+	OidcJWKSRotationModeManual = 0
+	// OidcJWKSRotationModePeriodic sets are rotated by the rotation worker every rotation period
+	OidcJWKSRotationModePeriodic = 1
+)
+
 type OidcJWKS struct {
 	ID             string    `db:"id" json:"id"`
 	CreatedAt      time.Time `db:"created_at" json:"created_at"`
 	CurrentJwksID  string    `db:"current_jwks_id" json:"current_jwks_id"`
 	NextJwksID     string    `db:"next_jwks_id" json:"next_jwks_id"`
 	PreviousJwksID *string   `db:"previous_jwks_id" json:"previous_jwks_id,omitempty"`
+	RetiredJwksID  *string   `db:"retired_jwks_id" json:"retired_jwks_id,omitempty"` // previous key dropped by the last rotation, restored by the rollback
 	RotationMode   int       `db:"rotation_mode" json:"rotation_mode"`
-	RotationPeriod int       `db:"rotation_period" json:"rotation_period"`
+	RotationPeriod int       `db:"rotation_period" json:"rotation_period"` // seconds
 	LastRotated    time.Time `db:"last_rotated" json:"last_rotated"`
 	Description    string    `db:"description" json:"description"`
 	Version        int       `db:"version" json:"version"`
@@ -19,6 +27,14 @@ func (OidcJWKS) TableName() string {
 	return "tribe_oidc_jwks"
 }
 
+// RotationDue reports whether the periodic rotation of the set is overdue
+func (r OidcJWKS) RotationDue(now time.Time) bool {
+	if r.RotationMode != OidcJWKSRotationModePeriodic || r.RotationPeriod <= 0 {
+		return false
+	}
+	return !now.Before(r.LastRotated.Add(time.Duration(r.RotationPeriod) * time.Second))
+}
+
 type OidcJWKSList struct {
 	List []OidcJWKS `json:"list"`
 	Page Page       `json:"page"`
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOidcJWKSRotationDue(t *testing.T) {
+	lastRotated := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		record OidcJWKS
+		now    time.Time
+		due    bool
+	}{
+		{name: "manual mode", record: OidcJWKS{RotationMode: OidcJWKSRotationModeManual, RotationPeriod: 60, LastRotated: lastRotated}, now: lastRotated.Add(time.Hour), due: false},
+		{name: "zero period", record: OidcJWKS{RotationMode: OidcJWKSRotationModePeriodic, LastRotated: lastRotated}, now: lastRotated.Add(time.Hour), due: false},
+		{name: "negative period", record: OidcJWKS{RotationMode: OidcJWKSRotationModePeriodic, RotationPeriod: -60, LastRotated: lastRotated}, now: lastRotated.Add(time.Hour), due: false},
+		{name: "not yet due", record: OidcJWKS{RotationMode: OidcJWKSRotationModePeriodic, RotationPeriod: 60, LastRotated: lastRotated}, now: lastRotated.Add(59 * time.Second), due: false},
+		{name: "exactly due", record: OidcJWKS{RotationMode: OidcJWKSRotationModePeriodic, RotationPeriod: 60, LastRotated: lastRotated}, now: lastRotated.Add(60 * time.Second), due: true},
+		{name: "overdue", record: OidcJWKS{RotationMode: OidcJWKSRotationModePeriodic, RotationPeriod: 60, LastRotated: lastRotated}, now: lastRotated.Add(time.Hour), due: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.due, tc.record.RotationDue(tc.now))
+		})
+	}
+}
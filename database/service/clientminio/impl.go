@@ -8,6 +8,7 @@ import (
 
 type APIImpl struct {
 	kmsKeysetManager
+	kmsKeyDestroyManager
 	jwksManager
 	oidcJwksManager
 }
@@ -17,6 +18,7 @@ var _ service.API = (*APIImpl)(nil)
 func NewAPIImpl(mc *minio.Client, config *config.MinioConfig) *APIImpl {
 	return &APIImpl{
 		kmsKeysetManager{mc, config.BucketName},
+		kmsKeyDestroyManager{mc, config.BucketName},
 		jwksManager{mc, config.BucketName},
 		oidcJwksManager{mc, config.BucketName},
 	}
@@ -82,6 +82,22 @@ func (m jwksManager) DeleteJWKS(ctx context.Context, id string) error {
 	return nil
 }
 
+func (m jwksManager) UpdateJWKS(ctx context.Context, record *model.JWKS) error {
+	if record == nil {
+		return service.ErrIllegalArgument{Reason: "Input parameter record is missing"}
+	}
+	objectName := m.objectNameForID(record.ID)
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "Marshal record failed")
+	}
+	_, err = m.mc.PutObject(ctx, m.bucketName, objectName, bytes.NewBuffer(data), int64(len(data)), minio.PutObjectOptions{ContentType: "application/json"})
+	if err != nil {
+		return errors.Wrap(err, "PutObject failed")
+	}
+	return nil
+}
+
 func (m jwksManager) ListJWKS(ctx context.Context, offset *int64, limit *int64) (*model.JWKSList, error) {
 	var maxKeys int
 	if limit != nil && *limit > 0 {
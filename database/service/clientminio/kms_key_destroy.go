@@ -0,0 +1,92 @@
+package clientminio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/database/service"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+)
+
+type kmsKeyDestroyManager struct {
+	mc         *minio.Client
+	bucketName string
+}
+
+func (m kmsKeyDestroyManager) CreateKMSKeyDestroy(ctx context.Context, record *model.KMSKeyDestroy) error {
+	if record == nil {
+		return service.ErrIllegalArgument{Reason: "Input parameter record is missing"}
+	}
+	objectName := m.objectNameForID(record.ID)
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "Marshal KMSKeyDestroy failed")
+	}
+	_, err = m.mc.PutObject(ctx, m.bucketName, objectName, bytes.NewBuffer(data), int64(len(data)), minio.PutObjectOptions{ContentType: "application/json"})
+	if err != nil {
+		return errors.Wrap(err, "PutObject failed")
+	}
+	return nil
+}
+
+func (m kmsKeyDestroyManager) getObject(ctx context.Context, objectName string) (*model.KMSKeyDestroy, error) {
+	reader, err := m.mc.GetObject(ctx, m.bucketName, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "GetObject failed")
+	}
+	defer reader.Close()
+	var record model.KMSKeyDestroy
+	err = json.NewDecoder(reader).Decode(&record)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (m kmsKeyDestroyManager) DeleteKMSKeyDestroy(ctx context.Context, id string) error {
+	if id == "" {
+		return service.ErrIllegalArgument{Reason: "Input parameter id is missing"}
+	}
+	objectName := m.objectNameForID(id)
+	err := m.mc.RemoveObject(ctx, m.bucketName, objectName, minio.RemoveObjectOptions{})
+	if err != nil {
+		return errors.Wrap(err, "RemoveObject failed")
+	}
+	return nil
+}
+
+func (m kmsKeyDestroyManager) ListKMSKeyDestroys(ctx context.Context, offset *int64, limit *int64) (*model.KMSKeyDestroyList, error) {
+	var maxKeys int
+	if limit != nil && *limit > 0 {
+		// limit 0 all elements
+		maxKeys = int(*limit)
+	}
+	list := make([]model.KMSKeyDestroy, 0)
+	for object := range m.mc.ListObjects(ctx, m.bucketName, minio.ListObjectsOptions{Prefix: m.objectPrefix(), Recursive: true, MaxKeys: maxKeys}) {
+		if object.Err != nil {
+			return nil, errors.Wrap(object.Err, "ListObjects failed")
+		}
+		record, err := m.getObject(ctx, object.Key)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *record)
+	}
+	return &model.KMSKeyDestroyList{List: list, Page: model.Page{
+		Offset: nil, // TODO: offset was not used
+		Limit:  limit,
+		Total:  0, // TODO: Total is unknown
+	}}, nil
+}
+
+func (m kmsKeyDestroyManager) objectNameForID(id string) string {
+	return fmt.Sprintf("%s%s", m.objectPrefix(), id)
+}
+
+func (m kmsKeyDestroyManager) objectPrefix() string {
+	var record model.KMSKeyDestroy
+	return fmt.Sprintf("%s/", record.TableName())
+}
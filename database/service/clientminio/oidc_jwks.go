@@ -77,9 +77,24 @@ func (m oidcJwksManager) UpdateOidcJWKS(ctx context.Context, record *model.OidcJ
 		return service.ErrIllegalArgument{Reason: "Input parameter record is missing"}
 	}
 	objectName := m.objectNameForID(record.ID)
+	exists, err := m.existsObjectWithName(ctx, objectName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return service.ErrConflict{Reason: fmt.Sprintf("OidcJWKS %s was removed", record.ID)}
+	}
+	// the object store has no conditional writes, the check does not exclude concurrent updates, so rotate run is refused on minio
+	stored, err := m.getObject(ctx, objectName)
+	if err != nil {
+		return err
+	}
+	if stored.Version != record.Version-1 {
+		return service.ErrConflict{Reason: fmt.Sprintf("OidcJWKS %s version %d was modified, stored version %d", record.ID, record.Version-1, stored.Version)}
+	}
 	data, err := json.Marshal(record)
 	if err != nil {
-		return errors.Wrap(err, "Marshal KMSKeyset failed")
+		return errors.Wrap(err, "Marshal OidcJWKS failed")
 	}
 	_, err = m.mc.PutObject(ctx, m.bucketName, objectName, bytes.NewBuffer(data), int64(len(data)), minio.PutObjectOptions{ContentType: "application/json"})
 	if err != nil {
@@ -88,6 +103,30 @@ func (m oidcJwksManager) UpdateOidcJWKS(ctx context.Context, record *model.OidcJ
 	return nil
 }
 
+func (m oidcJwksManager) ListOidcJWKS(ctx context.Context, offset *int64, limit *int64) (*model.OidcJWKSList, error) {
+	var maxKeys int
+	if limit != nil && *limit > 0 {
+		// limit 0 all elements
+		maxKeys = int(*limit)
+	}
+	list := make([]model.OidcJWKS, 0)
+	for object := range m.mc.ListObjects(ctx, m.bucketName, minio.ListObjectsOptions{Prefix: m.objectPrefix(), Recursive: true, MaxKeys: maxKeys}) {
+		if object.Err != nil {
+			return nil, errors.Wrap(object.Err, "ListObjects failed")
+		}
+		record, err := m.getObject(ctx, object.Key)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *record)
+	}
+	return &model.OidcJWKSList{List: list, Page: model.Page{
+		Offset: nil, // TODO: offset was not used
+		Limit:  limit,
+		Total:  0, // TODO: Total is unknown
+	}}, nil
+}
+
 func (m oidcJwksManager) objectNameForID(id string) string {
 	return fmt.Sprintf("%s%s", m.objectPrefix(), id)
 }
@@ -7,6 +7,7 @@ import (
 
 type APIImpl struct {
 	kmsKeysetManager
+	kmsKeyDestroyManager
 	jwksManager
 	oidcJwksManager
 }
@@ -16,6 +17,7 @@ var _ service.API = (*APIImpl)(nil)
 func NewAPIImpl(dbs db.Session) *APIImpl {
 	return &APIImpl{
 		kmsKeysetManager{dbs},
+		kmsKeyDestroyManager{dbs},
 		jwksManager{dbs},
 		oidcJwksManager{dbs},
 	}
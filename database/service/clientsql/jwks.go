@@ -71,6 +71,14 @@ func (m jwksManager) DeleteJWKSByKidUse(ctx context.Context, kid string, use str
 	return errors.Wrap(err, "delete kid/use")
 }
 
+func (m jwksManager) UpdateJWKS(ctx context.Context, record *model.JWKS) error {
+	if record == nil {
+		return service.ErrIllegalArgument{Reason: "Input parameter record is missing"}
+	}
+	err := m.dbs.WithContext(ctx).Collection(record.TableName()).Find(db.Cond{"id": record.ID}).Update(record)
+	return errors.Wrap(err, "update JWKS")
+}
+
 func (m jwksManager) ListJWKS(ctx context.Context, offset *int64, limit *int64) (*model.JWKSList, error) {
 	var jwks model.JWKS
 	result := m.dbs.WithContext(ctx).Collection(jwks.TableName()).Find().OrderBy("created_at")
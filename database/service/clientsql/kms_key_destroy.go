@@ -0,0 +1,64 @@
+package clientsql
+
+import (
+	"context"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/database/service"
+	"github.com/pkg/errors"
+	"github.com/upper/db/v4"
+)
+
+type kmsKeyDestroyManager struct {
+	dbs db.Session
+}
+
+func (m kmsKeyDestroyManager) CreateKMSKeyDestroy(ctx context.Context, record *model.KMSKeyDestroy) error {
+	if record == nil {
+		return service.ErrIllegalArgument{Reason: "Input parameter record is missing"}
+	}
+	_, err := m.dbs.WithContext(ctx).Collection(record.TableName()).Insert(record)
+	if err != nil {
+		return errors.Wrap(err, "insert KMSKeyDestroy")
+	}
+	return nil
+}
+
+func (m kmsKeyDestroyManager) DeleteKMSKeyDestroy(ctx context.Context, id string) error {
+	if id == "" {
+		return service.ErrIllegalArgument{Reason: "Input parameter id is missing"}
+	}
+	var record model.KMSKeyDestroy
+	err := m.dbs.WithContext(ctx).Collection(record.TableName()).Find(db.Cond{"id": id}).Delete()
+	return errors.Wrap(err, "delete KMSKeyDestroy")
+}
+
+func (m kmsKeyDestroyManager) ListKMSKeyDestroys(ctx context.Context, offset *int64, limit *int64) (*model.KMSKeyDestroyList, error) {
+	var record model.KMSKeyDestroy
+	result := m.dbs.WithContext(ctx).Collection(record.TableName()).Find().OrderBy("created_at")
+	if offset != nil && *offset > 0 {
+		result = result.Offset(int(*offset))
+	}
+	if limit != nil && *limit > 0 {
+		// limit 0 all elements
+		result = result.Limit(int(*limit))
+	}
+
+	var list []model.KMSKeyDestroy
+	err := result.All(&list)
+	if err != nil {
+		if errors.Is(err, db.ErrNoMoreRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "list KMSKeyDestroy")
+	}
+	// this executes additional query
+	total, err := result.TotalEntries()
+	if err != nil {
+		return nil, errors.Wrap(err, "list KMSKeyDestroy total entries")
+	}
+	return &model.KMSKeyDestroyList{List: list, Page: model.Page{
+		Offset: offset,
+		Limit:  limit,
+		Total:  total,
+	}}, nil
+}
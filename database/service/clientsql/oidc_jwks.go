@@ -2,6 +2,7 @@ package clientsql
 
 import (
 	"context"
+	"fmt"
 	"github.com/grepplabs/tribe/database/model"
 	"github.com/grepplabs/tribe/database/service"
 	"github.com/pkg/errors"
@@ -51,6 +52,47 @@ func (m oidcJwksManager) UpdateOidcJWKS(ctx context.Context, record *model.OidcJ
 	if record == nil {
 		return service.ErrIllegalArgument{Reason: "Input parameter record is missing"}
 	}
-	err := m.dbs.WithContext(ctx).Collection(record.TableName()).Find(db.Cond{"id": record.ID}).Update(record)
-	return errors.Wrap(err, "update OidcJWKS")
+	res, err := m.dbs.WithContext(ctx).SQL().Update(record.TableName()).Set(record).Where(db.Cond{"id": record.ID, "version": record.Version - 1}).Exec()
+	if err != nil {
+		return errors.Wrap(err, "update OidcJWKS")
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "update OidcJWKS rows affected")
+	}
+	if rows == 0 {
+		return service.ErrConflict{Reason: fmt.Sprintf("OidcJWKS %s version %d was modified or removed", record.ID, record.Version-1)}
+	}
+	return nil
+}
+
+func (m oidcJwksManager) ListOidcJWKS(ctx context.Context, offset *int64, limit *int64) (*model.OidcJWKSList, error) {
+	var oidcJWKS model.OidcJWKS
+	result := m.dbs.WithContext(ctx).Collection(oidcJWKS.TableName()).Find().OrderBy("created_at")
+	if offset != nil && *offset > 0 {
+		result = result.Offset(int(*offset))
+	}
+	if limit != nil && *limit > 0 {
+		// limit 0 all elements
+		result = result.Limit(int(*limit))
+	}
+
+	var list []model.OidcJWKS
+	err := result.All(&list)
+	if err != nil {
+		if errors.Is(err, db.ErrNoMoreRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "list OidcJWKS")
+	}
+	// this executes additional query
+	total, err := result.TotalEntries()
+	if err != nil {
+		return nil, errors.Wrap(err, "list OidcJWKS total entries")
+	}
+	return &model.OidcJWKSList{List: list, Page: model.Page{
+		Offset: offset,
+		Limit:  limit,
+		Total:  total,
+	}}, nil
 }
@@ -25,3 +25,11 @@ type ErrAlreadyExists struct {
 func (e ErrAlreadyExists) Error() string {
 	return fmt.Sprintf("The specified key already exists: %v", e.Reason)
 }
+
+type ErrConflict struct {
+	Reason string
+}
+
+func (e ErrConflict) Error() string {
+	return fmt.Sprintf("Conflict: %v", e.Reason)
+}
@@ -12,15 +12,22 @@ type API interface {
 	GetKMSKeyset(ctx context.Context, id string) (*model.KMSKeyset, error)
 	ListKMSKeysets(ctx context.Context, offset *int64, limit *int64) (*model.KMSKeysetList, error)
 
+	CreateKMSKeyDestroy(ctx context.Context, record *model.KMSKeyDestroy) error
+	DeleteKMSKeyDestroy(ctx context.Context, id string) error
+	ListKMSKeyDestroys(ctx context.Context, offset *int64, limit *int64) (*model.KMSKeyDestroyList, error)
+
 	CreateJWKS(ctx context.Context, record *model.JWKS) error
 	GetJWKS(ctx context.Context, id string) (*model.JWKS, error)
 	GetJWKSByKidUse(ctx context.Context, kid string, use string) (*model.JWKS, error)
 	DeleteJWKS(ctx context.Context, id string) error
 	DeleteJWKSByKidUse(ctx context.Context, kid string, use string) error
+	UpdateJWKS(ctx context.Context, record *model.JWKS) error
 	ListJWKS(ctx context.Context, offset *int64, limit *int64) (*model.JWKSList, error)
 
 	CreateOidcJWKS(ctx context.Context, id *model.OidcJWKS) error
 	DeleteOidcJWKS(ctx context.Context, id string) error
+	// UpdateOidcJWKS stores the record with the incremented version, ErrConflict is returned if the stored version is not record.Version-1
 	UpdateOidcJWKS(ctx context.Context, id *model.OidcJWKS) error
 	GetOidcJWKS(ctx context.Context, id string) (*model.OidcJWKS, error)
+	ListOidcJWKS(ctx context.Context, offset *int64, limit *int64) (*model.OidcJWKSList, error)
 }
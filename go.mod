@@ -3,11 +3,13 @@ module github.com/grepplabs/tribe
 go 1.16
 
 require (
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1
 	github.com/form3tech-oss/jwt-go v3.2.2+incompatible
 	github.com/go-openapi/runtime v0.19.27
 	github.com/golang/protobuf v1.4.2
 	github.com/google/tink/go v1.5.0
 	github.com/google/uuid v1.2.0
+	github.com/hashicorp/vault/api v1.0.4
 	github.com/jmoiron/sqlx v1.3.1
 	github.com/kamilsk/retry/v5 v5.0.0-rc8
 	github.com/minio/minio-go/v7 v7.0.10
@@ -21,5 +23,6 @@ require (
 	github.com/upper/db/v4 v4.1.0
 	go.uber.org/zap v1.16.0
 	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
 	gopkg.in/square/go-jose.v2 v2.5.1
 )
@@ -0,0 +1,279 @@
+package jwe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"strings"
+
+	"github.com/grepplabs/tribe/database/client"
+	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/jwt"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// DefaultContentEncryption is the content encryption of the encrypted tokens
+	DefaultContentEncryption = jose.A128CBC_HS256
+	// DefaultRSAKeyAlgorithm is the key management algorithm of the RSA recipients without alg
+	DefaultRSAKeyAlgorithm = jose.RSA_OAEP_256
+	// DefaultECKeyAlgorithm is the key management algorithm of the EC recipients without alg
+	DefaultECKeyAlgorithm = jose.ECDH_ES_A256KW
+
+	// ContentTypeJWT is the cty header of the nested JWT
+	ContentTypeJWT = "JWT"
+)
+
+var ErrKeyNotFound = errors.New("jwe: decryption key not found")
+
+// key management algorithms accepted for decryption, RSA1_5 is not accepted
+var keyAlgorithms = map[jose.KeyAlgorithm]struct{}{
+	jose.RSA_OAEP:       {},
+	jose.RSA_OAEP_256:   {},
+	jose.ECDH_ES:        {},
+	jose.ECDH_ES_A128KW: {},
+	jose.ECDH_ES_A192KW: {},
+	jose.ECDH_ES_A256KW: {},
+}
+
+type encryptOptions struct {
+	contentEncryption jose.ContentEncryption
+	keyAlgorithm      jose.KeyAlgorithm
+	contentType       string
+}
+
+type EncryptOption func(*encryptOptions)
+
+// WithContentEncryption sets the enc header, A128CBC-HS256 is used by default
+func WithContentEncryption(enc jose.ContentEncryption) EncryptOption {
+	return func(o *encryptOptions) {
+		o.contentEncryption = enc
+	}
+}
+
+// WithKeyAlgorithm sets the key management algorithm, by default the alg of the recipient key is used
+func WithKeyAlgorithm(alg jose.KeyAlgorithm) EncryptOption {
+	return func(o *encryptOptions) {
+		o.keyAlgorithm = alg
+	}
+}
+
+// WithContentType sets the cty header
+func WithContentType(cty string) EncryptOption {
+	return func(o *encryptOptions) {
+		o.contentType = cty
+	}
+}
+
+// Encrypt encrypts the payload to the public key of the recipient and returns the compact serialized JWE
+func Encrypt(recipient *jose.JSONWebKey, payload []byte, options ...EncryptOption) (string, error) {
+	opts := &encryptOptions{
+		contentEncryption: DefaultContentEncryption,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	publicKey, err := recipientPublicKey(recipient)
+	if err != nil {
+		return "", err
+	}
+	alg, err := keyAlgorithm(publicKey, opts.keyAlgorithm)
+	if err != nil {
+		return "", err
+	}
+	encrypterOptions := &jose.EncrypterOptions{}
+	if opts.contentType != "" {
+		encrypterOptions = encrypterOptions.WithContentType(jose.ContentType(opts.contentType))
+	}
+	encrypter, err := jose.NewEncrypter(opts.contentEncryption, jose.Recipient{
+		Algorithm: alg,
+		Key:       publicKey.Key,
+		KeyID:     publicKey.KeyID,
+	}, encrypterOptions)
+	if err != nil {
+		return "", errors.Wrap(err, "create encrypter failed")
+	}
+	object, err := encrypter.Encrypt(payload)
+	if err != nil {
+		return "", errors.Wrap(err, "encrypt failed")
+	}
+	return object.CompactSerialize()
+}
+
+// EncryptJWT encrypts the signed JWT as a nested JWT (sign-then-encrypt), see RFC 7519 section 5.2
+func EncryptJWT(recipient *jose.JSONWebKey, signedToken string, options ...EncryptOption) (string, error) {
+	if strings.Count(signedToken, ".") != 2 {
+		return "", errors.New("nested JWT requires a compact serialized signed JWT")
+	}
+	options = append(options, WithContentType(ContentTypeJWT))
+	return Encrypt(recipient, []byte(signedToken), options...)
+}
+
+// SignAndEncrypt signs the claims and encrypts the token to the recipient
+func SignAndEncrypt(ctx context.Context, signer *jwt.Signer, recipient *jose.JSONWebKey, claims []interface{}, options ...EncryptOption) (string, error) {
+	signedToken, err := signer.Sign(ctx, claims...)
+	if err != nil {
+		return "", err
+	}
+	return EncryptJWT(recipient, signedToken, options...)
+}
+
+// RecipientKey returns the encryption key of the client provided JWKS, JWK, certificate or public key
+func RecipientKey(data []byte) (*jose.JSONWebKey, error) {
+	keys, err := jwk.ParseKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys.Keys {
+		if key.Use == "" || key.Use == "enc" {
+			key := key
+			if publicKey, err := recipientPublicKey(&key); err == nil {
+				return publicKey, nil
+			}
+		}
+	}
+	return nil, errors.New("no public encryption key found")
+}
+
+// Result is the decrypted JWE
+type Result struct {
+	Header  jose.Header
+	Payload []byte
+	Key     *jose.JSONWebKey
+}
+
+// IsNestedJWT returns true if the payload is a nested JWT
+func (r *Result) IsNestedJWT() bool {
+	cty, _ := r.Header.ExtraHeaders[jose.HeaderContentType].(string)
+	return strings.EqualFold(cty, ContentTypeJWT)
+}
+
+// Decrypter decrypts the JWE with the private use=enc keys
+type Decrypter struct {
+	keys *jose.JSONWebKeySet
+}
+
+func NewDecrypter(keys *jose.JSONWebKeySet) *Decrypter {
+	return &Decrypter{keys: keys}
+}
+
+// NewJWKSDecrypter loads the private keys of the stored use=enc JWKS
+func NewJWKSDecrypter(ctx context.Context, dsClient client.Client, kmsProvider kms.Provider, jwksIDs ...string) (*Decrypter, error) {
+	keys := &jose.JSONWebKeySet{}
+	for _, jwksID := range jwksIDs {
+		record, err := dsClient.API().GetJWKS(ctx, jwksID)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			return nil, errors.Errorf("not found JWKS ID: %s", jwksID)
+		}
+		if record.Use != "enc" {
+			return nil, errors.Errorf("JWKS ID %s is not an encryption key: %s", jwksID, record.Use)
+		}
+		if record.IsVaultTransit() {
+			return nil, errors.Errorf("JWKS ID %s private key is not available, key storage %s", jwksID, record.KeyStorage)
+		}
+		recordKeys, err := jwk.DecryptJWKS(kmsProvider, record)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range recordKeys.Keys {
+			if jwk.IsPrivate(&key) {
+				// the JWE kid header is the kid of the public key
+				key.KeyID = jwk.BaseKeyID(key.KeyID)
+				keys.Keys = append(keys.Keys, key)
+			}
+		}
+	}
+	return NewDecrypter(keys), nil
+}
+
+// Decrypt decrypts the compact or JSON serialized JWE. The key is selected by kid, without kid all the keys are tried.
+func (d *Decrypter) Decrypt(token string) (*Result, error) {
+	object, err := jose.ParseEncrypted(token)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse JWE failed")
+	}
+	alg := jose.KeyAlgorithm(object.Header.Algorithm)
+	if _, ok := keyAlgorithms[alg]; !ok {
+		return nil, errors.Errorf("unsupported key management algorithm %s", alg)
+	}
+	var candidates []jose.JSONWebKey
+	if object.Header.KeyID != "" {
+		candidates = d.keys.Key(object.Header.KeyID)
+	} else {
+		candidates = d.keys.Keys
+	}
+	for _, key := range candidates {
+		if !jwk.IsPrivate(&key) || (key.Use != "" && key.Use != "enc") {
+			continue
+		}
+		if key.Algorithm != "" && key.Algorithm != string(alg) {
+			continue
+		}
+		payload, err := object.Decrypt(key.Key)
+		if err != nil {
+			continue
+		}
+		key := key
+		return &Result{
+			Header:  object.Header,
+			Payload: payload,
+			Key:     &key,
+		}, nil
+	}
+	if object.Header.KeyID != "" {
+		return nil, errors.Wrapf(ErrKeyNotFound, "kid %s", object.Header.KeyID)
+	}
+	return nil, ErrKeyNotFound
+}
+
+// DecryptJWT decrypts the nested JWT and verifies the signed token
+func (d *Decrypter) DecryptJWT(ctx context.Context, token string, verifier *jwt.Verifier) (*jwk.Claims, error) {
+	result, err := d.Decrypt(token)
+	if err != nil {
+		return nil, err
+	}
+	if !result.IsNestedJWT() {
+		return nil, errors.New("JWE is not a nested JWT, cty header must be JWT")
+	}
+	return verifier.Verify(ctx, string(result.Payload))
+}
+
+func recipientPublicKey(recipient *jose.JSONWebKey) (*jose.JSONWebKey, error) {
+	if recipient == nil {
+		return nil, errors.New("recipient key is required")
+	}
+	publicKey := *recipient
+	if jwk.IsPrivate(recipient) {
+		publicKey = recipient.Public()
+		publicKey.KeyID = jwk.BaseKeyID(recipient.KeyID)
+	}
+	switch publicKey.Key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return &publicKey, nil
+	default:
+		return nil, errors.Errorf("unsupported recipient key type %T", recipient.Key)
+	}
+}
+
+func keyAlgorithm(recipient *jose.JSONWebKey, alg jose.KeyAlgorithm) (jose.KeyAlgorithm, error) {
+	if alg == "" {
+		alg = jose.KeyAlgorithm(recipient.Algorithm)
+	}
+	if alg == "" {
+		switch recipient.Key.(type) {
+		case *rsa.PublicKey:
+			alg = DefaultRSAKeyAlgorithm
+		case *ecdsa.PublicKey:
+			alg = DefaultECKeyAlgorithm
+		}
+	}
+	if _, ok := keyAlgorithms[alg]; !ok {
+		return "", errors.Errorf("unsupported key management algorithm %s", alg)
+	}
+	return alg, nil
+}
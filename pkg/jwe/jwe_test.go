@@ -0,0 +1,211 @@
+package jwe
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+)
+
+func newTestEncKeys(t *testing.T, kid string, alg jose.KeyAlgorithm) *jose.JSONWebKeySet {
+	keys, err := jwk.NewJWKSGenerator().Generate(kid, string(alg), "enc")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return keys
+}
+
+func privateKeys(keys ...*jose.JSONWebKeySet) *jose.JSONWebKeySet {
+	result := &jose.JSONWebKeySet{}
+	for _, set := range keys {
+		for _, key := range set.Keys {
+			if jwk.IsPrivate(&key) {
+				key.KeyID = jwk.BaseKeyID(key.KeyID)
+				result.Keys = append(result.Keys, key)
+			}
+		}
+	}
+	return result
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, alg := range []jose.KeyAlgorithm{jose.RSA_OAEP, jose.RSA_OAEP_256, jose.ECDH_ES, jose.ECDH_ES_A256KW} {
+		t.Run(string(alg), func(t *testing.T) {
+			keys := newTestEncKeys(t, "enc-key", alg)
+			otherKeys := newTestEncKeys(t, "other-key", alg)
+			recipient, err := jwk.PublicKey("enc-key", keys)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			token, err := Encrypt(recipient, []byte("payload"))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			result, err := NewDecrypter(privateKeys(otherKeys, keys)).Decrypt(token)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, []byte("payload"), result.Payload)
+			assert.Equal(t, "enc-key", result.Header.KeyID)
+			assert.Equal(t, string(alg), result.Header.Algorithm)
+			assert.Equal(t, string(DefaultContentEncryption), result.Header.ExtraHeaders[jose.HeaderKey("enc")])
+			assert.False(t, result.IsNestedJWT())
+
+			_, err = NewDecrypter(privateKeys(otherKeys)).Decrypt(token)
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+		})
+	}
+}
+
+func TestEncryptNestedJWT(t *testing.T) {
+	encKeys := newTestEncKeys(t, "enc-key", jose.RSA_OAEP_256)
+	sigKeys, err := jwk.NewJWKSGenerator().Generate("sig-key", "ES256", "sig")
+	if !assert.NoError(t, err) {
+		return
+	}
+	sigPublic, err := jwk.PublicKey("sig-key", sigKeys)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: sigKeys.Keys[1].Key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "sig-key"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	signedToken, err := josejwt.Signed(signer).Claims(josejwt.Claims{
+		Subject: "alice",
+		Expiry:  josejwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}).CompactSerialize()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	recipient, err := jwk.PublicKey("enc-key", encKeys)
+	if !assert.NoError(t, err) {
+		return
+	}
+	token, err := EncryptJWT(recipient, signedToken, WithContentEncryption(jose.A256GCM))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	decrypter := NewDecrypter(privateKeys(encKeys))
+	verifier := jwt.NewKeySourceVerifier(jwk.NewStaticKeySource(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*sigPublic}}))
+	claims, err := decrypter.DecryptJWT(context.Background(), token, verifier)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "alice", claims.Subject)
+
+	plain, err := Encrypt(recipient, []byte(signedToken))
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = decrypter.DecryptJWT(context.Background(), plain, verifier)
+	assert.Error(t, err, "cty JWT is required")
+
+	_, err = EncryptJWT(recipient, "not-a-jwt")
+	assert.Error(t, err)
+}
+
+func TestRecipientKey(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, privateKey.Public(), privateKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if !assert.NoError(t, err) {
+		return
+	}
+	jwkData, err := json.Marshal(jose.JSONWebKey{Key: privateKey.Public(), KeyID: "client-key", Use: "enc"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	sigJwkData, err := json.Marshal(jose.JSONWebKey{Key: privateKey.Public(), KeyID: "client-key", Use: "sig"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  bool
+	}{
+		{name: "certificate", data: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})},
+		{name: "public key", data: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})},
+		{name: "jwk", data: jwkData},
+		{name: "sig jwk", data: sigJwkData, err: true},
+		{name: "garbage", data: []byte("garbage"), err: true},
+	}
+	decrypter := NewDecrypter(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: privateKey, KeyID: "client-key"}}})
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recipient, err := RecipientKey(tc.data)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			token, err := Encrypt(recipient, []byte("payload"))
+			if !assert.NoError(t, err) {
+				return
+			}
+			result, err := decrypter.Decrypt(token)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, string(DefaultRSAKeyAlgorithm), result.Header.Algorithm)
+			assert.Equal(t, []byte("payload"), result.Payload)
+		})
+	}
+}
+
+func TestDecryptRejectsRSA1_5(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	encrypter, err := jose.NewEncrypter(jose.A128CBC_HS256, jose.Recipient{Algorithm: jose.RSA1_5, Key: privateKey.Public()}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	object, err := encrypter.Encrypt([]byte("payload"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	token, err := object.CompactSerialize()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = NewDecrypter(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: privateKey}}}).Decrypt(token)
+	assert.Error(t, err)
+}
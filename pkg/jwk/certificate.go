@@ -0,0 +1,187 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+const DefaultCertificateValidity = 365 * 24 * time.Hour
+
+// CertificateConfig configures the certificates of the generated key pairs
+type CertificateConfig struct {
+	// Subject of the certificate, the common name is the kid if not set
+	Subject pkix.Name
+	// Validity is the lifetime of the certificate
+	Validity time.Duration
+	// CA issues the certificate, the certificate is self-signed if not set
+	CA *CertificateAuthority
+}
+
+// CertificateAuthority is a local CA issuing the certificates of the key pairs
+type CertificateAuthority struct {
+	// Certificates is the chain of the CA, the CA certificate is the first one
+	Certificates []*x509.Certificate
+	Key          crypto.Signer
+}
+
+// LoadCertificateAuthority reads the PEM encoded CA certificate chain and its private key
+func LoadCertificateAuthority(certFile, keyFile string) (*CertificateAuthority, error) {
+	certData, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read CA certificate file failed")
+	}
+	certKeys, err := ParseKeys(certData)
+	if err != nil {
+		return nil, err
+	}
+	var certificates []*x509.Certificate
+	for _, key := range certKeys.Keys {
+		certificates = append(certificates, key.Certificates...)
+	}
+	if len(certificates) == 0 {
+		return nil, errors.Errorf("no certificate found in %s", certFile)
+	}
+	if !certificates[0].IsCA {
+		return nil, errors.Errorf("certificate %s is not a CA", certificates[0].Subject)
+	}
+	keyData, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read CA key file failed")
+	}
+	keys, err := ParseKeys(keyData)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys.Keys {
+		if signer, ok := key.Key.(crypto.Signer); ok && IsPrivate(&key) {
+			if !publicKeyEqual(signer.Public(), certificates[0].PublicKey) {
+				return nil, errors.New("CA key does not match the CA certificate")
+			}
+			return &CertificateAuthority{Certificates: certificates, Key: signer}, nil
+		}
+	}
+	return nil, errors.Errorf("no private key found in %s", keyFile)
+}
+
+// ParseSubject parses a distinguished name like "CN=tribe,O=grepplabs,C=DE". Supported attributes are CN, O, OU, C, L, ST.
+func ParseSubject(subject string) (pkix.Name, error) {
+	var name pkix.Name
+	if strings.TrimSpace(subject) == "" {
+		return name, nil
+	}
+	for _, rdn := range strings.Split(subject, ",") {
+		kv := strings.SplitN(rdn, "=", 2)
+		if len(kv) != 2 {
+			return name, errors.Errorf("invalid subject attribute %s", rdn)
+		}
+		value := strings.TrimSpace(kv[1])
+		switch strings.ToUpper(strings.TrimSpace(kv[0])) {
+		case "CN":
+			name.CommonName = value
+		case "O":
+			name.Organization = append(name.Organization, value)
+		case "OU":
+			name.OrganizationalUnit = append(name.OrganizationalUnit, value)
+		case "C":
+			name.Country = append(name.Country, value)
+		case "L":
+			name.Locality = append(name.Locality, value)
+		case "ST":
+			name.Province = append(name.Province, value)
+		default:
+			return name, errors.Errorf("unsupported subject attribute %s", kv[0])
+		}
+	}
+	return name, nil
+}
+
+// NewCertificate issues the certificate of the public key and returns the chain. The self-signed certificate is signed
+// by the private key, which is required without a CA.
+func NewCertificate(config *CertificateConfig, kid, use string, publicKey crypto.PublicKey, privateKey crypto.PrivateKey) ([]*x509.Certificate, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "generate serial number failed")
+	}
+	validity := config.Validity
+	if validity <= 0 {
+		validity = DefaultCertificateValidity
+	}
+	subject := config.Subject
+	if subject.CommonName == "" {
+		subject.CommonName = kid
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               subject,
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              certificateKeyUsage(use, publicKey),
+		BasicConstraintsValid: true,
+	}
+	var (
+		parent = template
+		signer crypto.Signer
+		chain  []*x509.Certificate
+	)
+	if config.CA != nil {
+		parent = config.CA.Certificates[0]
+		signer = config.CA.Key
+		chain = config.CA.Certificates
+	} else {
+		var ok bool
+		if signer, ok = privateKey.(crypto.Signer); !ok {
+			return nil, errors.New("self-signed certificate requires the private key")
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey, signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "create certificate failed")
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse certificate failed")
+	}
+	return append([]*x509.Certificate{certificate}, chain...), nil
+}
+
+// AttachCertificates sets the x5c, x5t and x5t#S256 of the key
+func AttachCertificates(key *jose.JSONWebKey, chain []*x509.Certificate) {
+	if len(chain) == 0 {
+		return
+	}
+	sha1Sum := sha1.Sum(chain[0].Raw)
+	sha256Sum := sha256.Sum256(chain[0].Raw)
+	key.Certificates = chain
+	key.CertificateThumbprintSHA1 = sha1Sum[:]
+	key.CertificateThumbprintSHA256 = sha256Sum[:]
+}
+
+func certificateKeyUsage(use string, publicKey crypto.PublicKey) x509.KeyUsage {
+	if use == "enc" {
+		if _, ok := publicKey.(*rsa.PublicKey); ok {
+			return x509.KeyUsageKeyEncipherment
+		}
+		return x509.KeyUsageKeyAgreement
+	}
+	return x509.KeyUsageDigitalSignature
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	if key, ok := a.(interface{ Equal(crypto.PublicKey) bool }); ok {
+		return key.Equal(b)
+	}
+	return false
+}
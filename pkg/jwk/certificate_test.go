@@ -0,0 +1,164 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestGenerateSelfSignedCertificate(t *testing.T) {
+	tests := []struct {
+		alg      string
+		use      string
+		keyUsage x509.KeyUsage
+	}{
+		{alg: "RS256", use: "sig", keyUsage: x509.KeyUsageDigitalSignature},
+		{alg: "ES256", use: "sig", keyUsage: x509.KeyUsageDigitalSignature},
+		{alg: "EdDSA", use: "sig", keyUsage: x509.KeyUsageDigitalSignature},
+		{alg: "RSA-OAEP-256", use: "enc", keyUsage: x509.KeyUsageKeyEncipherment},
+		{alg: "ECDH-ES", use: "enc", keyUsage: x509.KeyUsageKeyAgreement},
+	}
+	for _, tc := range tests {
+		t.Run(tc.alg, func(t *testing.T) {
+			subject, err := ParseSubject("CN=tribe, O=grepplabs")
+			if !assert.NoError(t, err) {
+				return
+			}
+			gen := NewJWKSGenerator(WithCertificate(&CertificateConfig{Subject: subject, Validity: time.Hour}))
+			keys, err := gen.Generate("cert-key", tc.alg, tc.use)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			// keys are stored as JSON
+			data, err := json.Marshal(keys)
+			if !assert.NoError(t, err) {
+				return
+			}
+			var stored jose.JSONWebKeySet
+			if !assert.NoError(t, json.Unmarshal(data, &stored)) {
+				return
+			}
+
+			publicKey, err := PublicKey("cert-key", &stored)
+			if !assert.NoError(t, err) {
+				return
+			}
+			if !assert.Equal(t, 1, len(publicKey.Certificates)) {
+				return
+			}
+			certificate := publicKey.Certificates[0]
+			assert.Equal(t, "tribe", certificate.Subject.CommonName)
+			assert.Equal(t, []string{"grepplabs"}, certificate.Subject.Organization)
+			assert.Equal(t, tc.keyUsage, certificate.KeyUsage)
+			assert.True(t, publicKeyEqual(certificate.PublicKey, publicKey.Key))
+			assert.WithinDuration(t, time.Now().Add(time.Hour), certificate.NotAfter, time.Minute)
+
+			sha256Sum := sha256.Sum256(certificate.Raw)
+			assert.Equal(t, sha256Sum[:], publicKey.CertificateThumbprintSHA256)
+			assert.Equal(t, 20, len(publicKey.CertificateThumbprintSHA1))
+			assert.Contains(t, string(data), `"x5t#S256"`)
+			if tc.use == "sig" {
+				assert.NoError(t, certificate.CheckSignature(certificate.SignatureAlgorithm, certificate.RawTBSCertificate, certificate.Signature))
+			}
+		})
+	}
+}
+
+func TestGenerateCACertificate(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tribe test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	caKeyDER, err := x509.MarshalPKCS8PrivateKey(caKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	caKeyFile := filepath.Join(dir, "ca-key.pem")
+	if !assert.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0600)) {
+		return
+	}
+	if !assert.NoError(t, ioutil.WriteFile(caKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: caKeyDER}), 0600)) {
+		return
+	}
+
+	ca, err := LoadCertificateAuthority(caFile, caKeyFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	keys, err := NewJWKSGenerator(WithCertificate(&CertificateConfig{CA: ca})).Generate("ca-key", "RS256", "sig")
+	if !assert.NoError(t, err) {
+		return
+	}
+	publicKey, err := PublicKey("ca-key", keys)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.Equal(t, 2, len(publicKey.Certificates)) {
+		return
+	}
+	assert.Equal(t, "ca-key", publicKey.Certificates[0].Subject.CommonName)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(publicKey.Certificates[1])
+	_, err = publicKey.Certificates[0].Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	assert.NoError(t, err)
+
+	// the key does not match the CA certificate
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	otherKeyDER, err := x509.MarshalECPrivateKey(otherKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, ioutil.WriteFile(caKeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: otherKeyDER}), 0600)) {
+		return
+	}
+	_, err = LoadCertificateAuthority(caFile, caKeyFile)
+	assert.Error(t, err)
+}
+
+func TestParseSubject(t *testing.T) {
+	name, err := ParseSubject("CN=tribe,O=grepplabs,OU=iam,C=DE,L=Berlin,ST=Berlin")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "tribe", name.CommonName)
+	assert.Equal(t, []string{"DE"}, name.Country)
+
+	_, err = ParseSubject("CN")
+	assert.Error(t, err)
+	_, err = ParseSubject("XX=tribe")
+	assert.Error(t, err)
+}
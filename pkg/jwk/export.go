@@ -0,0 +1,237 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// export formats of the stored keys
+const (
+	ExportFormatPEM       = "pem"
+	ExportFormatPKCS8     = "pkcs8"
+	ExportFormatPKIX      = "pkix"
+	ExportFormatSSH       = "ssh"
+	ExportFormatJWKPublic = "jwk-public"
+)
+
+// ExportFormats lists the supported export formats
+var ExportFormats = []string{ExportFormatPEM, ExportFormatPKCS8, ExportFormatPKIX, ExportFormatSSH, ExportFormatJWKPublic}
+
+// Export encodes the keys in the format:
+//
+//	pem        - PKIX public keys, PKCS#1 (RSA), SEC1 (EC) or PKCS#8 (Ed25519) private keys and the certificates
+//	pkcs8      - PKCS#8 private keys
+//	pkix       - PKIX public keys
+//	ssh        - public keys in the authorized_keys format
+//	jwk-public - JWKS with the public keys
+//
+// The public keys are derived from the private keys if the set holds no public key of the kid.
+// The symmetric keys cannot be exported.
+func Export(keys *jose.JSONWebKeySet, format string, publicOnly bool) ([]byte, error) {
+	switch format {
+	case ExportFormatPEM:
+		return exportPEM(keys, publicOnly)
+	case ExportFormatPKCS8:
+		if publicOnly {
+			return nil, errors.Errorf("format %s holds private keys only, use %s for the public keys", format, ExportFormatPKIX)
+		}
+		return exportPKCS8(keys)
+	case ExportFormatPKIX:
+		return exportPKIX(keys)
+	case ExportFormatSSH:
+		return exportSSH(keys)
+	case ExportFormatJWKPublic:
+		publicKeys, err := exportPublicKeys(keys)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.MarshalIndent(JSONKeySet{JSONWebKeySet: &jose.JSONWebKeySet{Keys: publicKeys}}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	default:
+		return nil, errors.Errorf("unsupported export format %s", format)
+	}
+}
+
+// PublicKeys returns the JWKS with the public keys only
+func PublicKeys(keys *jose.JSONWebKeySet) (*jose.JSONWebKeySet, error) {
+	publicKeys, err := exportPublicKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	return &jose.JSONWebKeySet{Keys: publicKeys}, nil
+}
+
+// exportPublicKeys returns the public keys and the public keys of the private keys without the public counterpart
+func exportPublicKeys(keys *jose.JSONWebKeySet) ([]jose.JSONWebKey, error) {
+	var result []jose.JSONWebKey
+	seen := make(map[string]struct{})
+	for i := range keys.Keys {
+		if IsPublic(&keys.Keys[i]) {
+			result = append(result, keys.Keys[i])
+			seen[keys.Keys[i].KeyID] = struct{}{}
+		}
+	}
+	for i := range keys.Keys {
+		if !IsPrivate(&keys.Keys[i]) {
+			continue
+		}
+		kid := BaseKeyID(keys.Keys[i].KeyID)
+		if _, ok := seen[kid]; ok {
+			continue
+		}
+		publicKey := keys.Keys[i].Public()
+		publicKey.KeyID = kid
+		result = append(result, publicKey)
+		seen[kid] = struct{}{}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no public key found, symmetric keys cannot be exported")
+	}
+	return result, nil
+}
+
+func exportPrivateKeys(keys *jose.JSONWebKeySet) ([]jose.JSONWebKey, error) {
+	var result []jose.JSONWebKey
+	for i := range keys.Keys {
+		if IsPrivate(&keys.Keys[i]) {
+			result = append(result, keys.Keys[i])
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no private key found, the key is symmetric or held by the key storage")
+	}
+	return result, nil
+}
+
+func exportPEM(keys *jose.JSONWebKeySet, publicOnly bool) ([]byte, error) {
+	var buf bytes.Buffer
+	publicKeys, err := exportPublicKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	for i := range publicKeys {
+		if err = encodePKIX(&buf, &publicKeys[i]); err != nil {
+			return nil, err
+		}
+	}
+	if !publicOnly {
+		privateKeys, err := exportPrivateKeys(keys)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range privateKeys {
+			block, err := privateKeyBlock(&key)
+			if err != nil {
+				return nil, err
+			}
+			if err = pem.Encode(&buf, block); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// the public and private keys of a pair carry the same certificate chain
+	for _, key := range publicKeys {
+		for _, certificate := range key.Certificates {
+			if err = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func exportPKCS8(keys *jose.JSONWebKeySet) ([]byte, error) {
+	var buf bytes.Buffer
+	privateKeys, err := exportPrivateKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range privateKeys {
+		der, err := x509.MarshalPKCS8PrivateKey(key.Key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal PKCS#8 private key %s failed", key.KeyID)
+		}
+		if err = pem.Encode(&buf, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func exportPKIX(keys *jose.JSONWebKeySet) ([]byte, error) {
+	var buf bytes.Buffer
+	publicKeys, err := exportPublicKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	for i := range publicKeys {
+		if err = encodePKIX(&buf, &publicKeys[i]); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func exportSSH(keys *jose.JSONWebKeySet) ([]byte, error) {
+	var buf bytes.Buffer
+	publicKeys, err := exportPublicKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range publicKeys {
+		publicKey, err := ssh.NewPublicKey(key.Key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "convert public key %s to ssh failed", key.KeyID)
+		}
+		line := bytes.TrimSuffix(ssh.MarshalAuthorizedKey(publicKey), []byte("\n"))
+		buf.Write(line)
+		if key.KeyID != "" {
+			// the kid is the comment of the authorized key
+			buf.WriteString(" " + key.KeyID)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+func encodePKIX(buf *bytes.Buffer, key *jose.JSONWebKey) error {
+	der, err := x509.MarshalPKIXPublicKey(key.Key)
+	if err != nil {
+		return errors.Wrapf(err, "marshal PKIX public key %s failed", key.KeyID)
+	}
+	return pem.Encode(buf, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// privateKeyBlock encodes the private key in the traditional format, Ed25519 keys have PKCS#8 only
+func privateKeyBlock(key *jose.JSONWebKey) (*pem.Block, error) {
+	switch privateKey := key.Key.(type) {
+	case *rsa.PrivateKey:
+		return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}, nil
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal EC private key %s failed", key.KeyID)
+		}
+		return &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}, nil
+	case ed25519.PrivateKey:
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal PKCS#8 private key %s failed", key.KeyID)
+		}
+		return &pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil
+	default:
+		return nil, errors.Errorf("unsupported private key type %T", key.Key)
+	}
+}
@@ -0,0 +1,136 @@
+package jwk
+
+import (
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestExport(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			keys, err := NewJWKSGenerator().Generate("export-key", alg, "sig")
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			// pem round trip keeps the key pair
+			data, err := Export(keys, ExportFormatPEM, false)
+			if !assert.Nil(t, err) {
+				return
+			}
+			parsed, err := ParseKeys(data)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, 2, len(parsed.Keys))
+			assert.True(t, IsPublic(&parsed.Keys[0]))
+			assert.True(t, IsPrivate(&parsed.Keys[1]))
+
+			data, err = Export(keys, ExportFormatPEM, true)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, 1, strings.Count(string(data), "-----BEGIN PUBLIC KEY-----"))
+			assert.NotContains(t, string(data), "PRIVATE KEY")
+
+			data, err = Export(keys, ExportFormatPKCS8, false)
+			if !assert.Nil(t, err) {
+				return
+			}
+			block, _ := pem.Decode(data)
+			if !assert.NotNil(t, block) {
+				return
+			}
+			assert.Equal(t, "PRIVATE KEY", block.Type)
+
+			_, err = Export(keys, ExportFormatPKCS8, true)
+			assert.NotNil(t, err)
+
+			data, err = Export(keys, ExportFormatPKIX, false)
+			if !assert.Nil(t, err) {
+				return
+			}
+			block, rest := pem.Decode(data)
+			if !assert.NotNil(t, block) {
+				return
+			}
+			assert.Equal(t, "PUBLIC KEY", block.Type)
+			assert.Empty(t, rest)
+
+			data, err = Export(keys, ExportFormatSSH, false)
+			if !assert.Nil(t, err) {
+				return
+			}
+			_, comment, _, _, err := ssh.ParseAuthorizedKey(data)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, "export-key", comment)
+
+			data, err = Export(keys, ExportFormatJWKPublic, false)
+			if !assert.Nil(t, err) {
+				return
+			}
+			parsed, err = ParseKeys(data)
+			if !assert.Nil(t, err) {
+				return
+			}
+			if !assert.Equal(t, 1, len(parsed.Keys)) {
+				return
+			}
+			assert.Equal(t, "export-key", parsed.Keys[0].KeyID)
+			assert.True(t, IsPublic(&parsed.Keys[0]))
+		})
+	}
+}
+
+func TestExportPrivateKeyOnly(t *testing.T) {
+	keys, err := NewJWKSGenerator().Generate("export-key", "ES384", "sig")
+	if !assert.Nil(t, err) {
+		return
+	}
+	keys.Keys = keys.Keys[1:]
+
+	// the public key is derived from the private key
+	publicKeys, err := PublicKeys(keys)
+	if !assert.Nil(t, err) {
+		return
+	}
+	if !assert.Equal(t, 1, len(publicKeys.Keys)) {
+		return
+	}
+	assert.Equal(t, "export-key", publicKeys.Keys[0].KeyID)
+	assert.True(t, IsPublic(&publicKeys.Keys[0]))
+}
+
+func TestExportCertificate(t *testing.T) {
+	keys, err := NewJWKSGenerator(WithCertificate(&CertificateConfig{Validity: time.Hour})).Generate("export-key", "RS256", "sig")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	data, err := Export(keys, ExportFormatPEM, true)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, 1, strings.Count(string(data), "-----BEGIN CERTIFICATE-----"))
+}
+
+func TestExportSymmetric(t *testing.T) {
+	keys, err := NewJWKSGenerator().Generate("export-key", "HS256", "sig")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, format := range ExportFormats {
+		_, err = Export(keys, format, false)
+		assert.NotNil(t, err, format)
+	}
+	_, err = Export(keys, "der", false)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,172 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/grepplabs/tribe/pkg/jwk/keygen"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/pkcs12"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// ParsePKCS12 parses the PKCS#12 bundle, the private key holds the certificate chain
+func ParsePKCS12(data []byte, password string) (*jose.JSONWebKeySet, error) {
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode PKCS#12 failed")
+	}
+	var pemData []byte
+	for _, block := range blocks {
+		pemData = append(pemData, pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: block.Bytes})...)
+	}
+	return ParseKeys(pemData)
+}
+
+// ImportKey converts the imported private or symmetric key to the JWKS stored by tribe. The key is validated against
+// the alg and use, the certificates matching the key are kept.
+func ImportKey(keys *jose.JSONWebKeySet, sourceKid, kid, alg, use string) (*jose.JSONWebKeySet, error) {
+	if err := ValidateAlgUse(alg, use); err != nil {
+		return nil, err
+	}
+	key, err := SelectImportKey(keys, sourceKid)
+	if err != nil {
+		return nil, err
+	}
+	if key.Algorithm != "" && key.Algorithm != alg {
+		return nil, errors.Errorf("key alg %s does not match alg %s", key.Algorithm, alg)
+	}
+	if key.Use != "" && key.Use != use {
+		return nil, errors.Errorf("key use %s does not match use %s", key.Use, use)
+	}
+	if kid == "" {
+		kid = BaseKeyID(key.KeyID)
+	}
+	if kid == "" {
+		return nil, errors.New("kid is required, the imported key has no kid")
+	}
+	if err = validateImportKey(key.Key, alg); err != nil {
+		return nil, err
+	}
+	certificates := key.Certificates
+	if len(certificates) == 0 {
+		certificates = matchingCertificates(keys, key.Key)
+	}
+	if symmetric, ok := key.Key.([]byte); ok {
+		return &jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{
+				{
+					Algorithm: alg,
+					Use:       use,
+					Key:       symmetric,
+					KeyID:     kid,
+				},
+			},
+		}, nil
+	}
+	privateKey, ok := key.Key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("unsupported private key type %T", key.Key)
+	}
+	result, err := jwksGenerator{}.keyPair(kid, alg, use, privateKey.Public(), privateKey)
+	if err != nil {
+		return nil, err
+	}
+	for i := range result.Keys {
+		AttachCertificates(&result.Keys[i], certificates)
+	}
+	return result, nil
+}
+
+// SelectImportKey returns the private or symmetric key to import, the source kid is required if there are several keys
+func SelectImportKey(keys *jose.JSONWebKeySet, sourceKid string) (*jose.JSONWebKey, error) {
+	var candidates []jose.JSONWebKey
+	for _, key := range keys.Keys {
+		if sourceKid != "" && key.KeyID != sourceKid {
+			continue
+		}
+		if _, ok := key.Key.([]byte); ok || IsPrivate(&key) {
+			candidates = append(candidates, key)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		if sourceKid != "" {
+			return nil, errors.Errorf("no private or symmetric key with kid %s found", sourceKid)
+		}
+		return nil, errors.New("no private or symmetric key found, public keys cannot be imported")
+	case 1:
+		return &candidates[0], nil
+	default:
+		return nil, errors.Errorf("found %d private keys, select the key with the source kid", len(candidates))
+	}
+}
+
+// matchingCertificates returns the certificate chain of the private key e.g. from the PEM or PKCS#12 certificates
+func matchingCertificates(keys *jose.JSONWebKeySet, privateKey interface{}) []*x509.Certificate {
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil
+	}
+	for _, key := range keys.Keys {
+		if len(key.Certificates) != 0 && publicKeyEqual(signer.Public(), key.Certificates[0].PublicKey) {
+			return key.Certificates
+		}
+	}
+	return nil
+}
+
+// validateImportKey checks the key type and strength required by the alg
+func validateImportKey(key interface{}, alg string) error {
+	switch k := key.(type) {
+	case []byte:
+		minSize := map[string]int{"HS256": 32, "HS384": 48, "HS512": 64}[alg]
+		if minSize == 0 {
+			return errors.Errorf("symmetric key cannot be used with alg %s", alg)
+		}
+		if len(k) < minSize {
+			return errors.Errorf("too short key for %s, %d+ bytes are required", alg, minSize)
+		}
+	case *rsa.PrivateKey:
+		switch alg {
+		case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "RSA-OAEP", "RSA-OAEP-256":
+		default:
+			return errors.Errorf("RSA key cannot be used with alg %s", alg)
+		}
+		if k.N.BitLen() < keygen.RSAMinKeySize {
+			return errors.Errorf("too short key for RSA `alg`, %d+ is required", keygen.RSAMinKeySize)
+		}
+		if err := k.Validate(); err != nil {
+			return errors.Wrap(err, "invalid RSA key")
+		}
+	case *ecdsa.PrivateKey:
+		curves := map[string]elliptic.Curve{"ES256": elliptic.P256(), "ES384": elliptic.P384(), "ES512": elliptic.P521(), "ES256K": secp256k1.S256()}
+		switch alg {
+		case "ES256", "ES384", "ES512", "ES256K":
+			if k.Curve != curves[alg] {
+				return errors.Errorf("alg %s requires curve %s, but %s", alg, curves[alg].Params().Name, k.Curve.Params().Name)
+			}
+		case "ECDH-ES", "ECDH-ES+A128KW", "ECDH-ES+A192KW", "ECDH-ES+A256KW":
+			switch k.Curve {
+			case elliptic.P256(), elliptic.P384(), elliptic.P521():
+			default:
+				return errors.Errorf("unsupported curve %s", k.Curve.Params().Name)
+			}
+		default:
+			return errors.Errorf("EC key cannot be used with alg %s", alg)
+		}
+	case ed25519.PrivateKey:
+		if alg != "EdDSA" {
+			return errors.Errorf("Ed25519 key cannot be used with alg %s", alg)
+		}
+	default:
+		return errors.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestImportKeyPEM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	shortRSAKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pkcs1PEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	pkcs8PEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})
+	sec1PEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1})
+	shortPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(shortRSAKey)})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+
+	tests := []struct {
+		name string
+		data []byte
+		alg  string
+		use  string
+		err  bool
+	}{
+		{name: "PKCS#1 RS256", data: pkcs1PEM, alg: "RS256", use: "sig"},
+		{name: "PKCS#8 PS512", data: pkcs8PEM, alg: "PS512", use: "sig"},
+		{name: "PKCS#8 RSA-OAEP-256", data: pkcs8PEM, alg: "RSA-OAEP-256", use: "enc"},
+		{name: "SEC1 ES384", data: sec1PEM, alg: "ES384", use: "sig"},
+		{name: "SEC1 ECDH-ES", data: sec1PEM, alg: "ECDH-ES", use: "enc"},
+		{name: "SEC1 wrong curve", data: sec1PEM, alg: "ES256", use: "sig", err: true},
+		{name: "RSA as EC", data: pkcs1PEM, alg: "ES256", use: "sig", err: true},
+		{name: "RSA as EdDSA", data: pkcs1PEM, alg: "EdDSA", use: "sig", err: true},
+		{name: "short RSA", data: shortPEM, alg: "RS256", use: "sig", err: true},
+		{name: "public key", data: publicPEM, alg: "RS256", use: "sig", err: true},
+		{name: "alg use mismatch", data: pkcs1PEM, alg: "RSA-OAEP", use: "sig", err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			source, err := ParseKeys(tc.data)
+			if !assert.NoError(t, err) {
+				return
+			}
+			keys, err := ImportKey(source, "", "imported", tc.alg, tc.use)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			if !assert.Equal(t, 2, len(keys.Keys)) {
+				return
+			}
+			assert.Equal(t, "imported", keys.Keys[0].KeyID)
+			assert.Equal(t, "private-imported", keys.Keys[1].KeyID)
+			for _, key := range keys.Keys {
+				assert.Equal(t, tc.alg, key.Algorithm)
+				assert.Equal(t, tc.use, key.Use)
+			}
+			_, err = PublicKey("imported", keys)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestImportKeyJWK(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	source := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: ecKey.Public(), KeyID: "legacy-1", Algorithm: "ES256", Use: "sig"},
+		{Key: ecKey, KeyID: "legacy-1", Algorithm: "ES256", Use: "sig"},
+		{Key: []byte("0123456789abcdef0123456789abcdef"), KeyID: "legacy-hs", Algorithm: "HS256", Use: "sig"},
+		{Key: []byte("short"), KeyID: "legacy-short", Algorithm: "HS256", Use: "sig"},
+	}}
+	data, err := json.Marshal(source)
+	if !assert.NoError(t, err) {
+		return
+	}
+	parsed, err := ParseKeys(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = SelectImportKey(parsed, "")
+	assert.Error(t, err, "several keys require the source kid")
+
+	// the kid of the source key is kept
+	keys, err := ImportKey(parsed, "legacy-1", "", "ES256", "sig")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "legacy-1", keys.Keys[0].KeyID)
+	assert.True(t, IsPublic(&keys.Keys[0]))
+	assert.True(t, IsPrivate(&keys.Keys[1]))
+
+	_, err = ImportKey(parsed, "legacy-1", "", "ES384", "sig")
+	assert.Error(t, err, "alg must match the source alg")
+
+	keys, err = ImportKey(parsed, "legacy-hs", "", "HS256", "sig")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1, len(keys.Keys))
+
+	_, err = ImportKey(parsed, "legacy-short", "", "HS256", "sig")
+	assert.Error(t, err)
+}
+
+func TestImportKeyPKCS12(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/legacy.p12")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = ParsePKCS12(data, "wrong")
+	assert.Error(t, err)
+
+	source, err := ParsePKCS12(data, "secret")
+	if !assert.NoError(t, err) {
+		return
+	}
+	keys, err := ImportKey(source, "", "legacy-idp", "RS256", "sig")
+	if !assert.NoError(t, err) {
+		return
+	}
+	publicKey, err := PublicKey("legacy-idp", keys)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.Equal(t, 1, len(publicKey.Certificates)) {
+		return
+	}
+	assert.Equal(t, "legacy-idp", publicKey.Certificates[0].Subject.CommonName)
+	assert.Equal(t, 32, len(publicKey.CertificateThumbprintSHA256))
+}
@@ -1,14 +1,17 @@
 package jwk
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rsa"
+	"encoding/base64"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/grepplabs/tribe/pkg/jwk/keygen"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ed25519"
 	"gopkg.in/square/go-jose.v2"
+	"strings"
 )
 
 const (
@@ -20,27 +23,212 @@ type JWKSGenerator interface {
 	Generate(id, alg, use string) (*jose.JSONWebKeySet, error)
 }
 
-func NewJWKSGenerator() JWKSGenerator {
-	return &jwksGenerator{}
+// key id strategies, https://tools.ietf.org/html/rfc7638
+const (
+	// KeyIDStrategyUUID uses the provided id or a random UUID
+	KeyIDStrategyUUID = "uuid"
+	// KeyIDStrategyThumbprint uses the RFC 7638 SHA-256 thumbprint of the public key
+	KeyIDStrategyThumbprint = "thumbprint"
+	// KeyIDStrategyCustom requires the id to be provided
+	KeyIDStrategyCustom = "custom"
+)
+
+type GeneratorOption func(*jwksGenerator)
+
+// WithKeyIDStrategy sets how the key id is computed, the default is KeyIDStrategyUUID
+func WithKeyIDStrategy(strategy string) GeneratorOption {
+	return func(g *jwksGenerator) {
+		g.keyIDStrategy = strategy
+	}
+}
+
+// WithKeySize sets the RSA modulus length in bits
+func WithKeySize(bits int) GeneratorOption {
+	return func(g *jwksGenerator) {
+		g.keySize = bits
+	}
+}
+
+// WithCurve sets the elliptic curve of the EC keys. One of: P-256, P-384, P-521 or secp256k1 of the ES256K keys
+func WithCurve(crv string) GeneratorOption {
+	return func(g *jwksGenerator) {
+		g.curve = crv
+	}
+}
+
+// WithCertificate issues a certificate for each generated key pair, the symmetric keys have no certificate
+func WithCertificate(config *CertificateConfig) GeneratorOption {
+	return func(g *jwksGenerator) {
+		g.certificate = config
+	}
+}
+
+func NewJWKSGenerator(options ...GeneratorOption) JWKSGenerator {
+	g := &jwksGenerator{}
+	for _, option := range options {
+		option(g)
+	}
+	return g
 }
 
 type jwksGenerator struct {
+	certificate   *CertificateConfig
+	keyIDStrategy string
+	keySize       int
+	curve         string
+}
+
+// curveBits maps the JWA curve names to the key length of the keygen
+var curveBits = map[string]int{
+	"P-256": 256,
+	"P-384": 384,
+	"P-521": 521,
+}
+
+// ecSignatureCurves are the curves bound to the ECDSA signature algorithms
+var ecSignatureCurves = map[string]string{
+	"ES256":  "P-256",
+	"ES384":  "P-384",
+	"ES512":  "P-521",
+	"ES256K": curveSecp256k1,
+}
+
+// key management algorithms of use=enc keys, https://tools.ietf.org/html/rfc7518#section-4.1
+var encAlgorithms = map[string]struct{}{
+	string(jose.RSA_OAEP):       {},
+	string(jose.RSA_OAEP_256):   {},
+	string(jose.ECDH_ES):        {},
+	string(jose.ECDH_ES_A128KW): {},
+	string(jose.ECDH_ES_A192KW): {},
+	string(jose.ECDH_ES_A256KW): {},
+}
+
+// ValidateAlgUse checks the JWA alg is valid for the intended use of the key
+func ValidateAlgUse(alg, use string) error {
+	switch use {
+	case "sig":
+		switch alg {
+		case "HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "ES256K", "PS256", "PS384", "PS512", "EdDSA":
+			return nil
+		case "none":
+			return errors.New("unsecure 'none' algorithm is not supported")
+		}
+		if _, ok := encAlgorithms[alg]; ok {
+			return errors.Errorf("alg %s is a key management algorithm and requires use=enc", alg)
+		}
+		return errors.Errorf("unsupported alg: %s", alg)
+	case "enc":
+		if _, ok := encAlgorithms[alg]; ok {
+			return nil
+		}
+		if alg == string(jose.RSA1_5) {
+			return errors.Errorf("alg %s is vulnerable to padding oracle attacks, use RSA-OAEP or RSA-OAEP-256", alg)
+		}
+		return errors.Errorf("unsupported alg %s for use=enc. One of: [RSA-OAEP, RSA-OAEP-256, ECDH-ES, ECDH-ES+A128KW, ECDH-ES+A192KW, ECDH-ES+A256KW]", alg)
+	default:
+		return errors.Errorf("unsupported intend of use %s", use)
+	}
+}
+
+// ValidateKeyIDStrategy checks the strategy and the provided id
+func ValidateKeyIDStrategy(strategy, id string) error {
+	switch strategy {
+	case "", KeyIDStrategyUUID:
+		return nil
+	case KeyIDStrategyThumbprint:
+		if id != "" {
+			return errors.New("key id strategy thumbprint computes the id, but the id was provided")
+		}
+		return nil
+	case KeyIDStrategyCustom:
+		if id == "" {
+			return errors.New("key id strategy custom requires the id")
+		}
+		return nil
+	default:
+		return errors.Errorf("unsupported key id strategy %s. One of: [uuid, thumbprint, custom]", strategy)
+	}
+}
+
+// Thumbprint returns the base64url encoded RFC 7638 SHA-256 thumbprint of the asymmetric key
+func Thumbprint(key interface{}) (string, error) {
+	if _, ok := key.([]byte); ok {
+		return "", errors.New("thumbprint of a symmetric key would be derived from the secret")
+	}
+	if isSecp256k1(key) {
+		return base64.RawURLEncoding.EncodeToString(secp256k1Thumbprint(key)), nil
+	}
+	thumbprint, err := (&jose.JSONWebKey{Key: key}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+// keygenOptions returns the key length option of the keygen
+func (g jwksGenerator) keygenOptions(alg string) ([]keygen.Option, error) {
+	if g.keySize != 0 && g.curve != "" {
+		return nil, errors.New("key size and curve are mutually exclusive")
+	}
+	if g.keySize != 0 {
+		switch alg {
+		case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "RSA-OAEP", "RSA-OAEP-256":
+		default:
+			return nil, errors.Errorf("key size is supported by RSA algorithms only, but '%s'", alg)
+		}
+		return []keygen.Option{keygen.WithBits(g.keySize)}, nil
+	}
+	if g.curve != "" {
+		if crv, ok := ecSignatureCurves[alg]; ok {
+			if crv != g.curve {
+				return nil, errors.Errorf("alg %s requires curve %s, but '%s'", alg, crv, g.curve)
+			}
+			// the curve is bound to the alg
+			return nil, nil
+		}
+		bits, ok := curveBits[g.curve]
+		if !ok {
+			return nil, errors.Errorf("unsupported curve %s. One of: [P-256, P-384, P-521]", g.curve)
+		}
+		if !strings.HasPrefix(alg, string(jose.ECDH_ES)) {
+			return nil, errors.Errorf("curve is supported by EC algorithms only, but '%s'", alg)
+		}
+		return []keygen.Option{keygen.WithBits(bits)}, nil
+	}
+	return nil, nil
 }
 
 func (g jwksGenerator) Generate(id, alg, use string) (*jose.JSONWebKeySet, error) {
 	// https://tools.ietf.org/html/rfc7518#page-6
-	if use != "enc" && use != "sig" {
-		return nil, errors.Errorf("unsupported intend of use %s", use)
+	if err := ValidateAlgUse(alg, use); err != nil {
+		return nil, err
 	}
-	if id == "" {
-		id = uuid.NewString()
+	if err := ValidateKeyIDStrategy(g.keyIDStrategy, id); err != nil {
+		return nil, err
+	}
+	options, err := g.keygenOptions(alg)
+	if err != nil {
+		return nil, err
+	}
+	if use == "enc" {
+		publicKey, privateKey, err := keygen.NewKeygenEnc(options...).Generate(jose.KeyAlgorithm(alg))
+		if err != nil {
+			return nil, err
+		}
+		return g.keyPair(id, alg, use, publicKey, privateKey)
 	}
 	switch alg {
 	case "HS256", "HS384", "HS512":
+		if g.keyIDStrategy == KeyIDStrategyThumbprint {
+			return nil, errors.Errorf("key id strategy thumbprint requires an asymmetric alg, but '%s'", alg)
+		}
 		key, err := keygen.NewKeygenHs().Generate(jose.SignatureAlgorithm(alg))
 		if err != nil {
 			return nil, err
 		}
+		if id == "" {
+			id = uuid.NewString()
+		}
 		return &jose.JSONWebKeySet{
 			Keys: []jose.JSONWebKey{
 				{
@@ -51,39 +239,62 @@ func (g jwksGenerator) Generate(id, alg, use string) (*jose.JSONWebKeySet, error
 				},
 			},
 		}, nil
-	case "RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "PS256", "PS384", "PS512":
-		//TODO: generate with self signed certs
-		publicKey, privateKey, err := keygen.NewKeygenSig().Generate(jose.SignatureAlgorithm(alg))
+	default:
+		publicKey, privateKey, err := keygen.NewKeygenSig(options...).Generate(jose.SignatureAlgorithm(alg))
 		if err != nil {
 			return nil, err
 		}
-		return &jose.JSONWebKeySet{
-			Keys: []jose.JSONWebKey{
-				{
-					Algorithm: alg,
-					Use:       use,
-					Key:       publicKey,
-					KeyID:     g.keyID(publicKeyIDPrefix, id),
-				},
-				{
-					Algorithm: alg,
-					Use:       use,
-					Key:       privateKey,
-					KeyID:     g.keyID(privateKeyIDPrefix, id),
-				},
+		return g.keyPair(id, alg, use, publicKey, privateKey)
+	}
+}
+
+func (g jwksGenerator) keyPair(id, alg, use string, publicKey crypto.PublicKey, privateKey crypto.PrivateKey) (*jose.JSONWebKeySet, error) {
+	if g.keyIDStrategy == KeyIDStrategyThumbprint {
+		thumbprint, err := Thumbprint(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		id = thumbprint
+	} else if id == "" {
+		id = uuid.NewString()
+	}
+	keys := &jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{
+				Algorithm: alg,
+				Use:       use,
+				Key:       publicKey,
+				KeyID:     g.keyID(publicKeyIDPrefix, id),
 			},
-		}, nil
-	case "none":
-		return nil, errors.New("unsecure 'none' algorithm is not supported")
-	default:
-		return nil, errors.Errorf("unsupported alg: %s", alg)
+			{
+				Algorithm: alg,
+				Use:       use,
+				Key:       privateKey,
+				KeyID:     g.keyID(privateKeyIDPrefix, id),
+			},
+		},
 	}
+	if g.certificate != nil {
+		chain, err := NewCertificate(g.certificate, id, use, publicKey, privateKey)
+		if err != nil {
+			return nil, err
+		}
+		for i := range keys.Keys {
+			AttachCertificates(&keys.Keys[i], chain)
+		}
+	}
+	return keys, nil
 }
 
 func (g jwksGenerator) keyID(prefix, id string) string {
 	return fmt.Sprintf("%s%s", prefix, id)
 }
 
+// BaseKeyID returns the key id without the private key prefix
+func BaseKeyID(keyID string) string {
+	return strings.TrimPrefix(keyID, privateKeyIDPrefix)
+}
+
 func IsPublic(k *jose.JSONWebKey) bool {
 	switch k.Key.(type) {
 	case ed25519.PublicKey:
@@ -1,10 +1,14 @@
 package jwk
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
 	"fmt"
 	"github.com/form3tech-oss/jwt-go"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
 	"strings"
 	"testing"
 )
@@ -29,18 +33,6 @@ func TestJwktGenerate(t *testing.T) {
 		{alg: "PS256", use: "sig"},
 		{alg: "PS384", use: "sig"},
 		{alg: "PS512", use: "sig"},
-		{alg: "HS256", use: "enc"},
-		{alg: "HS384", use: "enc"},
-		{alg: "HS512", use: "enc"},
-		{alg: "RS256", use: "enc"},
-		{alg: "RS384", use: "enc"},
-		{alg: "RS512", use: "enc"},
-		{alg: "ES256", use: "enc"},
-		{alg: "ES384", use: "enc"},
-		{alg: "ES512", use: "enc"},
-		{alg: "PS256", use: "enc"},
-		{alg: "PS384", use: "enc"},
-		{alg: "PS512", use: "enc"},
 
 		{alg: "HS256", use: "sig", id: uuid.NewString()},
 		{alg: "ES256", use: "sig", id: uuid.NewString()},
@@ -84,3 +76,165 @@ func TestJwktGenerate(t *testing.T) {
 		})
 	}
 }
+
+func TestJwksGenerateEdDSA(t *testing.T) {
+	a := assert.New(t)
+	jwksset, err := NewJWKSGenerator().Generate("ed-key", "EdDSA", "sig")
+	a.Nil(err)
+	a.Equal(2, len(jwksset.Keys))
+	a.True(IsPublic(&jwksset.Keys[0]))
+	a.True(IsPrivate(&jwksset.Keys[1]))
+
+	// keys are stored as JSON
+	data, err := json.Marshal(jwksset)
+	a.Nil(err)
+	var stored jose.JSONWebKeySet
+	a.Nil(json.Unmarshal(data, &stored))
+	a.Equal(2, len(stored.Keys))
+	a.True(IsPublic(&stored.Keys[0]))
+	a.True(IsPrivate(&stored.Keys[1]))
+	a.Equal("EdDSA", stored.Keys[1].Algorithm)
+
+	publicKey, err := PublicKey("ed-key", &stored)
+	a.Nil(err)
+	a.Equal("ed-key", publicKey.KeyID)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.EdDSA, Key: stored.Keys[1].Key}, nil)
+	a.Nil(err)
+	jws, err := signer.Sign([]byte("payload"))
+	a.Nil(err)
+	payload, err := jws.Verify(publicKey.Key)
+	a.Nil(err)
+	a.Equal([]byte("payload"), payload)
+}
+
+func TestJwksGenerateEnc(t *testing.T) {
+	gen := NewJWKSGenerator()
+
+	for _, alg := range []jose.KeyAlgorithm{jose.RSA_OAEP, jose.RSA_OAEP_256, jose.ECDH_ES, jose.ECDH_ES_A128KW, jose.ECDH_ES_A192KW, jose.ECDH_ES_A256KW} {
+		t.Run(string(alg), func(t *testing.T) {
+			a := assert.New(t)
+			jwksset, err := gen.Generate("enc-key", string(alg), "enc")
+			a.Nil(err)
+			a.Equal(2, len(jwksset.Keys))
+			for _, key := range jwksset.Keys {
+				a.Equal("enc", key.Use)
+				a.Equal(string(alg), key.Algorithm)
+			}
+			publicKey, err := PublicKey("enc-key", jwksset)
+			a.Nil(err)
+
+			encrypter, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{Algorithm: alg, Key: publicKey.Key}, nil)
+			a.Nil(err)
+			jwe, err := encrypter.Encrypt([]byte("payload"))
+			a.Nil(err)
+			plaintext, err := jwe.Decrypt(jwksset.Keys[1].Key)
+			a.Nil(err)
+			a.Equal([]byte("payload"), plaintext)
+		})
+	}
+}
+
+func TestJwksGenerateInvalidAlgUse(t *testing.T) {
+	gen := NewJWKSGenerator()
+
+	tests := []struct {
+		alg string
+		use string
+	}{
+		{alg: "HS256", use: "enc"},
+		{alg: "RS256", use: "enc"},
+		{alg: "ES256", use: "enc"},
+		{alg: "EdDSA", use: "enc"},
+		{alg: "RSA1_5", use: "enc"},
+		{alg: "A128KW", use: "enc"},
+		{alg: "RSA-OAEP", use: "sig"},
+		{alg: "ECDH-ES", use: "sig"},
+		{alg: "none", use: "sig"},
+		{alg: "RS256", use: "other"},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s-%s", tc.alg, tc.use), func(t *testing.T) {
+			_, err := gen.Generate("", tc.alg, tc.use)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestJwksGenerateKeyIDStrategy(t *testing.T) {
+	a := assert.New(t)
+
+	keys, err := NewJWKSGenerator(WithKeyIDStrategy(KeyIDStrategyThumbprint)).Generate("", "ES256", "sig")
+	a.Nil(err)
+	thumbprint, err := Thumbprint(keys.Keys[0].Key)
+	a.Nil(err)
+	a.Equal(thumbprint, keys.Keys[0].KeyID)
+	a.Equal("private-"+thumbprint, keys.Keys[1].KeyID)
+	// thumbprints of the public and private key are equal
+	privateThumbprint, err := Thumbprint(keys.Keys[1].Key)
+	a.Nil(err)
+	a.Equal(thumbprint, privateThumbprint)
+
+	_, err = NewJWKSGenerator(WithKeyIDStrategy(KeyIDStrategyThumbprint)).Generate("my-id", "ES256", "sig")
+	a.NotNil(err)
+	_, err = NewJWKSGenerator(WithKeyIDStrategy(KeyIDStrategyThumbprint)).Generate("", "HS256", "sig")
+	a.NotNil(err)
+
+	keys, err = NewJWKSGenerator(WithKeyIDStrategy(KeyIDStrategyCustom)).Generate("my-id", "HS256", "sig")
+	a.Nil(err)
+	a.Equal("my-id", keys.Keys[0].KeyID)
+	_, err = NewJWKSGenerator(WithKeyIDStrategy(KeyIDStrategyCustom)).Generate("", "ES256", "sig")
+	a.NotNil(err)
+
+	keys, err = NewJWKSGenerator(WithKeyIDStrategy(KeyIDStrategyUUID)).Generate("", "ES256", "sig")
+	a.Nil(err)
+	_, err = uuid.Parse(keys.Keys[0].KeyID)
+	a.Nil(err)
+
+	_, err = NewJWKSGenerator(WithKeyIDStrategy("sha1")).Generate("", "ES256", "sig")
+	a.NotNil(err)
+}
+
+func TestJwksGenerateKeySizeCurve(t *testing.T) {
+	tests := []struct {
+		alg     string
+		use     string
+		options []GeneratorOption
+		size    int
+		err     bool
+	}{
+		{alg: "RS256", use: "sig", options: []GeneratorOption{WithKeySize(2048)}, size: 2048},
+		{alg: "RSA-OAEP-256", use: "enc", options: []GeneratorOption{WithKeySize(3072)}, size: 3072},
+		{alg: "RS256", use: "sig", options: []GeneratorOption{WithKeySize(1024)}, err: true},
+		{alg: "ES256", use: "sig", options: []GeneratorOption{WithKeySize(2048)}, err: true},
+		{alg: "ES384", use: "sig", options: []GeneratorOption{WithCurve("P-384")}, size: 384},
+		{alg: "ES384", use: "sig", options: []GeneratorOption{WithCurve("P-256")}, err: true},
+		{alg: "ECDH-ES", use: "enc", options: []GeneratorOption{WithCurve("P-521")}, size: 521},
+		{alg: "ECDH-ES+A256KW", use: "enc", options: []GeneratorOption{WithCurve("secp256k1")}, err: true},
+		{alg: "RS256", use: "sig", options: []GeneratorOption{WithCurve("P-256")}, err: true},
+		{alg: "ES256", use: "sig", options: []GeneratorOption{WithCurve("P-256"), WithKeySize(256)}, err: true},
+		{alg: "ES256K", use: "sig", size: 256},
+		{alg: "ES256K", use: "sig", options: []GeneratorOption{WithCurve("secp256k1")}, size: 256},
+		{alg: "ES256K", use: "sig", options: []GeneratorOption{WithCurve("P-256")}, err: true},
+		{alg: "ES256", use: "sig", options: []GeneratorOption{WithCurve("secp256k1")}, err: true},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s-%s-%d", tc.alg, tc.use, tc.size), func(t *testing.T) {
+			a := assert.New(t)
+			keys, err := NewJWKSGenerator(tc.options...).Generate("", tc.alg, tc.use)
+			if tc.err {
+				a.NotNil(err)
+				return
+			}
+			a.Nil(err)
+			switch key := keys.Keys[0].Key.(type) {
+			case *rsa.PublicKey:
+				a.Equal(tc.size, key.N.BitLen())
+			case *ecdsa.PublicKey:
+				a.Equal(tc.size, key.Curve.Params().BitSize)
+			default:
+				t.Fatalf("unexpected key type %T", key)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package jwkscrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+
+	"github.com/google/tink/go/aead"
+	"github.com/google/tink/go/tink"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/pkg/errors"
+)
+
+// Formats of the model.JWKS EncryptedJwks
+const (
+	// FormatLegacy is the base64 ciphertext encrypted with empty associated data
+	FormatLegacy = 1
+	// FormatBound is the base64 ciphertext prefixed with "v2:" and bound to the record id, kid, use and alg as associated data
+	FormatBound = 2
+	// FormatEnvelope is the base64 tink KMS envelope ciphertext prefixed with "v3:". The JWKS is encrypted with a random
+	// data encryption key (DEK) and only the DEK is encrypted by the KMS key. Both are bound to the record as in FormatBound.
+	FormatEnvelope = 3
+
+	FormatCurrent = FormatEnvelope
+)
+
+const (
+	formatBoundPrefix    = "v2:"
+	formatEnvelopePrefix = "v3:"
+	associatedDataTag    = "tribe-jwks-v2"
+
+	// length of the encrypted DEK length prefix of the tink envelope ciphertext
+	lenDEK = 4
+)
+
+var dekTemplate = aead.AES256GCMKeyTemplate()
+
+// ErrLegacyFormat is returned by DecryptStrict for the records which are not bound to the record
+var ErrLegacyFormat = errors.New("legacy JWKS encryption format without record binding, run jwks reencrypt")
+
+// FormatVersion returns the format of the encrypted JWKS
+func FormatVersion(encryptedJwks string) int {
+	switch {
+	case strings.HasPrefix(encryptedJwks, formatEnvelopePrefix):
+		return FormatEnvelope
+	case strings.HasPrefix(encryptedJwks, formatBoundPrefix):
+		return FormatBound
+	default:
+		return FormatLegacy
+	}
+}
+
+// AssociatedData returns the record binding, each field is length prefixed
+func AssociatedData(jwks *model.JWKS) []byte {
+	buf := new(bytes.Buffer)
+	for _, field := range []string{associatedDataTag, jwks.ID, jwks.Kid, jwks.Use, jwks.Alg} {
+		_ = binary.Write(buf, binary.BigEndian, uint32(len(field)))
+		buf.WriteString(field)
+	}
+	return buf.Bytes()
+}
+
+// Encrypt encrypts the plaintext in the current format and sets EncryptedJwks of the record
+func Encrypt(kek tink.AEAD, jwks *model.JWKS, plaintext []byte) error {
+	associatedData := AssociatedData(jwks)
+	ciphertext, err := envelopeAEAD(kek, associatedData).Encrypt(plaintext, associatedData)
+	if err != nil {
+		return errors.Wrap(err, "AEAD keys encryption failed")
+	}
+	jwks.EncryptedJwks = formatEnvelopePrefix + base64.StdEncoding.EncodeToString(ciphertext)
+	return nil
+}
+
+// Decrypt decrypts EncryptedJwks of the record in any supported format
+func Decrypt(kek tink.AEAD, jwks *model.JWKS) ([]byte, error) {
+	var (
+		primitive      = kek
+		associatedData []byte
+		encoded        = jwks.EncryptedJwks
+	)
+	switch FormatVersion(encoded) {
+	case FormatEnvelope:
+		encoded = strings.TrimPrefix(encoded, formatEnvelopePrefix)
+		associatedData = AssociatedData(jwks)
+		primitive = envelopeAEAD(kek, associatedData)
+	case FormatBound:
+		encoded = strings.TrimPrefix(encoded, formatBoundPrefix)
+		associatedData = AssociatedData(jwks)
+	default:
+		associatedData = []byte{}
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrapf(err, "base64 decode of JWKS ID failed: %s", jwks.ID)
+	}
+	plaintext, err := primitive.Decrypt(ciphertext, associatedData)
+	if err != nil {
+		return nil, errors.Wrap(err, "AEAD keys decryption failed")
+	}
+	return plaintext, nil
+}
+
+// DecryptStrict decrypts EncryptedJwks of the record and rejects the legacy format, which is not bound to the record
+func DecryptStrict(kek tink.AEAD, jwks *model.JWKS) ([]byte, error) {
+	if FormatVersion(jwks.EncryptedJwks) == FormatLegacy {
+		return nil, errors.Wrapf(ErrLegacyFormat, "JWKS ID %s", jwks.ID)
+	}
+	return Decrypt(kek, jwks)
+}
+
+// Rewrap re-encrypts only the DEK of an envelope encrypted record with the new KMS key, the JWKS itself is not decrypted
+func Rewrap(oldKEK tink.AEAD, newKEK tink.AEAD, jwks *model.JWKS) error {
+	if FormatVersion(jwks.EncryptedJwks) != FormatEnvelope {
+		return errors.Errorf("rewrap requires envelope format, JWKS ID: %s", jwks.ID)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(jwks.EncryptedJwks, formatEnvelopePrefix))
+	if err != nil {
+		return errors.Wrapf(err, "base64 decode of JWKS ID failed: %s", jwks.ID)
+	}
+	if len(ciphertext) <= lenDEK {
+		return errors.New("invalid envelope ciphertext")
+	}
+	encryptedDEKLen := int(binary.BigEndian.Uint32(ciphertext[:lenDEK]))
+	if encryptedDEKLen <= 0 || len(ciphertext)-lenDEK < encryptedDEKLen {
+		return errors.New("invalid envelope ciphertext")
+	}
+	encryptedDEK := ciphertext[lenDEK : lenDEK+encryptedDEKLen]
+	payload := ciphertext[lenDEK+encryptedDEKLen:]
+
+	associatedData := AssociatedData(jwks)
+	dek, err := oldKEK.Decrypt(encryptedDEK, associatedData)
+	if err != nil {
+		return errors.Wrap(err, "AEAD DEK decryption failed")
+	}
+	defer func() {
+		for i := range dek {
+			dek[i] = 0
+		}
+	}()
+	newEncryptedDEK, err := newKEK.Encrypt(dek, associatedData)
+	if err != nil {
+		return errors.Wrap(err, "AEAD DEK encryption failed")
+	}
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(newEncryptedDEK)))
+	buf.Write(newEncryptedDEK)
+	buf.Write(payload)
+	jwks.EncryptedJwks = formatEnvelopePrefix + base64.StdEncoding.EncodeToString(buf.Bytes())
+	return nil
+}
+
+// envelopeAEAD returns the tink KMS envelope AEAD. tink wraps the DEK with empty associated data, so the KMS key
+// is bound to the record associated data, which is also required by the vault transit keys with key derivation.
+func envelopeAEAD(kek tink.AEAD, associatedData []byte) tink.AEAD {
+	return aead.NewKMSEnvelopeAEAD2(dekTemplate, &boundAEAD{kek: kek, associatedData: associatedData})
+}
+
+type boundAEAD struct {
+	kek            tink.AEAD
+	associatedData []byte
+}
+
+func (b *boundAEAD) Encrypt(plaintext, _ []byte) ([]byte, error) {
+	return b.kek.Encrypt(plaintext, b.associatedData)
+}
+
+func (b *boundAEAD) Decrypt(ciphertext, _ []byte) ([]byte, error) {
+	return b.kek.Decrypt(ciphertext, b.associatedData)
+}
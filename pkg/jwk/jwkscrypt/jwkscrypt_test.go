@@ -0,0 +1,120 @@
+package jwkscrypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/google/tink/go/aead"
+	"github.com/google/tink/go/keyset"
+	"github.com/google/tink/go/tink"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	a := assert.New(t)
+
+	kh, err := keyset.NewHandle(aead.AES256GCMKeyTemplate())
+	a.Nil(err)
+	primitive, err := aead.New(kh)
+	a.Nil(err)
+
+	plaintext := []byte(`{"keys":[]}`)
+	record := &model.JWKS{ID: "id-1", Kid: "kid-1", Use: "sig", Alg: "RS256"}
+	a.Nil(Encrypt(primitive, record, plaintext))
+	a.Equal(FormatCurrent, FormatVersion(record.EncryptedJwks))
+
+	result, err := Decrypt(primitive, record)
+	a.Nil(err)
+	a.Equal(plaintext, result)
+
+	result, err = DecryptStrict(primitive, record)
+	a.Nil(err)
+	a.Equal(plaintext, result)
+
+	// ciphertext swapped into another record must not decrypt
+	for _, other := range []model.JWKS{
+		{ID: "id-2", Kid: "kid-1", Use: "sig", Alg: "RS256"},
+		{ID: "id-1", Kid: "kid-2", Use: "sig", Alg: "RS256"},
+		{ID: "id-1", Kid: "kid-1", Use: "enc", Alg: "RS256"},
+		{ID: "id-1", Kid: "kid-1", Use: "sig", Alg: "RS512"},
+	} {
+		other.EncryptedJwks = record.EncryptedJwks
+		_, err = Decrypt(primitive, &other)
+		a.NotNil(err)
+	}
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	a := assert.New(t)
+
+	kh, err := keyset.NewHandle(aead.AES256GCMKeyTemplate())
+	a.Nil(err)
+	primitive, err := aead.New(kh)
+	a.Nil(err)
+
+	plaintext := []byte(`{"keys":[]}`)
+	ciphertext, err := primitive.Encrypt(plaintext, []byte{})
+	a.Nil(err)
+	record := &model.JWKS{ID: "id-1", Kid: "kid-1", Use: "sig", Alg: "RS256", EncryptedJwks: base64.StdEncoding.EncodeToString(ciphertext)}
+	a.Equal(FormatLegacy, FormatVersion(record.EncryptedJwks))
+
+	result, err := Decrypt(primitive, record)
+	a.Nil(err)
+	a.Equal(plaintext, result)
+
+	_, err = DecryptStrict(primitive, record)
+	a.True(errors.Is(err, ErrLegacyFormat))
+}
+
+func TestDecryptBound(t *testing.T) {
+	a := assert.New(t)
+
+	primitive := newTestAEAD(t)
+	plaintext := []byte(`{"keys":[]}`)
+	record := &model.JWKS{ID: "id-1", Kid: "kid-1", Use: "sig", Alg: "RS256"}
+	ciphertext, err := primitive.Encrypt(plaintext, AssociatedData(record))
+	a.Nil(err)
+	record.EncryptedJwks = formatBoundPrefix + base64.StdEncoding.EncodeToString(ciphertext)
+	a.Equal(FormatBound, FormatVersion(record.EncryptedJwks))
+
+	result, err := Decrypt(primitive, record)
+	a.Nil(err)
+	a.Equal(plaintext, result)
+}
+
+func TestRewrap(t *testing.T) {
+	a := assert.New(t)
+
+	oldKEK := newTestAEAD(t)
+	newKEK := newTestAEAD(t)
+	plaintext := []byte(`{"keys":[]}`)
+	record := &model.JWKS{ID: "id-1", Kid: "kid-1", Use: "sig", Alg: "RS256"}
+	a.Nil(Encrypt(oldKEK, record, plaintext))
+	encrypted := record.EncryptedJwks
+
+	a.Nil(Rewrap(oldKEK, newKEK, record))
+	a.Equal(FormatEnvelope, FormatVersion(record.EncryptedJwks))
+	a.NotEqual(encrypted, record.EncryptedJwks)
+
+	_, err := Decrypt(oldKEK, record)
+	a.NotNil(err, "decrypt with the old KMS key should fail")
+	result, err := Decrypt(newKEK, record)
+	a.Nil(err)
+	a.Equal(plaintext, result)
+
+	other := &model.JWKS{ID: "id-2", Kid: "kid-1", Use: "sig", Alg: "RS256", EncryptedJwks: record.EncryptedJwks}
+	a.NotNil(Rewrap(newKEK, oldKEK, other), "DEK is bound to the record")
+
+	legacy := &model.JWKS{ID: "id-1", EncryptedJwks: "v2:AAAA"}
+	a.NotNil(Rewrap(oldKEK, newKEK, legacy))
+}
+
+func newTestAEAD(t *testing.T) tink.AEAD {
+	kh, err := keyset.NewHandle(aead.AES256GCMKeyTemplate())
+	assert.Nil(t, err)
+	primitive, err := aead.New(kh)
+	assert.Nil(t, err)
+	return primitive
+}
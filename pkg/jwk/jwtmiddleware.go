@@ -1 +1,253 @@
 package jwk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+var (
+	ErrMissingToken     = errors.New("jwt: missing bearer token")
+	ErrInvalidToken     = errors.New("jwt: invalid token")
+	ErrUnsupportedAlg   = errors.New("jwt: unsupported signing algorithm")
+	ErrInvalidSignature = errors.New("jwt: invalid signature")
+	ErrInvalidClaims    = errors.New("jwt: invalid claims")
+)
+
+// DefaultAllowedAlgorithms are the asymmetric algorithms of the keys generated by tribe
+var DefaultAllowedAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512, ES256K,
+	jose.EdDSA,
+}
+
+type claimsContextKey struct{}
+
+// Claims are the verified claims of the token
+type Claims struct {
+	jwt.Claims
+	// Raw contains all claims of the token including the registered ones
+	Raw map[string]interface{}
+}
+
+// ClaimsFromContext returns the claims of the token verified by the JWTMiddleware
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*Claims)
+	return claims, ok
+}
+
+// ContextWithClaims returns a copy of the context with the claims
+func ContextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+// TokenExtractor returns the raw token of the request or ErrMissingToken
+type TokenExtractor func(r *http.Request) (string, error)
+
+// BearerTokenExtractor extracts the token from the Authorization header, see RFC 6750
+func BearerTokenExtractor(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", ErrMissingToken
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return "", errors.Wrap(ErrInvalidToken, "authorization header format must be Bearer {token}")
+	}
+	token := strings.TrimSpace(parts[1])
+	if token == "" {
+		return "", ErrMissingToken
+	}
+	return token, nil
+}
+
+// ErrorHandler writes the response when the token validation fails
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// DefaultErrorHandler responds with 401 and the WWW-Authenticate challenge, key source failures respond with 500
+func DefaultErrorHandler(w http.ResponseWriter, _ *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrMissingToken):
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrUnsupportedAlg), errors.Is(err, ErrInvalidSignature),
+		errors.Is(err, ErrInvalidClaims), errors.Is(err, ErrKeyNotFound):
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// JWTMiddleware validates the bearer tokens signed by the keys of the key source
+type JWTMiddleware struct {
+	keySource    KeySource
+	algorithms   []jose.SignatureAlgorithm
+	issuer       string
+	audiences    []string
+	leeway       time.Duration
+	extractor    TokenExtractor
+	errorHandler ErrorHandler
+	optional     bool
+	now          func() time.Time
+}
+
+type JWTMiddlewareOption func(*JWTMiddleware)
+
+// WithAllowedAlgorithms sets the accepted signing algorithms, the "none" algorithm is never accepted
+func WithAllowedAlgorithms(algorithms ...jose.SignatureAlgorithm) JWTMiddlewareOption {
+	return func(m *JWTMiddleware) {
+		m.algorithms = algorithms
+	}
+}
+
+// WithIssuer sets the required iss claim
+func WithIssuer(issuer string) JWTMiddlewareOption {
+	return func(m *JWTMiddleware) {
+		m.issuer = issuer
+	}
+}
+
+// WithAudience sets the accepted audiences, the aud claim must contain at least one of them
+func WithAudience(audiences ...string) JWTMiddlewareOption {
+	return func(m *JWTMiddleware) {
+		m.audiences = audiences
+	}
+}
+
+// WithLeeway sets the allowed clock skew of the exp, nbf and iat claims
+func WithLeeway(leeway time.Duration) JWTMiddlewareOption {
+	return func(m *JWTMiddleware) {
+		m.leeway = leeway
+	}
+}
+
+func WithTokenExtractor(extractor TokenExtractor) JWTMiddlewareOption {
+	return func(m *JWTMiddleware) {
+		m.extractor = extractor
+	}
+}
+
+func WithErrorHandler(errorHandler ErrorHandler) JWTMiddlewareOption {
+	return func(m *JWTMiddleware) {
+		m.errorHandler = errorHandler
+	}
+}
+
+// WithCredentialsOptional passes requests without a token to the next handler, the invalid tokens are still rejected
+func WithCredentialsOptional(optional bool) JWTMiddlewareOption {
+	return func(m *JWTMiddleware) {
+		m.optional = optional
+	}
+}
+
+func WithNow(now func() time.Time) JWTMiddlewareOption {
+	return func(m *JWTMiddleware) {
+		m.now = now
+	}
+}
+
+func NewJWTMiddleware(keySource KeySource, options ...JWTMiddlewareOption) *JWTMiddleware {
+	m := &JWTMiddleware{
+		keySource:    keySource,
+		algorithms:   DefaultAllowedAlgorithms,
+		leeway:       jwt.DefaultLeeway,
+		extractor:    BearerTokenExtractor,
+		errorHandler: DefaultErrorHandler,
+		now:          time.Now,
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// Handler validates the request token and puts the verified claims into the request context
+func (m *JWTMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := m.extractor(r)
+		if err != nil {
+			if m.optional && errors.Is(err, ErrMissingToken) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			m.errorHandler(w, r, err)
+			return
+		}
+		claims, err := m.Validate(r.Context(), token)
+		if err != nil {
+			m.errorHandler(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), claims)))
+	})
+}
+
+// Validate verifies the token signature and validates the claims
+func (m *JWTMiddleware) Validate(ctx context.Context, token string) (*Claims, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidToken, err.Error())
+	}
+	if len(tok.Headers) != 1 {
+		return nil, errors.Wrap(ErrInvalidToken, "single signature is required")
+	}
+	header := tok.Headers[0]
+	alg := jose.SignatureAlgorithm(header.Algorithm)
+	if !m.isAllowed(alg) {
+		return nil, errors.Wrapf(ErrUnsupportedAlg, "%s", header.Algorithm)
+	}
+	if header.KeyID == "" {
+		return nil, errors.Wrap(ErrInvalidToken, "missing kid header")
+	}
+	key, err := m.keySource.Key(ctx, header.KeyID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "kid %s", header.KeyID)
+	}
+	if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+		return nil, errors.Wrapf(ErrUnsupportedAlg, "key %s alg %s, token alg %s", key.KeyID, key.Algorithm, header.Algorithm)
+	}
+	claims := &Claims{}
+	if err = tok.Claims(VerifierKey(key.Key), &claims.Claims, &claims.Raw); err != nil {
+		return nil, errors.Wrap(ErrInvalidSignature, err.Error())
+	}
+	if err = m.validateClaims(&claims.Claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (m *JWTMiddleware) validateClaims(claims *jwt.Claims) error {
+	if claims.Expiry == nil {
+		return errors.Wrap(ErrInvalidClaims, "missing exp claim")
+	}
+	err := claims.ValidateWithLeeway(jwt.Expected{Issuer: m.issuer, Time: m.now()}, m.leeway)
+	if err != nil {
+		return errors.Wrap(ErrInvalidClaims, err.Error())
+	}
+	if len(m.audiences) == 0 {
+		return nil
+	}
+	for _, audience := range m.audiences {
+		if claims.Audience.Contains(audience) {
+			return nil
+		}
+	}
+	return errors.Wrap(ErrInvalidClaims, fmt.Sprintf("audience %v is not accepted", []string(claims.Audience)))
+}
+
+func (m *JWTMiddleware) isAllowed(alg jose.SignatureAlgorithm) bool {
+	for _, allowed := range m.algorithms {
+		if alg == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+package jwk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func newTestKeys(t *testing.T, kid, alg string) (*jose.JSONWebKey, *jose.JSONWebKey) {
+	keys, err := NewJWKSGenerator().Generate(kid, alg, "sig")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if len(keys.Keys) == 1 {
+		return &keys.Keys[0], &keys.Keys[0]
+	}
+	public, err := PublicKey(kid, keys)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for i := range keys.Keys {
+		if IsPrivate(&keys.Keys[i]) {
+			return &keys.Keys[i], public
+		}
+	}
+	t.Fatal("private key not found")
+	return nil, nil
+}
+
+func signTestToken(t *testing.T, key *jose.JSONWebKey, kid string, claims interface{}) string {
+	publicKey := key.Public()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.SignatureAlgorithm(key.Algorithm), Key: SignerKey(&publicKey, key.Key)},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", kid))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return token
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	now := time.Now()
+	rsPrivate, rsPublic := newTestKeys(t, "rs-key", "RS256")
+	esPrivate, esPublic := newTestKeys(t, "es-key", "ES256")
+	edPrivate, edPublic := newTestKeys(t, "ed-key", "EdDSA")
+	eskPrivate, eskPublic := newTestKeys(t, "esk-key", "ES256K")
+	hsKey, _ := newTestKeys(t, "hs-key", "HS256")
+	otherPrivate, _ := newTestKeys(t, "other-key", "RS256")
+
+	keySource := NewStaticKeySource(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*rsPublic, *esPublic, *edPublic, *eskPublic, *hsKey}})
+	middleware := NewJWTMiddleware(keySource,
+		WithIssuer("https://tribe.example.com"),
+		WithAudience("api", "admin"),
+		WithLeeway(30*time.Second),
+	)
+	claims := func(modify func(c *jwt.Claims)) jwt.Claims {
+		c := jwt.Claims{
+			Issuer:   "https://tribe.example.com",
+			Subject:  "alice",
+			Audience: jwt.Audience{"api"},
+			Expiry:   jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt: jwt.NewNumericDate(now),
+		}
+		if modify != nil {
+			modify(&c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{name: "valid RS256", header: "Bearer " + signTestToken(t, rsPrivate, "rs-key", claims(nil)), status: http.StatusOK},
+		{name: "valid ES256", header: "Bearer " + signTestToken(t, esPrivate, "es-key", claims(nil)), status: http.StatusOK},
+		{name: "valid EdDSA", header: "Bearer " + signTestToken(t, edPrivate, "ed-key", claims(nil)), status: http.StatusOK},
+		{name: "valid ES256K", header: "Bearer " + signTestToken(t, eskPrivate, "esk-key", claims(nil)), status: http.StatusOK},
+		{name: "any of audiences", header: "Bearer " + signTestToken(t, rsPrivate, "rs-key", claims(func(c *jwt.Claims) { c.Audience = jwt.Audience{"other", "admin"} })), status: http.StatusOK},
+		{name: "expired within leeway", header: "Bearer " + signTestToken(t, rsPrivate, "rs-key", claims(func(c *jwt.Claims) { c.Expiry = jwt.NewNumericDate(now.Add(-10 * time.Second)) })), status: http.StatusOK},
+		{name: "missing token", header: "", status: http.StatusUnauthorized},
+		{name: "not bearer", header: "Basic YWxpY2U6c2VjcmV0", status: http.StatusUnauthorized},
+		{name: "malformed token", header: "Bearer abc.def", status: http.StatusUnauthorized},
+		{name: "alg not allowed", header: "Bearer " + signTestToken(t, hsKey, "hs-key", claims(nil)), status: http.StatusUnauthorized},
+		{name: "unknown kid", header: "Bearer " + signTestToken(t, rsPrivate, "unknown", claims(nil)), status: http.StatusUnauthorized},
+		{name: "invalid signature", header: "Bearer " + signTestToken(t, otherPrivate, "rs-key", claims(nil)), status: http.StatusUnauthorized},
+		{name: "key alg mismatch", header: "Bearer " + signTestToken(t, rsPrivate, "es-key", claims(nil)), status: http.StatusUnauthorized},
+		{name: "ES256K key with ES256 token", header: "Bearer " + signTestToken(t, esPrivate, "esk-key", claims(nil)), status: http.StatusUnauthorized},
+		{name: "expired", header: "Bearer " + signTestToken(t, rsPrivate, "rs-key", claims(func(c *jwt.Claims) { c.Expiry = jwt.NewNumericDate(now.Add(-time.Minute)) })), status: http.StatusUnauthorized},
+		{name: "missing exp", header: "Bearer " + signTestToken(t, rsPrivate, "rs-key", claims(func(c *jwt.Claims) { c.Expiry = nil })), status: http.StatusUnauthorized},
+		{name: "not before", header: "Bearer " + signTestToken(t, rsPrivate, "rs-key", claims(func(c *jwt.Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute)) })), status: http.StatusUnauthorized},
+		{name: "wrong issuer", header: "Bearer " + signTestToken(t, rsPrivate, "rs-key", claims(func(c *jwt.Claims) { c.Issuer = "https://evil.example.com" })), status: http.StatusUnauthorized},
+		{name: "wrong audience", header: "Bearer " + signTestToken(t, rsPrivate, "rs-key", claims(func(c *jwt.Claims) { c.Audience = jwt.Audience{"other"} })), status: http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var verified *Claims
+			handler := middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				verified, _ = ClaimsFromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.status, rec.Code)
+			if tc.status == http.StatusOK {
+				if !assert.NotNil(t, verified) {
+					return
+				}
+				assert.Equal(t, "alice", verified.Subject)
+				assert.Equal(t, "alice", verified.Raw["sub"])
+			} else {
+				assert.Nil(t, verified)
+				assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
+
+func TestJWTMiddlewareRemoteKeySource(t *testing.T) {
+	oldPrivate, oldPublic := newTestKeys(t, "old-key", "ES256")
+	newPrivate, newPublic := newTestKeys(t, "new-key", "ES256")
+
+	var (
+		rotated  atomic.Value
+		requests int32
+	)
+	rotated.Store(false)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		keys := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*oldPublic}}
+		if rotated.Load().(bool) {
+			keys.Keys = append(keys.Keys, *newPublic)
+		}
+		_ = json.NewEncoder(w).Encode(keys)
+	}))
+	defer server.Close()
+
+	keySource := NewRemoteKeySource(server.URL, WithRefreshInterval(time.Hour, 0))
+	middleware := NewJWTMiddleware(keySource)
+	claims := jwt.Claims{Subject: "alice", Expiry: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+
+	_, err := middleware.Validate(context.Background(), signTestToken(t, oldPrivate, "old-key", claims))
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = middleware.Validate(context.Background(), signTestToken(t, oldPrivate, "old-key", claims))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	rotated.Store(true)
+	verified, err := middleware.Validate(context.Background(), signTestToken(t, newPrivate, "new-key", claims))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "alice", verified.Subject)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestCachedKeySourceConcurrentLoad(t *testing.T) {
+	_, public := newTestKeys(t, "kid-1", "ES256")
+	var loads int32
+	release := make(chan struct{})
+	source := newCachedKeySource(func(ctx context.Context) (*jose.JSONWebKeySet, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*public}}, nil
+	}, time.Minute, time.Minute, false)
+
+	const callers = 5
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			_, err := source.Key(context.Background(), "kid-1")
+			errs <- err
+		}()
+	}
+	// the lock is not held during the load
+	for atomic.LoadInt32(&loads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, source.state().loadedAt.IsZero())
+
+	close(release)
+	for i := 0; i < callers; i++ {
+		assert.NoError(t, <-errs)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+}
+
+func TestCachedKeySourceLoadFailure(t *testing.T) {
+	a := assert.New(t)
+	_, public := newTestKeys(t, "kid-1", "ES256")
+	var (
+		loads   int
+		loadErr error
+	)
+	source := newCachedKeySource(func(ctx context.Context) (*jose.JSONWebKeySet, error) {
+		loads++
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		return &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*public}}, nil
+	}, time.Minute, 10*time.Second, false)
+	source.maxAge = time.Hour
+	now := time.Now()
+	source.now = func() time.Time { return now }
+
+	_, err := source.Key(context.Background(), "kid-1")
+	a.NoError(err)
+	loadedAt := now
+
+	// the previous keys are used and the failed load is retried after the min refresh interval
+	loadErr = errors.New("source unavailable")
+	now = loadedAt.Add(2 * time.Minute)
+	_, err = source.Key(context.Background(), "kid-1")
+	a.NoError(err)
+	a.Equal(2, loads)
+	a.Equal(loadedAt, source.state().loadedAt)
+
+	now = now.Add(5 * time.Second)
+	_, err = source.Key(context.Background(), "kid-1")
+	a.NoError(err)
+	a.Equal(2, loads)
+
+	now = now.Add(10 * time.Second)
+	_, err = source.Key(context.Background(), "kid-1")
+	a.NoError(err)
+	a.Equal(3, loads)
+
+	// the keys exceeded the max age
+	now = loadedAt.Add(time.Hour)
+	_, err = source.Key(context.Background(), "kid-1")
+	if a.Error(err) {
+		a.Contains(err.Error(), "source unavailable")
+	}
+	_, err = source.Keys(context.Background())
+	a.Error(err)
+
+	loadErr = nil
+	now = now.Add(10 * time.Second)
+	_, err = source.Key(context.Background(), "kid-1")
+	a.NoError(err)
+	a.Equal(now, source.state().loadedAt)
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/pkg/errors"
 
 	"golang.org/x/crypto/ed25519"
@@ -15,6 +16,9 @@ import (
 // https://tools.ietf.org/html/rfc7517
 // https://tools.ietf.org/html/rfc7518
 
+// ES256K is ECDSA using secp256k1 and SHA-256, https://tools.ietf.org/html/rfc8812#section-3.2
+const ES256K = jose.SignatureAlgorithm("ES256K")
+
 type KeygenSig interface {
 	// Generate generates keypair for asymmetric algorithms specified by https://tools.ietf.org/html/rfc7518#section-3.1
 	Generate(alg jose.SignatureAlgorithm) (crypto.PublicKey, crypto.PrivateKey, error)
@@ -34,9 +38,10 @@ type keygenSig struct {
 
 func (r *keygenSig) verifyBits(alg jose.SignatureAlgorithm) error {
 	switch alg {
-	case jose.ES256, jose.ES384, jose.ES512, jose.EdDSA:
+	case jose.ES256, jose.ES384, jose.ES512, ES256K, jose.EdDSA:
 		keylen := map[jose.SignatureAlgorithm]int{
 			jose.ES256: 256,
+			ES256K:     256,
 			jose.ES384: 384,
 			jose.ES512: 521,
 			jose.EdDSA: 256,
@@ -78,6 +83,14 @@ func (r keygenSig) Generate(alg jose.SignatureAlgorithm) (crypto.PublicKey, cryp
 			return nil, nil, err
 		}
 		return key.Public(), key, err
+	case ES256K:
+		key, err := secp256k1.GeneratePrivateKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer key.Zero()
+		ecKey := key.ToECDSA()
+		return ecKey.Public(), ecKey, nil
 	case jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512:
 		key, err := rsa.GenerateKey(rand.Reader, r.bits)
 		if err != nil {
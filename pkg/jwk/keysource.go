@@ -0,0 +1,261 @@
+package jwk
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+const (
+	DefaultKeySourceRefreshInterval    = 5 * time.Minute
+	DefaultKeySourceMinRefreshInterval = 10 * time.Second
+	DefaultKeySourceMaxAge             = time.Hour
+
+	maxJWKSResponseSize = 1 << 20
+)
+
+// ErrKeyNotFound is returned when no key has the requested key id
+var ErrKeyNotFound = errors.New("jwk: key not found")
+
+// KeySource resolves the public verification keys by key id
+type KeySource interface {
+	Key(ctx context.Context, kid string) (*jose.JSONWebKey, error)
+}
+
+// StaticKeySource resolves the keys from a fixed key set. Private keys are not returned, the symmetric keys are
+// returned, so HS signed tokens can be validated when the HS algorithms are allowed.
+type StaticKeySource struct {
+	keys *jose.JSONWebKeySet
+}
+
+func NewStaticKeySource(keys *jose.JSONWebKeySet) *StaticKeySource {
+	return &StaticKeySource{keys: keys}
+}
+
+func (s *StaticKeySource) Key(_ context.Context, kid string) (*jose.JSONWebKey, error) {
+	return findKey(s.keys, kid, true)
+}
+
+// cachedKeySource caches the loaded key set and reloads it after the refresh interval. An unknown kid
+// triggers a reload, so the keys of a rotation are found, but not more often than the min refresh interval.
+// When a reload fails, the previous keys are used and the load is retried after the min refresh interval.
+// Keys older than the max age are not used anymore, the source fails closed until a load succeeds.
+type cachedKeySource struct {
+	load               func(ctx context.Context) (*jose.JSONWebKeySet, error)
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+	maxAge             time.Duration
+	symmetric          bool
+	now                func() time.Time
+
+	mu       sync.Mutex
+	keys     *jose.JSONWebKeySet
+	loadedAt time.Time
+	failedAt time.Time
+	loadErr  error
+	// loading is closed when the load in progress completes
+	loading chan struct{}
+}
+
+type keySourceState struct {
+	keys     *jose.JSONWebKeySet
+	loadedAt time.Time
+	failedAt time.Time
+	loadErr  error
+}
+
+func newCachedKeySource(load func(ctx context.Context) (*jose.JSONWebKeySet, error), refreshInterval, minRefreshInterval time.Duration, symmetric bool) *cachedKeySource {
+	return &cachedKeySource{
+		load:               load,
+		refreshInterval:    refreshInterval,
+		minRefreshInterval: minRefreshInterval,
+		maxAge:             DefaultKeySourceMaxAge,
+		symmetric:          symmetric,
+		now:                time.Now,
+	}
+}
+
+func (s *cachedKeySource) Key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	now := s.now()
+	keys, loadedAt, err := s.get(ctx, now, s.refreshInterval)
+	if err != nil {
+		return nil, err
+	}
+	key, err := findKey(keys, kid, s.symmetric)
+	if err != ErrKeyNotFound || now.Sub(loadedAt) < s.minRefreshInterval {
+		return key, err
+	}
+	if keys, _, err = s.get(ctx, now, s.minRefreshInterval); err != nil {
+		return nil, err
+	}
+	return findKey(keys, kid, s.symmetric)
+}
+
+// Keys returns the cached key set
+func (s *cachedKeySource) Keys(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	keys, _, err := s.get(ctx, s.now(), s.refreshInterval)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// get returns the cached key set, which is reloaded when it was loaded before the interval
+func (s *cachedKeySource) get(ctx context.Context, now time.Time, interval time.Duration) (*jose.JSONWebKeySet, time.Time, error) {
+	state := s.state()
+	if state.keys == nil || now.Sub(state.loadedAt) >= interval {
+		state = s.reload(ctx, now)
+	}
+	if state.keys == nil {
+		return nil, time.Time{}, state.loadErr
+	}
+	if s.maxAge > 0 && now.Sub(state.loadedAt) >= s.maxAge {
+		return nil, time.Time{}, errors.Errorf("jwk: keys loaded at %s exceeded the max age %v, last load error: %v", state.loadedAt.Format(time.RFC3339), s.maxAge, state.loadErr)
+	}
+	return state.keys, state.loadedAt, nil
+}
+
+func (s *cachedKeySource) state() keySourceState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return keySourceState{keys: s.keys, loadedAt: s.loadedAt, failedAt: s.failedAt, loadErr: s.loadErr}
+}
+
+// reload loads the key set without the lock and swaps it in, concurrent callers wait for the load in progress.
+// A failed load keeps the previous keys and their load time, it is not retried before the min refresh interval.
+func (s *cachedKeySource) reload(ctx context.Context, now time.Time) keySourceState {
+	s.mu.Lock()
+	if loading := s.loading; loading != nil {
+		s.mu.Unlock()
+		select {
+		case <-loading:
+		case <-ctx.Done():
+			return keySourceState{loadErr: ctx.Err()}
+		}
+		return s.state()
+	}
+	if !s.failedAt.IsZero() && now.Sub(s.failedAt) < s.minRefreshInterval {
+		s.mu.Unlock()
+		return s.state()
+	}
+	loading := make(chan struct{})
+	s.loading = loading
+	s.mu.Unlock()
+
+	keys, err := s.load(ctx)
+
+	s.mu.Lock()
+	s.loading = nil
+	close(loading)
+	s.loadErr = err
+	if err != nil {
+		// keep the previous keys, the source could be temporarily unavailable
+		s.failedAt = now
+	} else {
+		s.keys = keys
+		s.loadedAt = now
+		s.failedAt = time.Time{}
+	}
+	s.mu.Unlock()
+
+	state := s.state()
+	if err != nil {
+		if state.keys == nil {
+			log.FromContextOrDefault(ctx).Errorf("jwk: load of the key set failed: %v", err)
+		} else {
+			log.FromContextOrDefault(ctx).Warnf("jwk: load of the key set failed, keeping the keys loaded at %s: %v", state.loadedAt.Format(time.RFC3339), err)
+		}
+	}
+	return state
+}
+
+// RemoteKeySource fetches the key set from a JWKS URL e.g. https://issuer/.well-known/jwks.json
+type RemoteKeySource struct {
+	*cachedKeySource
+	url        string
+	httpClient *http.Client
+}
+
+type RemoteKeySourceOption func(*RemoteKeySource)
+
+func WithHTTPClient(httpClient *http.Client) RemoteKeySourceOption {
+	return func(s *RemoteKeySource) {
+		s.httpClient = httpClient
+	}
+}
+
+// WithRefreshInterval sets how long the fetched keys are used and how often an unknown kid can trigger a fetch
+func WithRefreshInterval(refreshInterval, minRefreshInterval time.Duration) RemoteKeySourceOption {
+	return func(s *RemoteKeySource) {
+		s.refreshInterval = refreshInterval
+		s.minRefreshInterval = minRefreshInterval
+	}
+}
+
+// WithMaxAge sets how long the fetched keys are used when the JWKS URL is not available, 0 uses them without limit
+func WithMaxAge(maxAge time.Duration) RemoteKeySourceOption {
+	return func(s *RemoteKeySource) {
+		s.maxAge = maxAge
+	}
+}
+
+func NewRemoteKeySource(url string, options ...RemoteKeySourceOption) *RemoteKeySource {
+	s := &RemoteKeySource{
+		url:        url,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	s.cachedKeySource = newCachedKeySource(s.fetch, DefaultKeySourceRefreshInterval, DefaultKeySourceMinRefreshInterval, false)
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+func (s *RemoteKeySource) fetch(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetch JWKS %s failed", s.url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fetch JWKS %s failed, status %d", s.url, resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, maxJWKSResponseSize))
+	if err != nil {
+		return nil, errors.Wrapf(err, "read JWKS %s failed", s.url)
+	}
+	keys, err := UnmarshalKeySet(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unmarshal JSONWebKeySet %s failed", s.url)
+	}
+	return keys, nil
+}
+
+// findKey returns the public key with the key id, the symmetric keys are returned only from the trusted key sources
+func findKey(keys *jose.JSONWebKeySet, kid string, symmetric bool) (*jose.JSONWebKey, error) {
+	if keys == nil {
+		return nil, ErrKeyNotFound
+	}
+	for _, key := range keys.Key(kid) {
+		if key.Use == "enc" {
+			continue
+		}
+		if _, ok := key.Key.([]byte); (ok && symmetric) || IsPublic(&key) {
+			key := key
+			return &key, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
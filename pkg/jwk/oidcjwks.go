@@ -0,0 +1,144 @@
+package jwk
+
+import (
+	"context"
+	"time"
+
+	"github.com/grepplabs/tribe/database/client"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/jwk/jwkscrypt"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// DecryptJWKS returns the keys of the JWKS record, only the public key is returned when the private key is held by vault transit
+func DecryptJWKS(kmsProvider kms.Provider, jwks *model.JWKS) (*jose.JSONWebKeySet, error) {
+	var plaintext []byte
+	if jwks.IsVaultTransit() {
+		plaintext = []byte(jwks.PublicJwks)
+	} else {
+		aead, err := kmsProvider.AEADFromKeyURI(jwks.KMSKeyURI)
+		if err != nil {
+			return nil, err
+		}
+		if kms.StrictBinding(kmsProvider) {
+			plaintext, err = jwkscrypt.DecryptStrict(aead, jwks)
+		} else {
+			plaintext, err = jwkscrypt.Decrypt(aead, jwks)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	result, err := UnmarshalKeySet(plaintext)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unmarshal JSONWebKeySet failed")
+	}
+	return result, nil
+}
+
+// PublicKey returns the public key of the private and public key pair or of the single public key held by vault transit
+func PublicKey(jwksID string, keys *jose.JSONWebKeySet) (*jose.JSONWebKey, error) {
+	if len(keys.Keys) == 1 && IsPublic(&keys.Keys[0]) {
+		// private key is held by vault transit
+		return &keys.Keys[0], nil
+	}
+	if len(keys.Keys) != 2 {
+		return nil, errors.Errorf("JWKS ID %s should have private and public key: %v", jwksID, len(keys.Keys))
+	}
+	if IsPublic(&keys.Keys[0]) && IsPrivate(&keys.Keys[1]) {
+		return &keys.Keys[0], nil
+	} else if IsPublic(&keys.Keys[1]) && IsPrivate(&keys.Keys[0]) {
+		return &keys.Keys[1], nil
+	}
+	return nil, errors.Errorf("JWKS ID %s with invalid private and public keys set", jwksID)
+}
+
+// VerificationKey returns the public key or the symmetric key of the HS algorithms
+func VerificationKey(jwksID string, keys *jose.JSONWebKeySet) (*jose.JSONWebKey, error) {
+	if len(keys.Keys) == 1 {
+		if _, ok := keys.Keys[0].Key.([]byte); ok {
+			return &keys.Keys[0], nil
+		}
+	}
+	return PublicKey(jwksID, keys)
+}
+
+// OidcJWKSKeys returns the verification keys of the current, next and previous JWKS of the OidcJWKS
+func OidcJWKSKeys(ctx context.Context, dsClient client.Client, kmsProvider kms.Provider, oidcJwks *model.OidcJWKS) (*jose.JSONWebKeySet, error) {
+	jwksIDs := []string{oidcJwks.CurrentJwksID, oidcJwks.NextJwksID}
+	if oidcJwks.PreviousJwksID != nil {
+		jwksIDs = append(jwksIDs, *oidcJwks.PreviousJwksID)
+	}
+	result := &jose.JSONWebKeySet{}
+	for _, jwksID := range jwksIDs {
+		record, err := dsClient.API().GetJWKS(ctx, jwksID)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			return nil, errors.Errorf("not found JWKS ID: %s", jwksID)
+		}
+		keys, err := DecryptJWKS(kmsProvider, record)
+		if err != nil {
+			return nil, err
+		}
+		key, err := VerificationKey(jwksID, keys)
+		if err != nil {
+			return nil, err
+		}
+		result.Keys = append(result.Keys, *key)
+	}
+	return result, nil
+}
+
+// OidcJWKSKeySource resolves the verification keys of an OidcJWKS from the datastore. The keys are reloaded after the
+// refresh interval or when an unknown kid is requested, so the rotated keys are picked up.
+type OidcJWKSKeySource struct {
+	*cachedKeySource
+	dsClient    client.Client
+	kmsProvider kms.Provider
+	oidcJwksID  string
+}
+
+func NewOidcJWKSKeySource(dsClient client.Client, kmsProvider kms.Provider, oidcJwksID string, options ...OidcJWKSKeySourceOption) *OidcJWKSKeySource {
+	s := &OidcJWKSKeySource{
+		dsClient:    dsClient,
+		kmsProvider: kmsProvider,
+		oidcJwksID:  oidcJwksID,
+	}
+	s.cachedKeySource = newCachedKeySource(s.load, DefaultKeySourceRefreshInterval, DefaultKeySourceMinRefreshInterval, true)
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+type OidcJWKSKeySourceOption func(*OidcJWKSKeySource)
+
+// WithOidcJWKSRefreshInterval sets how long the loaded keys are used and how often an unknown kid can trigger a reload
+func WithOidcJWKSRefreshInterval(refreshInterval, minRefreshInterval time.Duration) OidcJWKSKeySourceOption {
+	return func(s *OidcJWKSKeySource) {
+		s.refreshInterval = refreshInterval
+		s.minRefreshInterval = minRefreshInterval
+	}
+}
+
+// WithOidcJWKSMaxAge sets how long the loaded keys are used when the datastore is not available, 0 uses them without limit
+func WithOidcJWKSMaxAge(maxAge time.Duration) OidcJWKSKeySourceOption {
+	return func(s *OidcJWKSKeySource) {
+		s.maxAge = maxAge
+	}
+}
+
+func (s *OidcJWKSKeySource) load(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	record, err := s.dsClient.API().GetOidcJWKS(ctx, s.oidcJwksID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, errors.Errorf("not found OidcJWKS ID: %s", s.oidcJwksID)
+	}
+	return OidcJWKSKeys(ctx, s.dsClient, s.kmsProvider, record)
+}
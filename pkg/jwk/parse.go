@@ -0,0 +1,90 @@
+package jwk
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// ParseKeys parses a JWKS, a single JWK or PEM blocks. The PEM blocks can be certificates, PKIX or PKCS#1 public keys
+// and PKCS#1, PKCS#8 or SEC1 private keys. The keys of the certificates hold the certificate chain.
+func ParseKeys(data []byte) (*jose.JSONWebKeySet, error) {
+	if keys, err := ParseJSONKeys(data); err == nil {
+		return keys, nil
+	}
+	keys := &jose.JSONWebKeySet{}
+	var certificates []*x509.Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, "parse certificate failed")
+			}
+			certificates = append(certificates, certificate)
+			continue
+		}
+		key, err := parsePEMKey(block)
+		if err != nil {
+			return nil, err
+		}
+		keys.Keys = append(keys.Keys, jose.JSONWebKey{Key: key})
+	}
+	if len(certificates) != 0 {
+		// the leaf certificate is the first one
+		keys.Keys = append(keys.Keys, jose.JSONWebKey{Key: certificates[0].PublicKey, Certificates: certificates})
+	}
+	if len(keys.Keys) == 0 {
+		return nil, errors.New("no JWK, JWKS or PEM encoded keys found")
+	}
+	return keys, nil
+}
+
+// ParseJSONKeys parses a JWKS or a single JWK
+func ParseJSONKeys(data []byte) (*jose.JSONWebKeySet, error) {
+	if keys, err := UnmarshalKeySet(data); err == nil && len(keys.Keys) != 0 {
+		return keys, nil
+	}
+	key, err := UnmarshalKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*key}}, nil
+}
+
+func parsePEMKey(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		return key, errors.Wrap(err, "parse PKIX public key failed")
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		return key, errors.Wrap(err, "parse PKCS#1 public key failed")
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		return key, errors.Wrap(err, "parse PKCS#1 private key failed")
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		return key, errors.Wrap(err, "parse SEC1 private key failed")
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			// PKCS#12 bundles label the PKCS#1 and SEC1 keys as PRIVATE KEY
+			if rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes); rsaErr == nil {
+				return rsaKey, nil
+			}
+			if ecKey, ecErr := x509.ParseECPrivateKey(block.Bytes); ecErr == nil {
+				return ecKey, nil
+			}
+		}
+		return key, errors.Wrap(err, "parse PKCS#8 private key failed")
+	default:
+		return nil, errors.Errorf("unsupported PEM block %s", block.Type)
+	}
+}
@@ -0,0 +1,309 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/grepplabs/tribe/pkg/jwk/keygen"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// ES256K keys use the secp256k1 curve, https://tools.ietf.org/html/rfc8812. go-jose can neither marshal the keys
+// nor sign with them, so the keys are marshaled here and the signatures are made by the opaque signer and verifier.
+const (
+	ES256K = keygen.ES256K
+
+	curveSecp256k1      = "secp256k1"
+	secp256k1CoordBytes = 32
+)
+
+// rawSecp256k1Key is the JWK of the secp256k1 keys, https://tools.ietf.org/html/rfc8812#section-3.1
+type rawSecp256k1Key struct {
+	Kty string   `json:"kty"`
+	Kid string   `json:"kid,omitempty"`
+	Use string   `json:"use,omitempty"`
+	Alg string   `json:"alg,omitempty"`
+	Crv string   `json:"crv"`
+	X   string   `json:"x"`
+	Y   string   `json:"y"`
+	D   string   `json:"d,omitempty"`
+	X5c []string `json:"x5c,omitempty"`
+}
+
+func isSecp256k1(key interface{}) bool {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		return k.Curve == secp256k1.S256()
+	case *ecdsa.PrivateKey:
+		return k.Curve == secp256k1.S256()
+	default:
+		return false
+	}
+}
+
+// MarshalKey marshals the JWK, the secp256k1 keys are supported
+func MarshalKey(key *jose.JSONWebKey) ([]byte, error) {
+	if !isSecp256k1(key.Key) {
+		return json.Marshal(key)
+	}
+	if len(key.Certificates) != 0 {
+		return nil, errors.New("certificates of secp256k1 keys are not supported")
+	}
+	raw := rawSecp256k1Key{
+		Kty: "EC",
+		Kid: key.KeyID,
+		Use: key.Use,
+		Alg: key.Algorithm,
+		Crv: curveSecp256k1,
+	}
+	switch k := key.Key.(type) {
+	case *ecdsa.PublicKey:
+		raw.X, raw.Y = encodeCoordinate(k.X), encodeCoordinate(k.Y)
+	case *ecdsa.PrivateKey:
+		raw.X, raw.Y, raw.D = encodeCoordinate(k.X), encodeCoordinate(k.Y), encodeCoordinate(k.D)
+	}
+	return json.Marshal(&raw)
+}
+
+// UnmarshalKey parses the JWK, the secp256k1 keys are supported
+func UnmarshalKey(data []byte) (*jose.JSONWebKey, error) {
+	var header struct {
+		Kty string `json:"kty"`
+		Crv string `json:"crv"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	if header.Kty != "EC" || header.Crv != curveSecp256k1 {
+		var key jose.JSONWebKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			return nil, err
+		}
+		return &key, nil
+	}
+	var raw rawSecp256k1Key
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if len(raw.X5c) != 0 {
+		return nil, errors.New("certificates of secp256k1 keys are not supported")
+	}
+	x, err := decodeCoordinate("x", raw.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeCoordinate("y", raw.Y)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := secp256k1PublicKey(&ecdsa.PublicKey{Curve: secp256k1.S256(), X: x, Y: y})
+	if err != nil {
+		return nil, err
+	}
+	key := &jose.JSONWebKey{KeyID: raw.Kid, Use: raw.Use, Algorithm: raw.Alg, Key: publicKey.ToECDSA()}
+	if raw.D == "" {
+		return key, nil
+	}
+	d, err := decodeCoordinate("d", raw.D)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := secp256k1PrivateKey(&ecdsa.PrivateKey{D: d})
+	defer privateKey.Zero()
+	if d.Sign() == 0 || !privateKey.PubKey().IsEqual(publicKey) {
+		return nil, errors.New("invalid secp256k1 private key, d does not match x and y")
+	}
+	key.Key = privateKey.ToECDSA()
+	return key, nil
+}
+
+// MarshalKeySet marshals the JWKS, the secp256k1 keys are supported
+func MarshalKeySet(keys *jose.JSONWebKeySet) ([]byte, error) {
+	var raw struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	for i := range keys.Keys {
+		data, err := MarshalKey(&keys.Keys[i])
+		if err != nil {
+			return nil, err
+		}
+		raw.Keys = append(raw.Keys, data)
+	}
+	return json.Marshal(&raw)
+}
+
+// UnmarshalKeySet parses the JWKS, the secp256k1 keys are supported
+func UnmarshalKeySet(data []byte) (*jose.JSONWebKeySet, error) {
+	var raw struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	keys := &jose.JSONWebKeySet{}
+	for _, data := range raw.Keys {
+		key, err := UnmarshalKey(data)
+		if err != nil {
+			return nil, err
+		}
+		keys.Keys = append(keys.Keys, *key)
+	}
+	return keys, nil
+}
+
+// JSONKeySet marshals the JWKS with MarshalKeySet, so the output producers can write the secp256k1 keys
+type JSONKeySet struct {
+	*jose.JSONWebKeySet
+}
+
+func (s JSONKeySet) MarshalJSON() ([]byte, error) {
+	return MarshalKeySet(s.JSONWebKeySet)
+}
+
+func (s JSONKeySet) MarshalYAML() (interface{}, error) {
+	return s.JSONWebKeySet, nil
+}
+
+// JSONKey marshals the JWK with MarshalKey, so the output producers can write the secp256k1 keys
+type JSONKey struct {
+	*jose.JSONWebKey
+}
+
+func (k JSONKey) MarshalJSON() ([]byte, error) {
+	return MarshalKey(k.JSONWebKey)
+}
+
+func (k JSONKey) MarshalYAML() (interface{}, error) {
+	return k.JSONWebKey, nil
+}
+
+// SignerKey returns the key passed to the go-jose signer, the secp256k1 private keys are wrapped by the opaque signer
+func SignerKey(publicKey *jose.JSONWebKey, privateKey interface{}) interface{} {
+	if k, ok := privateKey.(*ecdsa.PrivateKey); ok && isSecp256k1(k) {
+		return &es256kSigner{publicKey: *publicKey, privateKey: k}
+	}
+	return privateKey
+}
+
+// VerifierKey returns the key passed to the go-jose verifier, the secp256k1 public keys are wrapped by the opaque verifier
+func VerifierKey(key interface{}) interface{} {
+	if k, ok := key.(*ecdsa.PublicKey); ok && isSecp256k1(k) {
+		return &es256kVerifier{publicKey: k}
+	}
+	return key
+}
+
+type es256kSigner struct {
+	publicKey  jose.JSONWebKey
+	privateKey *ecdsa.PrivateKey
+}
+
+func (s *es256kSigner) Public() *jose.JSONWebKey {
+	return &s.publicKey
+}
+
+func (s *es256kSigner) Algs() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{ES256K}
+}
+
+// SignPayload returns the JWS signature R || S, https://tools.ietf.org/html/rfc7518#section-3.4
+func (s *es256kSigner) SignPayload(payload []byte, alg jose.SignatureAlgorithm) ([]byte, error) {
+	if alg != ES256K {
+		return nil, errors.Errorf("secp256k1 key supports %s, but %s was requested", ES256K, alg)
+	}
+	privateKey := secp256k1PrivateKey(s.privateKey)
+	defer privateKey.Zero()
+	hash := sha256.Sum256(payload)
+	// the compact signature is the recovery code followed by R and S
+	return secpecdsa.SignCompact(privateKey, hash[:], false)[1:], nil
+}
+
+type es256kVerifier struct {
+	publicKey *ecdsa.PublicKey
+}
+
+func (v *es256kVerifier) VerifyPayload(payload []byte, signature []byte, alg jose.SignatureAlgorithm) error {
+	if alg != ES256K {
+		return jose.ErrUnsupportedAlgorithm
+	}
+	if len(signature) != 2*secp256k1CoordBytes {
+		return errors.New("invalid ES256K signature size")
+	}
+	var r, s secp256k1.ModNScalar
+	if overflow := r.SetByteSlice(signature[:secp256k1CoordBytes]); overflow || r.IsZero() {
+		return errors.New("invalid ES256K signature")
+	}
+	if overflow := s.SetByteSlice(signature[secp256k1CoordBytes:]); overflow || s.IsZero() {
+		return errors.New("invalid ES256K signature")
+	}
+	publicKey, err := secp256k1PublicKey(v.publicKey)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(payload)
+	if !secpecdsa.NewSignature(&r, &s).Verify(hash[:], publicKey) {
+		return errors.New("ES256K signature verification failed")
+	}
+	return nil
+}
+
+// secp256k1Thumbprint returns the RFC 7638 SHA-256 thumbprint of the secp256k1 key
+func secp256k1Thumbprint(key interface{}) []byte {
+	var publicKey *ecdsa.PublicKey
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		publicKey = k
+	case *ecdsa.PrivateKey:
+		publicKey = &k.PublicKey
+	}
+	input := fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, curveSecp256k1, encodeCoordinate(publicKey.X), encodeCoordinate(publicKey.Y))
+	thumbprint := sha256.Sum256([]byte(input))
+	return thumbprint[:]
+}
+
+func secp256k1PublicKey(key *ecdsa.PublicKey) (*secp256k1.PublicKey, error) {
+	if key.X == nil || key.Y == nil || key.X.BitLen() > 8*secp256k1CoordBytes || key.Y.BitLen() > 8*secp256k1CoordBytes {
+		return nil, errors.New("invalid secp256k1 public key")
+	}
+	uncompressed := make([]byte, 1+2*secp256k1CoordBytes)
+	uncompressed[0] = 0x04
+	key.X.FillBytes(uncompressed[1 : 1+secp256k1CoordBytes])
+	key.Y.FillBytes(uncompressed[1+secp256k1CoordBytes:])
+	publicKey, err := secp256k1.ParsePubKey(uncompressed)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid secp256k1 public key")
+	}
+	return publicKey, nil
+}
+
+func secp256k1PrivateKey(key *ecdsa.PrivateKey) *secp256k1.PrivateKey {
+	d := make([]byte, secp256k1CoordBytes)
+	key.D.FillBytes(d)
+	defer wipeBytes(d)
+	return secp256k1.PrivKeyFromBytes(d)
+}
+
+func encodeCoordinate(v *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(v.FillBytes(make([]byte, secp256k1CoordBytes)))
+}
+
+func decodeCoordinate(name, value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) != secp256k1CoordBytes {
+		return nil, errors.Errorf("invalid secp256k1 key parameter %s", name)
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,137 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestSecp256k1MarshalKeySet(t *testing.T) {
+	keys, err := NewJWKSGenerator(WithKeyIDStrategy(KeyIDStrategyThumbprint)).Generate("", "ES256K", "sig")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2, len(keys.Keys))
+
+	data, err := MarshalKeySet(keys)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var raw struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if !assert.NoError(t, json.Unmarshal(data, &raw)) {
+		return
+	}
+	for _, key := range raw.Keys {
+		assert.Equal(t, "EC", key["kty"])
+		assert.Equal(t, "secp256k1", key["crv"])
+		assert.Equal(t, "ES256K", key["alg"])
+	}
+
+	parsed, err := UnmarshalKeySet(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.Equal(t, len(keys.Keys), len(parsed.Keys)) {
+		return
+	}
+	for i := range keys.Keys {
+		assert.Equal(t, keys.Keys[i].KeyID, parsed.Keys[i].KeyID)
+		assert.Equal(t, IsPrivate(&keys.Keys[i]), IsPrivate(&parsed.Keys[i]))
+		assert.True(t, parsed.Keys[i].Valid())
+	}
+
+	// the thumbprint kid is the RFC 7638 hash of the required members in lexicographic order
+	public, err := PublicKey(parsed.Keys[0].KeyID, parsed)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ecKey := public.Key.(*ecdsa.PublicKey)
+	input := `{"crv":"secp256k1","kty":"EC","x":"` + encodeCoordinate(ecKey.X) + `","y":"` + encodeCoordinate(ecKey.Y) + `"}`
+	hash := sha256.Sum256([]byte(input))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(hash[:]), public.KeyID)
+	for i := range parsed.Keys {
+		thumbprint, err := Thumbprint(parsed.Keys[i].Key)
+		if assert.NoError(t, err) {
+			assert.Equal(t, public.KeyID, thumbprint)
+		}
+	}
+
+	// the other keys are marshaled by go-jose
+	rsKeys, err := NewJWKSGenerator(WithKeySize(2048)).Generate("rs-key", "RS256", "sig")
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, err = MarshalKeySet(rsKeys)
+	if !assert.NoError(t, err) {
+		return
+	}
+	expected, err := json.Marshal(rsKeys)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.JSONEq(t, string(expected), string(data))
+}
+
+func TestSecp256k1UnmarshalKeyInvalid(t *testing.T) {
+	keys, err := NewJWKSGenerator().Generate("esk-key", "ES256K", "sig")
+	if !assert.NoError(t, err) {
+		return
+	}
+	other, err := NewJWKSGenerator().Generate("esk-key", "ES256K", "sig")
+	if !assert.NoError(t, err) {
+		return
+	}
+	var private, otherPrivate *jose.JSONWebKey
+	for i := range keys.Keys {
+		if IsPrivate(&keys.Keys[i]) {
+			private, otherPrivate = &keys.Keys[i], &other.Keys[i]
+		}
+	}
+	if !assert.NotNil(t, private) {
+		return
+	}
+	data, err := MarshalKey(private)
+	if !assert.NoError(t, err) {
+		return
+	}
+	otherData, err := MarshalKey(otherPrivate)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var raw, otherRaw map[string]string
+	if !assert.NoError(t, json.Unmarshal(data, &raw)) || !assert.NoError(t, json.Unmarshal(otherData, &otherRaw)) {
+		return
+	}
+
+	tests := []struct {
+		name   string
+		modify func(m map[string]string)
+	}{
+		{name: "d of another key", modify: func(m map[string]string) { m["d"] = otherRaw["d"] }},
+		{name: "point not on curve", modify: func(m map[string]string) { m["y"] = otherRaw["y"] }},
+		{name: "short coordinate", modify: func(m map[string]string) { m["x"] = m["x"][:20] }},
+		{name: "missing coordinate", modify: func(m map[string]string) { delete(m, "y") }},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			modified := map[string]string{}
+			for k, v := range raw {
+				modified[k] = v
+			}
+			tc.modify(modified)
+			data, err := json.Marshal(modified)
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, err = UnmarshalKey(data)
+			assert.Error(t, err)
+		})
+	}
+}
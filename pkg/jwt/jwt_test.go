@@ -0,0 +1,170 @@
+package jwt
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/tink/go/aead"
+	"github.com/google/tink/go/keyset"
+	"github.com/google/tink/go/tink"
+	"github.com/google/uuid"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/database/service"
+	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/jwk/jwkscrypt"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/stretchr/testify/assert"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+)
+
+type testAPI struct {
+	service.API
+	mu       sync.Mutex
+	jwks     map[string]*model.JWKS
+	oidcJwks map[string]*model.OidcJWKS
+}
+
+type testClient struct {
+	api *testAPI
+}
+
+func (c *testClient) API() service.API { return c.api }
+
+func (a *testAPI) GetJWKS(_ context.Context, id string) (*model.JWKS, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.jwks[id], nil
+}
+
+func (a *testAPI) GetOidcJWKS(_ context.Context, id string) (*model.OidcJWKS, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if record, ok := a.oidcJwks[id]; ok {
+		copied := *record
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+type testProvider struct {
+	kms.Provider
+	aead tink.AEAD
+}
+
+func (p *testProvider) AEADFromKeyURI(string) (tink.AEAD, error) { return p.aead, nil }
+
+func newTestProvider(t *testing.T) *testProvider {
+	kh, err := keyset.NewHandle(aead.AES256GCMKeyTemplate())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	primitive, err := aead.New(kh)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return &testProvider{aead: primitive}
+}
+
+func (a *testAPI) createJWKS(t *testing.T, provider *testProvider, alg string) string {
+	id := uuid.NewString()
+	keys, err := jwk.NewJWKSGenerator().Generate(id, alg, "sig")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	plaintext, err := jwk.MarshalKeySet(keys)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	record := &model.JWKS{ID: id, Kid: id, Alg: alg, Use: "sig", KMSKeyURI: "test://", KeyStorage: model.JWKSKeyStorageKMS}
+	if !assert.NoError(t, jwkscrypt.Encrypt(provider.aead, record, plaintext)) {
+		t.FailNow()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.jwks[id] = record
+	return id
+}
+
+func (a *testAPI) rotate(oidcJwksID, newNextJwksID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	record := a.oidcJwks[oidcJwksID]
+	previous := record.CurrentJwksID
+	record.PreviousJwksID = &previous
+	record.CurrentJwksID = record.NextJwksID
+	record.NextJwksID = newNextJwksID
+}
+
+func TestSignVerify(t *testing.T) {
+	for _, alg := range []string{"HS256", "RS256", "PS384", "ES256", "ES512", "EdDSA", "ES256K"} {
+		t.Run(alg, func(t *testing.T) {
+			provider := newTestProvider(t)
+			api := &testAPI{jwks: map[string]*model.JWKS{}, oidcJwks: map[string]*model.OidcJWKS{}}
+			api.oidcJwks["oidc"] = &model.OidcJWKS{
+				ID:            "oidc",
+				CurrentJwksID: api.createJWKS(t, provider, alg),
+				NextJwksID:    api.createJWKS(t, provider, alg),
+			}
+			dsClient := &testClient{api: api}
+			signer := NewSigner(dsClient, provider, "oidc", WithSignerRefreshInterval(0))
+			// the keys are reloaded on every verification, so the rotations are seen at once
+			verifier := NewKeySourceVerifier(jwk.NewOidcJWKSKeySource(dsClient, provider, "oidc", jwk.WithOidcJWKSRefreshInterval(0, 0)))
+
+			claims := josejwt.Claims{Subject: "alice", Expiry: josejwt.NewNumericDate(time.Now().Add(time.Minute))}
+			token, err := signer.Sign(context.Background(), claims, map[string]interface{}{"scope": "read"})
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			kid, signedAlg, err := signer.KeyID(context.Background())
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, api.oidcJwks["oidc"].CurrentJwksID, kid)
+			assert.Equal(t, alg, string(signedAlg))
+
+			parsed, err := josejwt.ParseSigned(token)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, kid, parsed.Headers[0].KeyID)
+			assert.Equal(t, alg, parsed.Headers[0].Algorithm)
+
+			verified, err := verifier.Verify(context.Background(), token)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, "alice", verified.Subject)
+			assert.Equal(t, "read", verified.Raw["scope"])
+
+			// after the rotation the token is signed by the former next key and the old token is verified by the previous key
+			api.rotate("oidc", api.createJWKS(t, provider, alg))
+			rotatedToken, err := signer.Sign(context.Background(), claims)
+			if !assert.NoError(t, err) {
+				return
+			}
+			rotatedKid, _, err := signer.KeyID(context.Background())
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.NotEqual(t, kid, rotatedKid)
+
+			_, err = verifier.Verify(context.Background(), rotatedToken)
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, err = verifier.Verify(context.Background(), token)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			// the key rotated out of the set is not accepted
+			api.rotate("oidc", api.createJWKS(t, provider, alg))
+			api.rotate("oidc", api.createJWKS(t, provider, alg))
+			_, err = verifier.Verify(context.Background(), token)
+			assert.ErrorIs(t, err, jwk.ErrKeyNotFound)
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package jwt
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grepplabs/tribe/database/client"
+	"github.com/grepplabs/tribe/database/model"
+	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+)
+
+const DefaultSignerRefreshInterval = time.Minute
+
+// Signer signs the claims with the current key of an OidcJWKS. The OidcJWKS is reloaded after the refresh interval,
+// so the tokens are signed with the new current key after a rotation.
+type Signer struct {
+	dsClient        client.Client
+	kmsProvider     kms.Provider
+	oidcJwksID      string
+	jwksID          string
+	refreshInterval time.Duration
+	now             func() time.Time
+
+	mu       sync.Mutex
+	key      *signingKey
+	loadedAt time.Time
+	loadErr  error
+	// loading is closed when the load in progress completes
+	loading chan struct{}
+}
+
+type signingKey struct {
+	jwksID string
+	kid    string
+	alg    jose.SignatureAlgorithm
+	signer jose.Signer
+}
+
+type SignerOption func(*Signer)
+
+// WithSignerRefreshInterval sets how often the OidcJWKS is checked for a new current key
+func WithSignerRefreshInterval(refreshInterval time.Duration) SignerOption {
+	return func(s *Signer) {
+		s.refreshInterval = refreshInterval
+	}
+}
+
+func NewSigner(dsClient client.Client, kmsProvider kms.Provider, oidcJwksID string, options ...SignerOption) *Signer {
+	s := &Signer{
+		dsClient:        dsClient,
+		kmsProvider:     kmsProvider,
+		oidcJwksID:      oidcJwksID,
+		refreshInterval: DefaultSignerRefreshInterval,
+		now:             time.Now,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// NewJWKSSigner creates the signer of a single JWKS, the key is loaded once
+func NewJWKSSigner(dsClient client.Client, kmsProvider kms.Provider, jwksID string) *Signer {
+	return &Signer{
+		dsClient:    dsClient,
+		kmsProvider: kmsProvider,
+		jwksID:      jwksID,
+		now:         time.Now,
+	}
+}
+
+// Sign returns the compact serialized JWT with the alg and kid headers of the current key
+func (s *Signer) Sign(ctx context.Context, claims ...interface{}) (string, error) {
+	key, err := s.currentKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	builder := josejwt.Signed(key.signer)
+	for _, c := range claims {
+		builder = builder.Claims(c)
+	}
+	token, err := builder.CompactSerialize()
+	if err != nil {
+		return "", errors.Wrapf(err, "sign with JWKS ID %s failed", key.jwksID)
+	}
+	return token, nil
+}
+
+// KeyID returns the kid and alg of the current key
+func (s *Signer) KeyID(ctx context.Context) (string, jose.SignatureAlgorithm, error) {
+	key, err := s.currentKey(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	return key.kid, key.alg, nil
+}
+
+// currentKey returns the loaded key or loads it. The datastore reads and the decryption run without the lock,
+// concurrent callers wait for the load in progress.
+func (s *Signer) currentKey(ctx context.Context) (*signingKey, error) {
+	s.mu.Lock()
+	now := s.now()
+	current := s.key
+	if current != nil && (s.jwksID != "" || now.Sub(s.loadedAt) < s.refreshInterval) {
+		s.mu.Unlock()
+		return current, nil
+	}
+	if loading := s.loading; loading != nil {
+		s.mu.Unlock()
+		select {
+		case <-loading:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.loadErr != nil {
+			return nil, s.loadErr
+		}
+		return s.key, nil
+	}
+	loading := make(chan struct{})
+	s.loading = loading
+	s.mu.Unlock()
+
+	key, err := s.refreshKey(ctx, current)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loading = nil
+	close(loading)
+	s.loadErr = err
+	if err != nil {
+		return nil, err
+	}
+	s.key = key
+	s.loadedAt = now
+	return key, nil
+}
+
+// refreshKey returns the key of the JWKS or of the current JWKS of the OidcJWKS, the current key is kept if not rotated
+func (s *Signer) refreshKey(ctx context.Context, current *signingKey) (*signingKey, error) {
+	if s.jwksID != "" {
+		return s.loadKey(ctx, s.jwksID)
+	}
+	record, err := s.dsClient.API().GetOidcJWKS(ctx, s.oidcJwksID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, errors.Errorf("not found OidcJWKS ID: %s", s.oidcJwksID)
+	}
+	if current != nil && current.jwksID == record.CurrentJwksID {
+		return current, nil
+	}
+	return s.loadKey(ctx, record.CurrentJwksID)
+}
+
+func (s *Signer) loadKey(ctx context.Context, jwksID string) (*signingKey, error) {
+	record, err := s.dsClient.API().GetJWKS(ctx, jwksID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, errors.Errorf("not found JWKS ID: %s", jwksID)
+	}
+	if record.Use != "sig" {
+		return nil, errors.Errorf("JWKS ID %s is not a signing key: %s", jwksID, record.Use)
+	}
+	keys, err := jwk.DecryptJWKS(s.kmsProvider, record)
+	if err != nil {
+		return nil, err
+	}
+	kid, privateKey, err := s.privateKey(record, keys)
+	if err != nil {
+		return nil, err
+	}
+	alg := jose.SignatureAlgorithm(record.Alg)
+	options := (&jose.SignerOptions{}).WithType("JWT").WithHeader(jose.HeaderKey("kid"), kid)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: privateKey}, options)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create signer of JWKS ID %s failed", jwksID)
+	}
+	return &signingKey{
+		jwksID: jwksID,
+		kid:    kid,
+		alg:    alg,
+		signer: signer,
+	}, nil
+}
+
+// privateKey returns the kid of the published key and the signing key
+func (s *Signer) privateKey(record *model.JWKS, keys *jose.JSONWebKeySet) (string, interface{}, error) {
+	if record.IsVaultTransit() {
+		signingKeyProvider, ok := s.kmsProvider.(kms.SigningKeyProvider)
+		if !ok {
+			return "", nil, errors.Errorf("kms provider does not support key storage %s", record.KeyStorage)
+		}
+		publicKey, err := jwk.PublicKey(record.ID, keys)
+		if err != nil {
+			return "", nil, err
+		}
+		opaqueSigner, err := signingKeyProvider.Signer(record.KMSKeyURI, *publicKey)
+		if err != nil {
+			return "", nil, err
+		}
+		return publicKey.KeyID, opaqueSigner, nil
+	}
+	if len(keys.Keys) == 1 {
+		if _, ok := keys.Keys[0].Key.([]byte); ok {
+			return keys.Keys[0].KeyID, keys.Keys[0].Key, nil
+		}
+	}
+	publicKey, err := jwk.PublicKey(record.ID, keys)
+	if err != nil {
+		return "", nil, err
+	}
+	for i := range keys.Keys {
+		if jwk.IsPrivate(&keys.Keys[i]) {
+			return publicKey.KeyID, jwk.SignerKey(publicKey, keys.Keys[i].Key), nil
+		}
+	}
+	return "", nil, errors.Errorf("JWKS ID %s has no private key", record.ID)
+}
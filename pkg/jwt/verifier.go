@@ -0,0 +1,43 @@
+package jwt
+
+import (
+	"context"
+
+	"github.com/grepplabs/tribe/database/client"
+	"github.com/grepplabs/tribe/pkg/jwk"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// AllAlgorithms are the signing algorithms of the tribe JWKS. The HS algorithms are safe to allow with the keys
+// of an OidcJWKS, as the token alg must match the alg of the key.
+var AllAlgorithms = []jose.SignatureAlgorithm{
+	jose.HS256, jose.HS384, jose.HS512,
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512, jwk.ES256K,
+	jose.EdDSA,
+}
+
+// Verifier verifies the tokens signed by the current, next or previous key of an OidcJWKS. The decrypted keys
+// are cached and reloaded when a token is signed by an unknown key e.g. after a rotation.
+type Verifier struct {
+	validator *jwk.JWTMiddleware
+}
+
+func NewVerifier(dsClient client.Client, kmsProvider kms.Provider, oidcJwksID string, options ...jwk.JWTMiddlewareOption) *Verifier {
+	return NewKeySourceVerifier(jwk.NewOidcJWKSKeySource(dsClient, kmsProvider, oidcJwksID), options...)
+}
+
+// NewKeySourceVerifier creates the verifier of any key source e.g. the remote JWKS of a tribe server
+func NewKeySourceVerifier(keySource jwk.KeySource, options ...jwk.JWTMiddlewareOption) *Verifier {
+	options = append([]jwk.JWTMiddlewareOption{jwk.WithAllowedAlgorithms(AllAlgorithms...)}, options...)
+	return &Verifier{
+		validator: jwk.NewJWTMiddleware(keySource, options...),
+	}
+}
+
+// Verify verifies the signature and the claims of the token
+func (v *Verifier) Verify(ctx context.Context, token string) (*jwk.Claims, error) {
+	return v.validator.Validate(ctx, token)
+}
@@ -0,0 +1,60 @@
+package kms
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/spf13/pflag"
+)
+
+type Config struct {
+	mu      sync.Mutex
+	flagSet *pflag.FlagSet
+
+	Provider      string
+	StrictBinding bool
+
+	DatastoreConfig *config.DatastoreConfig
+	providerConfigs map[string]ProviderConfig
+}
+
+// NewConfig creates the kms configuration together with the configurations of all registered providers
+func NewConfig(datastoreConfig *config.DatastoreConfig) *Config {
+	c := &Config{
+		DatastoreConfig: datastoreConfig,
+		providerConfigs: make(map[string]ProviderConfig),
+	}
+	for _, name := range Providers() {
+		factory, err := GetFactory(name)
+		if err != nil {
+			continue
+		}
+		c.providerConfigs[name] = factory.NewConfig()
+	}
+	return c
+}
+
+func (c *Config) FlagSet() *pflag.FlagSet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.flagSet == nil {
+		c.flagSet = &pflag.FlagSet{}
+		c.flagSet.StringVar(&c.Provider, "kms-provider", "db", fmt.Sprintf("KMS provider. One of: [%s]", strings.Join(Providers(), ", ")))
+		c.flagSet.BoolVar(&c.StrictBinding, "kms-strict-binding", true, "Reject JWKS encrypted in the legacy format, which is not bound to the record. Disabling it is a temporary migration switch until jwks reencrypt has upgraded all JWKS")
+	}
+	c.flagSet.AddFlagSet(c.DatastoreConfig.FlagSet())
+	for _, name := range Providers() {
+		if providerConfig, ok := c.providerConfigs[name]; ok {
+			c.flagSet.AddFlagSet(providerConfig.FlagSet())
+		}
+	}
+	return c.flagSet
+}
+
+// ProviderConfig returns the configuration of the provider
+func (c *Config) ProviderConfig(name string) ProviderConfig {
+	return c.providerConfigs[ProviderName(name)]
+}
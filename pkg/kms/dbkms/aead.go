@@ -1,30 +1,23 @@
 package dbkms
 
 import (
-	"github.com/google/tink/go/aead"
-	"github.com/google/tink/go/keyset"
 	"github.com/google/tink/go/tink"
 )
 
-type GetKeysetFunc func() (*keyset.Handle, error)
-
 type kmsAEAD struct {
-	getKeysetFunc GetKeysetFunc
+	client   *client
+	keysetID string
 }
 
 var _ tink.AEAD = (*kmsAEAD)(nil)
 
-func NewAEAD(getKeyset GetKeysetFunc) tink.AEAD {
-	return &kmsAEAD{getKeysetFunc: getKeyset}
+func newAEAD(client *client, keysetID string) tink.AEAD {
+	return &kmsAEAD{client: client, keysetID: keysetID}
 }
 
 // Encrypt AEAD encrypts the plaintext data and uses addtionaldata from authentication.
 func (r *kmsAEAD) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
-	h, err := r.getKeysetFunc()
-	if err != nil {
-		return nil, err
-	}
-	a, err := aead.New(h)
+	a, _, err := r.client.cache.get(r.keysetID)
 	if err != nil {
 		return nil, err
 	}
@@ -33,13 +26,20 @@ func (r *kmsAEAD) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
 
 // Decrypt AEAD decrypts the data and verified the additional data.
 func (r *kmsAEAD) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
-	h, err := r.getKeysetFunc()
+	a, cached, err := r.client.cache.get(r.keysetID)
 	if err != nil {
 		return nil, err
 	}
-	a, err := aead.New(h)
-	if err != nil {
-		return nil, err
+	plaintext, err := a.Decrypt(ciphertext, additionalData)
+	if err != nil && cached {
+		// the keyset could have been rotated by another process, a foreign or tampered ciphertext does not reload it
+		reloaded, changed, reloadErr := r.client.cache.reloadIfChanged(r.keysetID)
+		if reloadErr != nil {
+			return nil, reloadErr
+		}
+		if changed {
+			return reloaded.Decrypt(ciphertext, additionalData)
+		}
 	}
-	return a.Decrypt(ciphertext, additionalData)
+	return plaintext, err
 }
@@ -0,0 +1,159 @@
+package dbkms
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/tink/go/aead"
+	"github.com/google/tink/go/tink"
+	"github.com/grepplabs/tribe/pkg/kms/masterkey"
+)
+
+type keysetLoadFunc func(keysetID string) (masterkey.MasterKeyset, int, error)
+
+type keysetVersionFunc func(keysetID string) (int, error)
+
+type cacheEntry struct {
+	mk        masterkey.MasterKeyset
+	primitive tink.AEAD
+	version   int
+	expiresAt time.Time
+}
+
+// loadCall is a keyset load in progress, concurrent callers of the same keyset wait for its result
+type loadCall struct {
+	done      chan struct{}
+	primitive tink.AEAD
+	err       error
+}
+
+// keysetCache keeps decrypted master keysets by keyset ID. Entries are wiped on eviction.
+// Expired entries are kept while the stored keyset version is unchanged, so only a rotated or rewrapped keyset is decrypted again.
+type keysetCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*cacheEntry
+	calls   map[string]*loadCall
+	load    keysetLoadFunc
+	version keysetVersionFunc
+	now     func() time.Time
+}
+
+func newKeysetCache(ttl time.Duration, load keysetLoadFunc, version keysetVersionFunc) *keysetCache {
+	return &keysetCache{
+		ttl:     ttl,
+		entries: make(map[string]*cacheEntry),
+		calls:   make(map[string]*loadCall),
+		load:    load,
+		version: version,
+		now:     time.Now,
+	}
+}
+
+// get returns the AEAD primitive for the keyset, cached reports whether it was served from the cache
+func (c *keysetCache) get(keysetID string) (primitive tink.AEAD, cached bool, err error) {
+	c.mu.Lock()
+	entry, ok := c.entries[keysetID]
+	if ok && c.now().Before(entry.expiresAt) {
+		c.mu.Unlock()
+		return entry.primitive, true, nil
+	}
+	if call, ok := c.calls[keysetID]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.primitive, false, call.err
+	}
+	call := &loadCall{done: make(chan struct{})}
+	c.calls[keysetID] = call
+	c.mu.Unlock()
+
+	// the datastore read and the key derivation run without the lock
+	call.primitive, cached, call.err = c.refresh(keysetID, entry)
+
+	c.mu.Lock()
+	delete(c.calls, keysetID)
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.primitive, cached, call.err
+}
+
+// refresh revalidates the expired entry against the stored version or loads the keyset
+func (c *keysetCache) refresh(keysetID string, expired *cacheEntry) (tink.AEAD, bool, error) {
+	if expired != nil {
+		version, err := c.version(keysetID)
+		if err == nil && version == expired.version {
+			c.mu.Lock()
+			if c.entries[keysetID] == expired {
+				expired.expiresAt = c.now().Add(c.ttl)
+			}
+			c.mu.Unlock()
+			return expired.primitive, true, nil
+		}
+	}
+	mk, version, err := c.load(keysetID)
+	if err != nil {
+		return nil, false, err
+	}
+	primitive, err := aead.New(mk.GetKeyset())
+	if err != nil {
+		mk.Destroy()
+		return nil, false, err
+	}
+	if c.ttl <= 0 {
+		mk.Destroy()
+		return primitive, false, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evict(keysetID)
+	c.entries[keysetID] = &cacheEntry{
+		mk:        mk,
+		primitive: primitive,
+		version:   version,
+		expiresAt: c.now().Add(c.ttl),
+	}
+	return primitive, false, nil
+}
+
+// reloadIfChanged loads the keyset again only when the stored version differs from the cached one, so a failing
+// decryption triggers the key derivation after a rotation or rewrap only. changed is false if the cached keyset is current.
+func (c *keysetCache) reloadIfChanged(keysetID string) (primitive tink.AEAD, changed bool, err error) {
+	c.mu.Lock()
+	entry, ok := c.entries[keysetID]
+	c.mu.Unlock()
+	if ok {
+		version, err := c.version(keysetID)
+		if err != nil {
+			return nil, false, err
+		}
+		if version == entry.version {
+			return nil, false, nil
+		}
+		c.mu.Lock()
+		if c.entries[keysetID] == entry {
+			c.evict(keysetID)
+		}
+		c.mu.Unlock()
+	}
+	primitive, _, err = c.get(keysetID)
+	if err != nil {
+		return nil, false, err
+	}
+	return primitive, true, nil
+}
+
+func (c *keysetCache) invalidate(keysetID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evict(keysetID)
+}
+
+func (c *keysetCache) evict(keysetID string) {
+	if entry, ok := c.entries[keysetID]; ok {
+		delete(c.entries, keysetID)
+		entry.mk.Destroy()
+	}
+}
@@ -0,0 +1,207 @@
+package dbkms
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/tribe/pkg/kms/masterkey"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type testLoader struct {
+	mu       sync.Mutex
+	mk       masterkey.MasterKeyset
+	version  int
+	loads    int
+	versions int
+	err      error
+	block    chan struct{}
+}
+
+func (l *testLoader) load(keysetID string) (masterkey.MasterKeyset, int, error) {
+	if l.block != nil {
+		<-l.block
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.loads++
+	if l.err != nil {
+		return nil, 0, l.err
+	}
+	// the cache destroys evicted keysets, so every load returns a fresh copy
+	encryptedKeyset, err := l.mk.EncryptKeyset()
+	if err != nil {
+		return nil, 0, err
+	}
+	mk, err := masterkey.DecryptKeyset(encryptedKeyset, []byte("testsecret"))
+	return mk, l.version, err
+}
+
+func (l *testLoader) storedVersion(keysetID string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.versions++
+	if l.err != nil {
+		return 0, l.err
+	}
+	return l.version, nil
+}
+
+func newTestLoader(t *testing.T) *testLoader {
+	mk, err := masterkey.NewMasterKeyset([]byte("testsecret"), masterkey.WithKDFParams(masterkey.KDFParams{Time: 1, Memory: 64, Threads: 1}))
+	assert.Nil(t, err)
+	return &testLoader{mk: mk, version: 1}
+}
+
+func TestKeysetCache(t *testing.T) {
+	a := assert.New(t)
+	loader := newTestLoader(t)
+	now := time.Now()
+	cache := newKeysetCache(time.Minute, loader.load, loader.storedVersion)
+	cache.now = func() time.Time { return now }
+
+	primitive, cached, err := cache.get("ks-1")
+	a.Nil(err)
+	a.False(cached)
+	ciphertext, err := primitive.Encrypt([]byte("plaintext"), nil)
+	a.Nil(err)
+
+	primitive, cached, err = cache.get("ks-1")
+	a.Nil(err)
+	a.True(cached)
+	a.Equal(1, loader.loads)
+	a.Equal(0, loader.versions)
+
+	plaintext, err := primitive.Decrypt(ciphertext, nil)
+	a.Nil(err)
+	a.Equal([]byte("plaintext"), plaintext)
+
+	// expired with an unchanged version, the cached keyset is kept
+	now = now.Add(2 * time.Minute)
+	_, cached, err = cache.get("ks-1")
+	a.Nil(err)
+	a.True(cached)
+	a.Equal(1, loader.loads)
+	a.Equal(1, loader.versions)
+
+	_, cached, err = cache.get("ks-1")
+	a.Nil(err)
+	a.True(cached)
+	a.Equal(1, loader.versions)
+
+	// expired after a version bump, the keyset is loaded again
+	loader.version = 2
+	now = now.Add(2 * time.Minute)
+	_, cached, err = cache.get("ks-1")
+	a.Nil(err)
+	a.False(cached)
+	a.Equal(2, loader.loads)
+	a.Equal(2, cache.entries["ks-1"].version)
+
+	// explicit invalidation
+	cache.invalidate("ks-1")
+	a.Equal(0, len(cache.entries))
+	_, cached, err = cache.get("ks-1")
+	a.Nil(err)
+	a.False(cached)
+	a.Equal(3, loader.loads)
+}
+
+func TestKeysetCacheConcurrentLoad(t *testing.T) {
+	a := assert.New(t)
+	loader := newTestLoader(t)
+	loader.block = make(chan struct{})
+	cache := newKeysetCache(time.Minute, loader.load, loader.storedVersion)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := cache.get("ks-1")
+			errs <- err
+		}()
+	}
+	// wait until the callers share the load in progress
+	for {
+		cache.mu.Lock()
+		_, loading := cache.calls["ks-1"]
+		cache.mu.Unlock()
+		if loading {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(loader.block)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		a.Nil(err)
+	}
+	a.Equal(1, loader.loads)
+	a.Equal(0, len(cache.calls))
+}
+
+func TestKeysetCacheDisabled(t *testing.T) {
+	a := assert.New(t)
+	loader := newTestLoader(t)
+	cache := newKeysetCache(0, loader.load, loader.storedVersion)
+
+	for i := 0; i < 3; i++ {
+		_, cached, err := cache.get("ks-1")
+		a.Nil(err)
+		a.False(cached)
+	}
+	a.Equal(3, loader.loads)
+	a.Equal(0, len(cache.entries))
+}
+
+func TestKeysetCacheLoadError(t *testing.T) {
+	a := assert.New(t)
+	loader := newTestLoader(t)
+	loader.err = errors.New("not found")
+	cache := newKeysetCache(time.Minute, loader.load, loader.storedVersion)
+
+	_, _, err := cache.get("ks-1")
+	a.NotNil(err)
+	a.Equal(0, len(cache.entries))
+}
+
+func TestAEADDecryptReloadsChangedKeyset(t *testing.T) {
+	a := assert.New(t)
+	loader := newTestLoader(t)
+	cache := newKeysetCache(time.Minute, loader.load, loader.storedVersion)
+	primitive := newAEAD(&client{cache: cache}, "ks-1")
+
+	ciphertext, err := primitive.Encrypt([]byte("plaintext"), nil)
+	a.Nil(err)
+	a.Equal(1, loader.loads)
+
+	// a tampered ciphertext checks the stored version, but does not load the unchanged keyset
+	tampered := append([]byte{}, ciphertext...)
+	tampered[len(tampered)-1] ^= 1
+	for i := 0; i < 3; i++ {
+		_, err = primitive.Decrypt(tampered, nil)
+		a.NotNil(err)
+	}
+	a.Equal(1, loader.loads)
+	a.Equal(3, loader.versions)
+
+	// the keyset was rewrapped by another process, the failing decryption loads it again
+	loader.version = 2
+	_, err = primitive.Decrypt(tampered, nil)
+	a.NotNil(err)
+	a.Equal(2, loader.loads)
+	a.Equal(2, cache.entries["ks-1"].version)
+
+	plaintext, err := primitive.Decrypt(ciphertext, nil)
+	a.Nil(err)
+	a.Equal([]byte("plaintext"), plaintext)
+	a.Equal(2, loader.loads)
+}
@@ -0,0 +1,80 @@
+package dbkms
+
+import (
+	"encoding/base64"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grepplabs/tribe/pkg/kms/shamir"
+	"github.com/grepplabs/tribe/pkg/secret"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+type Config struct {
+	mu      sync.Mutex
+	flagSet *pflag.FlagSet
+
+	KeysetId     string
+	MasterSecret string
+	UnsealShares []string
+	CacheTTL     time.Duration
+	Preload      []string
+}
+
+func NewConfig() *Config {
+	return &Config{}
+}
+
+func (c *Config) FlagSet() *pflag.FlagSet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.flagSet == nil {
+		c.flagSet = &pflag.FlagSet{}
+		c.flagSet.StringVar(&c.KeysetId, "kms-keyset-id", "", "Identifier of the keyset")
+		c.flagSet.StringVar(&c.MasterSecret, "kms-master-secret", "", "Master secret or secret reference e.g. file:///path, env://VAR, stdin://, prompt://label, vault://path#field")
+		c.flagSet.DurationVar(&c.CacheTTL, "kms-cache-ttl", DefaultCacheTTL, "How long decrypted keysets are cached, 0 disables the cache")
+		c.flagSet.StringSliceVar(&c.Preload, "kms-preload-keyset", nil, "Identifiers of the keysets to decrypt and cache at startup")
+		c.flagSet.StringArrayVar(&c.UnsealShares, "kms-unseal-share", nil, "Shamir share of the master secret or secret reference. Repeat until the threshold is reached, a reference can hold multiple shares separated by new lines")
+	}
+	return c.flagSet
+}
+
+// GetMasterSecret resolves the master secret reference or unseals the master secret from the shares
+func (c *Config) GetMasterSecret() (string, error) {
+	if len(c.UnsealShares) != 0 {
+		if c.MasterSecret != "" {
+			return "", errors.New("kms master secret and unseal shares are mutually exclusive")
+		}
+		return c.unsealMasterSecret()
+	}
+	masterSecret, err := secret.Resolve("kms-master-secret", c.MasterSecret)
+	if err != nil {
+		return "", errors.Wrap(err, "resolve kms master secret failed")
+	}
+	return masterSecret, nil
+}
+
+func (c *Config) unsealMasterSecret() (string, error) {
+	shares := make([][]byte, 0, len(c.UnsealShares))
+	for _, ref := range c.UnsealShares {
+		value, err := secret.Resolve("kms-unseal-share", ref)
+		if err != nil {
+			return "", errors.Wrap(err, "resolve kms unseal share failed")
+		}
+		for _, encoded := range strings.Fields(value) {
+			share, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return "", errors.Wrap(err, "base64 decode of unseal share failed")
+			}
+			shares = append(shares, share)
+		}
+	}
+	masterSecret, err := shamir.Combine(shares)
+	if err != nil {
+		return "", errors.Wrap(err, "unseal kms master secret failed")
+	}
+	return string(masterSecret), nil
+}
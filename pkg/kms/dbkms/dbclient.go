@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"github.com/google/tink/go/core/registry"
-	"github.com/google/tink/go/keyset"
 	"github.com/google/tink/go/tink"
 	"github.com/grepplabs/tribe/config"
 	dbClient "github.com/grepplabs/tribe/database/client"
@@ -14,11 +13,14 @@ import (
 	"github.com/pkg/errors"
 	"net/url"
 	"strings"
+	"time"
 )
 
 const (
 	dbPrefix       = "db://"
 	keyKmsKeysetId = "kms-keyset-id"
+
+	DefaultCacheTTL = 5 * time.Minute
 )
 
 var _ registry.KMSClient = (*client)(nil)
@@ -29,6 +31,9 @@ type client struct {
 	logger       log.Logger
 	dbClient     dbClient.Client
 	dbConfig     *config.DBConfig
+	cacheTTL     time.Duration
+	preload      []string
+	cache        *keysetCache
 }
 
 // Implements KMSClient Supported methods
@@ -38,19 +43,22 @@ func (c *client) Supported(keyURI string) bool {
 
 // Implements KMSClient GetAEAD methods
 func (c *client) GetAEAD(keyURI string) (tink.AEAD, error) {
-	mk, err := c.getMasterKey(keyURI, c.masterSecret)
+	keysetID, err := c.getKeysetID(keyURI)
 	if err != nil {
 		return nil, err
 	}
-	return NewAEAD(func() (*keyset.Handle, error) {
-		return mk.GetKeyset(), nil
-	}), nil
+	// fail fast if the keyset cannot be decrypted
+	if _, _, err := c.cache.get(keysetID); err != nil {
+		return nil, err
+	}
+	return newAEAD(c, keysetID), nil
 }
 
 func NewClient(options ...Option) (*client, error) {
 	c := &client{
 		keyURIPrefix: dbPrefix,
 		logger:       log.DefaultLogger.WithName("dbkms-client"),
+		cacheTTL:     DefaultCacheTTL,
 	}
 	for _, option := range options {
 		if err := option(c); err != nil {
@@ -73,44 +81,77 @@ func NewClient(options ...Option) (*client, error) {
 		}
 		c.dbClient = dbc
 	}
+	c.cache = newKeysetCache(c.cacheTTL, c.loadMasterKey, c.keysetVersion)
+	for _, keysetID := range c.preload {
+		if _, _, err := c.cache.get(keysetID); err != nil {
+			return nil, errors.Wrapf(err, "preload kms keyset failed: %s", keysetID)
+		}
+		c.logger.Infof("kms keyset %s preloaded", keysetID)
+	}
 	return c, nil
 }
 
-func (c *client) getMasterKey(keyURI string, masterSecret string) (masterkey.MasterKeyset, error) {
-	if masterSecret == "" {
-		return nil, errors.Errorf("master-secret is required for key-uri: %s", keyURI)
-	}
+func (c *client) getKeysetID(keyURI string) (string, error) {
 	if !strings.HasPrefix(strings.ToLower(keyURI), c.keyURIPrefix) {
-		return nil, fmt.Errorf("uriPrefix must start with %s, but got %s", c.keyURIPrefix, keyURI)
+		return "", fmt.Errorf("uriPrefix must start with %s, but got %s", c.keyURIPrefix, keyURI)
+	}
+	return KeysetID(keyURI)
+}
+
+// KeysetID returns the master keyset identifier of the db key URI e.g. db://db?kms-keyset-id=<id>
+func KeysetID(keyURI string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(keyURI), dbPrefix) {
+		return "", fmt.Errorf("uriPrefix must start with %s, but got %s", dbPrefix, keyURI)
 	}
 	u, err := url.Parse(keyURI)
 	if err != nil {
-		return nil, errors.Wrapf(err, "url parse failed: %s", keyURI)
+		return "", errors.Wrapf(err, "url parse failed: %s", keyURI)
 	}
 	keysetID := u.Query().Get(keyKmsKeysetId)
 	if keysetID == "" {
-		return nil, errors.Errorf("query param %s not found: %s", keyKmsKeysetId, keysetID)
+		return "", errors.Errorf("query param %s not found: %s", keyKmsKeysetId, keyURI)
+	}
+	return keysetID, nil
+}
+
+func (c *client) loadMasterKey(keysetID string) (masterkey.MasterKeyset, int, error) {
+	if c.masterSecret == "" {
+		return nil, 0, errors.Errorf("master-secret is required for keyset: %s", keysetID)
 	}
 	ks, err := c.dbClient.API().GetKMSKeyset(context.Background(), keysetID)
 	if err != nil {
-		return nil, errors.Wrapf(err, "get kms keyset failed: %s", keysetID)
+		return nil, 0, errors.Wrapf(err, "get kms keyset failed: %s", keysetID)
 	}
 	if ks == nil {
-		return nil, errors.Errorf("kms keyset not found: %s", keysetID)
+		return nil, 0, errors.Errorf("kms keyset not found: %s", keysetID)
 	}
 	encryptedKeyset, err := base64.StdEncoding.DecodeString(ks.EncryptedKeyset)
 	if err != nil {
-		return nil, errors.Wrap(err, "base64 decode of encrypted keyset failed")
+		return nil, 0, errors.Wrap(err, "base64 decode of encrypted keyset failed")
+	}
+	mk, err := masterkey.DecryptKeyset(encryptedKeyset, []byte(c.masterSecret))
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "decrypt master keyset failed")
 	}
-	mk, err := masterkey.DecryptKeyset(encryptedKeyset, []byte(masterSecret))
+	c.logger.Debugf("kms keyset %s version %d loaded", keysetID, ks.Version)
+	return mk, ks.Version, nil
+}
+
+// keysetVersion reads the stored keyset version, which is bumped when the keyset is rotated or rewrapped
+func (c *client) keysetVersion(keysetID string) (int, error) {
+	ks, err := c.dbClient.API().GetKMSKeyset(context.Background(), keysetID)
 	if err != nil {
-		return nil, errors.Wrap(err, "decrypt master keyset failed")
+		return 0, errors.Wrapf(err, "get kms keyset failed: %s", keysetID)
+	}
+	if ks == nil {
+		return 0, errors.Errorf("kms keyset not found: %s", keysetID)
 	}
-	return mk, nil
+	return ks.Version, nil
 }
 
-func RegisterKMSClient(logger log.Logger, dsClient dbClient.Client, masterSecret string, provider string) error {
-	dbkmsClient, err := NewClient(WithMasterSecret(masterSecret), WithLogger(logger), WithDBClient(dsClient), WithKeyURIPrefix(fmt.Sprintf("%s%s", dbPrefix, provider)))
+func RegisterKMSClient(logger log.Logger, dsClient dbClient.Client, masterSecret string, provider string, options ...Option) error {
+	options = append([]Option{WithMasterSecret(masterSecret), WithLogger(logger), WithDBClient(dsClient), WithKeyURIPrefix(fmt.Sprintf("%s%s", dbPrefix, provider))}, options...)
+	dbkmsClient, err := NewClient(options...)
 	if err != nil {
 		return err
 	}
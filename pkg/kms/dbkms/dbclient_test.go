@@ -0,0 +1,33 @@
+package dbkms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeysetID(t *testing.T) {
+	tests := []struct {
+		keyURI   string
+		keysetID string
+		hasErr   bool
+	}{
+		{keyURI: "db://db?kms-keyset-id=keyset-1", keysetID: "keyset-1"},
+		{keyURI: "db://minio?kms-keyset-id=keyset-1", keysetID: "keyset-1"},
+		{keyURI: "db://db", hasErr: true},
+		{keyURI: "hcvault://vault/transit/keys/tribe-jwks-1", hasErr: true},
+		{keyURI: "file:///tmp/tribe.keyfile", hasErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.keyURI, func(t *testing.T) {
+			a := assert.New(t)
+			keysetID, err := KeysetID(tc.keyURI)
+			if tc.hasErr {
+				a.NotNil(err)
+			} else {
+				a.Nil(err)
+				a.Equal(tc.keysetID, keysetID)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package dbkms
 
 import (
+	"time"
+
 	"github.com/grepplabs/tribe/config"
 	dbClient "github.com/grepplabs/tribe/database/client"
 	"github.com/grepplabs/tribe/pkg/log"
@@ -42,3 +44,19 @@ func WithKeyURIPrefix(keyURIPrefix string) Option {
 		return nil
 	}
 }
+
+// WithCacheTTL sets how long decrypted keysets are cached, 0 disables the cache
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(c *client) error {
+		c.cacheTTL = ttl
+		return nil
+	}
+}
+
+// WithPreloadKeysets decrypts and caches the keysets when the client is created
+func WithPreloadKeysets(keysetIDs ...string) Option {
+	return func(c *client) error {
+		c.preload = append(c.preload, keysetIDs...)
+		return nil
+	}
+}
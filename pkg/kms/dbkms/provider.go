@@ -0,0 +1,91 @@
+package dbkms
+
+import (
+	"fmt"
+
+	"github.com/google/tink/go/tink"
+	"github.com/grepplabs/tribe/config"
+	dbClient "github.com/grepplabs/tribe/database/client"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const ProviderName = "db"
+
+func init() {
+	kms.Register(ProviderName, factory{})
+}
+
+type factory struct{}
+
+func (factory) Scheme() string {
+	return "db"
+}
+
+func (factory) NewConfig() kms.ProviderConfig {
+	return NewConfig()
+}
+
+func (factory) New(logger log.Logger, datastoreConfig *config.DatastoreConfig, providerConfig kms.ProviderConfig) (kms.Provider, error) {
+	cfg, ok := providerConfig.(*Config)
+	if !ok {
+		return nil, errors.Errorf("unexpected %s kms provider config %T", ProviderName, providerConfig)
+	}
+	dsClient, err := dbClient.NewClient(logger, datastoreConfig)
+	if err != nil {
+		return nil, err
+	}
+	masterSecret, err := cfg.GetMasterSecret()
+	if err != nil {
+		return nil, err
+	}
+	options := []Option{
+		WithMasterSecret(masterSecret),
+		WithLogger(logger),
+		WithDBClient(dsClient),
+		WithKeyURIPrefix(fmt.Sprintf("%s%s", dbPrefix, datastoreConfig.Provider)),
+		WithCacheTTL(cfg.CacheTTL),
+		WithPreloadKeysets(cfg.Preload...),
+	}
+	kmsClient, err := NewClient(options...)
+	if err != nil {
+		return nil, err
+	}
+	return &provider{
+		client:   kmsClient,
+		keysetID: cfg.KeysetId,
+	}, nil
+}
+
+// provider encrypts the JWKS with the master keysets stored in the datastore. The keysets are shared, so key URIs do not need a normalization.
+type provider struct {
+	client   *client
+	keysetID string
+}
+
+func (p *provider) AEADFromKeyURI(refKeyURI string) (tink.AEAD, error) {
+	return p.client.GetAEAD(refKeyURI)
+}
+
+func (p *provider) NewAEAD(jwksID string) (tink.AEAD, string, error) {
+	keyURI := fmt.Sprintf("%s?%s=%s", p.client.keyURIPrefix, keyKmsKeysetId, p.keysetID)
+	aead, err := p.client.GetAEAD(keyURI)
+	if err != nil {
+		return nil, "", err
+	}
+	return aead, keyURI, nil
+}
+
+// DeleteKey keeps the master keysets, they are shared by the JWKS
+func (p *provider) DeleteKey(refKeyURI string) error {
+	return nil
+}
+
+func (p *provider) ToRefKeyURI(keyURI string) string {
+	return keyURI
+}
+
+func (p *provider) FromRefKeyURI(refKeyURI string) string {
+	return refKeyURI
+}
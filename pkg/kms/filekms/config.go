@@ -0,0 +1,42 @@
+package filekms
+
+import (
+	"sync"
+
+	"github.com/grepplabs/tribe/pkg/secret"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+type Config struct {
+	mu      sync.Mutex
+	flagSet *pflag.FlagSet
+
+	Path       string
+	Passphrase string
+}
+
+func NewConfig() *Config {
+	return &Config{}
+}
+
+func (c *Config) FlagSet() *pflag.FlagSet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.flagSet == nil {
+		c.flagSet = &pflag.FlagSet{}
+		c.flagSet.StringVar(&c.Path, "kms-keyfile", "tribe.keyfile", "Path of the keyfile used by the file KMS provider")
+		c.flagSet.StringVar(&c.Passphrase, "kms-keyfile-passphrase", "", "Passphrase of the keyfile or secret reference e.g. file:///path, env://VAR, stdin://, prompt://label")
+	}
+	return c.flagSet
+}
+
+// GetPassphrase resolves the keyfile passphrase reference
+func (c *Config) GetPassphrase() (string, error) {
+	passphrase, err := secret.Resolve("kms-keyfile-passphrase", c.Passphrase)
+	if err != nil {
+		return "", errors.Wrap(err, "resolve kms keyfile passphrase failed")
+	}
+	return passphrase, nil
+}
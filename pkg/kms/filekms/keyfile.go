@@ -0,0 +1,146 @@
+package filekms
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/google/tink/go/aead"
+	"github.com/google/tink/go/aead/subtle"
+	"github.com/google/tink/go/keyset"
+	"github.com/grepplabs/tribe/pkg/kms/masterkey"
+	"github.com/pkg/errors"
+)
+
+const (
+	keyfileVersion = 1
+	kdfArgon2id    = "argon2id"
+)
+
+// keyfile is a tink JSON encrypted keyset together with the Argon2id parameters deriving the passphrase key
+type keyfile struct {
+	Version         int             `json:"version"`
+	KDF             keyfileKDF      `json:"kdf"`
+	EncryptedKeyset json.RawMessage `json:"encrypted_keyset"`
+}
+
+type keyfileKDF struct {
+	Name    string `json:"name"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+	Salt    []byte `json:"salt"`
+}
+
+// CreateKeyfile generates a new AEAD keyset and writes it encrypted with the passphrase. An existing keyfile is only replaced with force.
+func CreateKeyfile(path string, passphrase []byte, params masterkey.KDFParams, force bool) (*keyset.Handle, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("keyfile passphrase is empty")
+	}
+	if _, err := os.Stat(path); err == nil && !force {
+		return nil, errors.Errorf("keyfile %s already exists", path)
+	}
+	kh, err := keyset.NewHandle(aead.AES256GCMKeyTemplate())
+	if err != nil {
+		return nil, err
+	}
+	data, err := encryptKeyfile(kh, passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create keyfile %s failed", path)
+	}
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return nil, errors.Wrapf(err, "write keyfile %s failed", path)
+	}
+	if err = f.Close(); err != nil {
+		return nil, errors.Wrapf(err, "close keyfile %s failed", path)
+	}
+	return kh, nil
+}
+
+// LoadKeyfile reads the keyfile and decrypts the keyset with the passphrase
+func LoadKeyfile(path string, passphrase []byte) (*keyset.Handle, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("keyfile passphrase is empty")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read keyfile %s failed", path)
+	}
+	var kf keyfile
+	if err = json.Unmarshal(data, &kf); err != nil {
+		return nil, errors.Wrapf(err, "parse keyfile %s failed", path)
+	}
+	if kf.Version != keyfileVersion {
+		return nil, errors.Errorf("unsupported keyfile version %d", kf.Version)
+	}
+	if kf.KDF.Name != kdfArgon2id {
+		return nil, errors.Errorf("unsupported keyfile kdf %s", kf.KDF.Name)
+	}
+	params := masterkey.KDFParams{Time: kf.KDF.Time, Memory: kf.KDF.Memory, Threads: kf.KDF.Threads}
+	backend, wipe, err := passphraseAEAD(passphrase, kf.KDF.Salt, params)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe()
+	kh, err := keyset.Read(keyset.NewJSONReader(bytes.NewReader(kf.EncryptedKeyset)), backend)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decrypt keyfile %s failed", path)
+	}
+	return kh, nil
+}
+
+func encryptKeyfile(kh *keyset.Handle, passphrase []byte, params masterkey.KDFParams) ([]byte, error) {
+	salt, err := masterkey.NewSalt()
+	if err != nil {
+		return nil, err
+	}
+	backend, wipe, err := passphraseAEAD(passphrase, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe()
+	buf := new(bytes.Buffer)
+	if err = kh.Write(keyset.NewJSONWriter(buf), backend); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(&keyfile{
+		Version: keyfileVersion,
+		KDF: keyfileKDF{
+			Name:    kdfArgon2id,
+			Time:    params.Time,
+			Memory:  params.Memory,
+			Threads: params.Threads,
+			Salt:    salt,
+		},
+		EncryptedKeyset: buf.Bytes(),
+	}, "", "  ")
+}
+
+// passphraseAEAD returns the AEAD of the derived key and a function wiping the key, which must be called after use
+func passphraseAEAD(passphrase, salt []byte, params masterkey.KDFParams) (*subtle.AESGCM, func(), error) {
+	key, err := masterkey.DeriveKey(passphrase, salt, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	wipe := func() {
+		for i := range key {
+			key[i] = 0
+		}
+	}
+	backend, err := subtle.NewAESGCM(key)
+	if err != nil {
+		wipe()
+		return nil, nil, err
+	}
+	return backend, wipe, nil
+}
@@ -0,0 +1,132 @@
+package filekms
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/tink/go/aead"
+	"github.com/google/tink/go/tink"
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	ProviderName = "file"
+
+	filePrefix = "file://"
+)
+
+func init() {
+	kms.Register(ProviderName, factory{})
+}
+
+type factory struct{}
+
+func (factory) Scheme() string {
+	return "file"
+}
+
+func (factory) NewConfig() kms.ProviderConfig {
+	return NewConfig()
+}
+
+func (factory) New(logger log.Logger, _ *config.DatastoreConfig, providerConfig kms.ProviderConfig) (kms.Provider, error) {
+	cfg, ok := providerConfig.(*Config)
+	if !ok {
+		return nil, errors.Errorf("unexpected %s kms provider config %T", ProviderName, providerConfig)
+	}
+	passphrase, err := cfg.GetPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	return NewProvider(logger, cfg.Path, passphrase)
+}
+
+// provider encrypts the JWKS with a keyset stored in a local passphrase protected keyfile. It is meant for development and CI.
+type provider struct {
+	logger     log.Logger
+	path       string
+	passphrase []byte
+
+	mu    sync.Mutex
+	aeads map[string]tink.AEAD
+}
+
+// NewProvider creates the file provider, the keyfile is decrypted to fail fast on a wrong passphrase.
+// A relative path is resolved against the working directory, so the stored key URIs do not depend on it.
+func NewProvider(logger log.Logger, path string, passphrase string) (kms.Provider, error) {
+	if path == "" {
+		return nil, errors.New("kms keyfile path is empty")
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve kms keyfile path failed")
+	}
+	p := &provider{
+		logger:     logger.WithName("filekms"),
+		path:       path,
+		passphrase: []byte(passphrase),
+		aeads:      make(map[string]tink.AEAD),
+	}
+	if _, err := p.getAEAD(path); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *provider) AEADFromKeyURI(refKeyURI string) (tink.AEAD, error) {
+	if !strings.HasPrefix(refKeyURI, filePrefix) {
+		return nil, errors.Errorf("unsupported keyURI %s", refKeyURI)
+	}
+	return p.getAEAD(strings.TrimPrefix(refKeyURI, filePrefix))
+}
+
+func (p *provider) NewAEAD(string) (tink.AEAD, string, error) {
+	a, err := p.getAEAD(p.path)
+	if err != nil {
+		return nil, "", err
+	}
+	return a, KeyURI(p.path), nil
+}
+
+// DeleteKey keeps the keyfile, it is shared by the JWKS
+func (p *provider) DeleteKey(string) error {
+	return nil
+}
+
+func (p *provider) ToRefKeyURI(keyURI string) string {
+	return keyURI
+}
+
+func (p *provider) FromRefKeyURI(refKeyURI string) string {
+	return refKeyURI
+}
+
+// KeyURI returns the key URI of the keyfile
+func KeyURI(path string) string {
+	return filePrefix + path
+}
+
+// getAEAD returns the AEAD of the keyfile, other keyfiles than the configured one are decrypted with the same passphrase
+func (p *provider) getAEAD(path string) (tink.AEAD, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if a, ok := p.aeads[path]; ok {
+		return a, nil
+	}
+	kh, err := LoadKeyfile(path, p.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	a, err := aead.New(kh)
+	if err != nil {
+		return nil, err
+	}
+	p.aeads[path] = a
+	p.logger.Debugf("keyfile %s loaded", path)
+	return a, nil
+}
@@ -0,0 +1,88 @@
+package filekms
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grepplabs/tribe/pkg/kms/masterkey"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var testKDFParams = masterkey.KDFParams{Time: 1, Memory: 64, Threads: 1}
+
+func TestKeyfile(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "tribe.keyfile")
+
+	kh, err := CreateKeyfile(path, []byte("passphrase"), testKDFParams, false)
+	a.Nil(err)
+	info, err := os.Stat(path)
+	a.Nil(err)
+	a.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	_, err = CreateKeyfile(path, []byte("passphrase"), testKDFParams, false)
+	a.NotNil(err, "existing keyfile must not be overwritten")
+
+	loaded, err := LoadKeyfile(path, []byte("passphrase"))
+	a.Nil(err)
+	a.Equal(kh.KeysetInfo().PrimaryKeyId, loaded.KeysetInfo().PrimaryKeyId)
+
+	_, err = LoadKeyfile(path, []byte("wrong"))
+	a.NotNil(err, "wrong passphrase should fail")
+
+	data, err := ioutil.ReadFile(path)
+	a.Nil(err)
+	a.NotContains(string(data), `"value"`, "keyfile must not contain plaintext key material")
+}
+
+func TestProvider(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "tribe.keyfile")
+	_, err := CreateKeyfile(path, []byte("passphrase"), testKDFParams, false)
+	a.Nil(err)
+
+	_, err = NewProvider(log.DefaultLogger, path, "wrong")
+	a.NotNil(err)
+
+	p, err := NewProvider(log.DefaultLogger, path, "passphrase")
+	a.Nil(err)
+	aead, refKeyURI, err := p.NewAEAD("jwks-1")
+	a.Nil(err)
+	a.Equal("file://"+path, refKeyURI)
+	ciphertext, err := aead.Encrypt([]byte("plaintext"), []byte("jwks-1"))
+	a.Nil(err)
+
+	aead2, err := p.AEADFromKeyURI(refKeyURI)
+	a.Nil(err)
+	plaintext, err := aead2.Decrypt(ciphertext, []byte("jwks-1"))
+	a.Nil(err)
+	a.Equal([]byte("plaintext"), plaintext)
+	_, err = aead2.Decrypt(ciphertext, []byte("jwks-2"))
+	a.NotNil(err)
+
+	_, err = p.AEADFromKeyURI("db://db?kms-keyset-id=1")
+	a.NotNil(err)
+}
+
+func TestProviderRelativePath(t *testing.T) {
+	a := assert.New(t)
+	dir := t.TempDir()
+	_, err := CreateKeyfile(filepath.Join(dir, "tribe.keyfile"), []byte("passphrase"), testKDFParams, false)
+	a.Nil(err)
+
+	wd, err := os.Getwd()
+	a.Nil(err)
+	a.Nil(os.Chdir(dir))
+	defer func() { _ = os.Chdir(wd) }()
+
+	p, err := NewProvider(log.DefaultLogger, "tribe.keyfile", "passphrase")
+	a.Nil(err)
+	_, refKeyURI, err := p.NewAEAD("jwks-1")
+	a.Nil(err)
+	abs, err := filepath.Abs("tribe.keyfile")
+	a.Nil(err)
+	a.Equal("file://"+abs, refKeyURI)
+}
@@ -0,0 +1,171 @@
+package masterkey
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+)
+
+// Encrypted keyset formats
+const (
+	// FormatLegacy is a tink encrypted keyset wrapped with an unsalted SHA-256 of the master secret
+	FormatLegacy = 1
+	// FormatArgon2id is a versioned envelope with Argon2id parameters and salt followed by the tink encrypted keyset
+	FormatArgon2id = 2
+
+	FormatCurrent = FormatArgon2id
+)
+
+const (
+	kdfArgon2id = 1
+
+	keySize     = 32 // AES-256
+	saltSize    = 16
+	maxSaltSize = 64
+
+	// upper bounds of the stored parameters, a tampered header must not exhaust memory or cpu
+	maxKDFTime   = 16
+	maxKDFMemory = 4 * 1024 * 1024 // 4 GiB
+)
+
+// envelope: magic | version (1) | kdf (1) | time (4) | memory (4) | threads (1) | salt length (1) | salt | encrypted keyset
+var envelopeMagic = []byte("TMK")
+
+// KDFParams are Argon2id parameters, memory is in KiB
+type KDFParams struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// DefaultKDFParams follow the second recommended option of RFC 9106
+var DefaultKDFParams = KDFParams{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+func (p KDFParams) validate() error {
+	if p.Time == 0 || p.Memory == 0 || p.Threads == 0 {
+		return errors.New("kms: invalid argon2id parameters")
+	}
+	if p.Memory < 8*uint32(p.Threads) {
+		return errors.New("kms: argon2id memory must be at least 8*threads KiB")
+	}
+	if p.Time > maxKDFTime {
+		return errors.Errorf("kms: argon2id time %d exceeds the maximum of %d", p.Time, maxKDFTime)
+	}
+	if p.Memory > maxKDFMemory {
+		return errors.Errorf("kms: argon2id memory %d KiB exceeds the maximum of %d KiB", p.Memory, maxKDFMemory)
+	}
+	return nil
+}
+
+type kdfHeader struct {
+	params KDFParams
+	salt   []byte
+}
+
+func newKDFHeader(params KDFParams) (*kdfHeader, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	salt, err := NewSalt()
+	if err != nil {
+		return nil, err
+	}
+	return &kdfHeader{params: params, salt: salt}, nil
+}
+
+// DeriveKey derives an AES-256 key from the secret with Argon2id, the caller should wipe the key after use
+func DeriveKey(secret, salt []byte, params KDFParams) ([]byte, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	if len(salt) == 0 || len(salt) > maxSaltSize {
+		return nil, errors.Errorf("kms: invalid salt length %d", len(salt))
+	}
+	return argon2.IDKey(secret, salt, params.Time, params.Memory, params.Threads, keySize), nil
+}
+
+// NewSalt returns a random salt for DeriveKey
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "kms: generate salt failed")
+	}
+	return salt, nil
+}
+
+func (h *kdfHeader) deriveKey(secret []byte) []byte {
+	return argon2.IDKey(secret, h.salt, h.params.Time, h.params.Memory, h.params.Threads, keySize)
+}
+
+func (h *kdfHeader) marshal() []byte {
+	buf := new(bytes.Buffer)
+	buf.Write(envelopeMagic)
+	buf.WriteByte(FormatArgon2id)
+	buf.WriteByte(kdfArgon2id)
+	_ = binary.Write(buf, binary.BigEndian, h.params.Time)
+	_ = binary.Write(buf, binary.BigEndian, h.params.Memory)
+	buf.WriteByte(h.params.Threads)
+	buf.WriteByte(byte(len(h.salt)))
+	buf.Write(h.salt)
+	return buf.Bytes()
+}
+
+// unmarshalKDFHeader parses the envelope header and returns the remaining encrypted keyset
+func unmarshalKDFHeader(data []byte) (*kdfHeader, []byte, error) {
+	r := bytes.NewReader(data[len(envelopeMagic)+1:])
+	kdf, err := r.ReadByte()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "kms: read kdf failed")
+	}
+	if kdf != kdfArgon2id {
+		return nil, nil, errors.Errorf("kms: unsupported kdf %d", kdf)
+	}
+	var h kdfHeader
+	if err := binary.Read(r, binary.BigEndian, &h.params.Time); err != nil {
+		return nil, nil, errors.Wrap(err, "kms: read kdf time failed")
+	}
+	if err := binary.Read(r, binary.BigEndian, &h.params.Memory); err != nil {
+		return nil, nil, errors.Wrap(err, "kms: read kdf memory failed")
+	}
+	if h.params.Threads, err = r.ReadByte(); err != nil {
+		return nil, nil, errors.Wrap(err, "kms: read kdf threads failed")
+	}
+	if err := h.params.validate(); err != nil {
+		return nil, nil, err
+	}
+	saltLen, err := r.ReadByte()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "kms: read salt length failed")
+	}
+	if saltLen == 0 || saltLen > maxSaltSize {
+		return nil, nil, errors.Errorf("kms: invalid salt length %d", saltLen)
+	}
+	h.salt = make([]byte, saltLen)
+	if _, err := io.ReadFull(r, h.salt); err != nil {
+		return nil, nil, errors.Wrap(err, "kms: read salt failed")
+	}
+	return &h, data[len(data)-r.Len():], nil
+}
+
+// FormatVersion returns the format of the encrypted keyset
+func FormatVersion(encryptedKeyset []byte) int {
+	if len(encryptedKeyset) > len(envelopeMagic) && bytes.HasPrefix(encryptedKeyset, envelopeMagic) {
+		return int(encryptedKeyset[len(envelopeMagic)])
+	}
+	return FormatLegacy
+}
+
+// 32 bytes for AES-256
+func hashByteSecret(secret []byte) []byte {
+	r := sha256.Sum256(secret)
+	return r[:]
+}
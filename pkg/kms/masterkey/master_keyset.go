@@ -2,7 +2,7 @@ package masterkey
 
 import (
 	"bytes"
-	"crypto/sha256"
+
 	"github.com/google/tink/go/aead"
 	"github.com/google/tink/go/aead/subtle"
 	"github.com/google/tink/go/keyset"
@@ -14,14 +14,29 @@ var errEmptyMasterSecret = errors.New("kms: master secret is empty")
 type MasterKeyset interface {
 	EncryptKeyset() ([]byte, error)
 	GetKeyset() *keyset.Handle
+	// FormatVersion returns the format the keyset was read from, FormatCurrent for new keysets
+	FormatVersion() int
+	// Destroy wipes the secret and the key material, the keyset cannot be used afterwards
+	Destroy()
 }
 
 type masterKeyset struct {
-	kh     *keyset.Handle
-	secret []byte
+	kh        *keyset.Handle
+	secret    []byte
+	format    int
+	kdfParams KDFParams
+}
+
+type Option func(*masterKeyset)
+
+// WithKDFParams sets Argon2id parameters used by EncryptKeyset
+func WithKDFParams(params KDFParams) Option {
+	return func(m *masterKeyset) {
+		m.kdfParams = params
+	}
 }
 
-func NewMasterKeyset(secret []byte) (MasterKeyset, error) {
+func NewMasterKeyset(secret []byte, options ...Option) (MasterKeyset, error) {
 	if len(secret) == 0 {
 		return nil, errEmptyMasterSecret
 	}
@@ -29,15 +44,44 @@ func NewMasterKeyset(secret []byte) (MasterKeyset, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &masterKeyset{
-		secret: secret,
-		kh:     kh,
-	}, nil
+	return newMasterKeyset(kh, secret, FormatCurrent, options...), nil
+}
+
+func newMasterKeyset(kh *keyset.Handle, secret []byte, format int, options ...Option) *masterKeyset {
+	m := &masterKeyset{
+		secret:    append([]byte(nil), secret...),
+		kh:        kh,
+		format:    format,
+		kdfParams: DefaultKDFParams,
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
 }
 
-// DecryptKeyset decrypts encrypted key set using provided secret
-func DecryptKeyset(encryptedKeyset []byte, secret []byte) (MasterKeyset, error) {
-	masterKey, err := getKMSEnvelopeAEAD(secret)
+// DecryptKeyset decrypts encrypted key set using provided secret. Both the legacy and the current formats are supported.
+func DecryptKeyset(encryptedKeyset []byte, secret []byte, options ...Option) (MasterKeyset, error) {
+	if len(secret) == 0 {
+		return nil, errEmptyMasterSecret
+	}
+	var key []byte
+	format := FormatVersion(encryptedKeyset)
+	switch format {
+	case FormatLegacy:
+		key = hashByteSecret(secret)
+	case FormatArgon2id:
+		header, data, err := unmarshalKDFHeader(encryptedKeyset)
+		if err != nil {
+			return nil, err
+		}
+		key = header.deriveKey(secret)
+		encryptedKeyset = data
+	default:
+		return nil, errors.Errorf("kms: unsupported encrypted keyset format %d", format)
+	}
+	defer wipeBytes(key)
+	masterKey, err := getKMSEnvelopeAEAD(key)
 	if err != nil {
 		return nil, err
 	}
@@ -46,19 +90,22 @@ func DecryptKeyset(encryptedKeyset []byte, secret []byte) (MasterKeyset, error)
 	if err != nil {
 		return nil, err
 	}
-	return &masterKeyset{
-		kh:     kh,
-		secret: secret,
-	}, nil
+	return newMasterKeyset(kh, secret, format, options...), nil
 }
 
-// EncryptKeyset encrypts key set
+// EncryptKeyset encrypts key set in the current format with a new random salt
 func (m masterKeyset) EncryptKeyset() ([]byte, error) {
-	masterKey, err := getKMSEnvelopeAEAD(m.secret)
+	header, err := newKDFHeader(m.kdfParams)
+	if err != nil {
+		return nil, err
+	}
+	key := header.deriveKey(m.secret)
+	defer wipeBytes(key)
+	masterKey, err := getKMSEnvelopeAEAD(key)
 	if err != nil {
 		return nil, err
 	}
-	buf := new(bytes.Buffer)
+	buf := bytes.NewBuffer(header.marshal())
 	w := keyset.NewBinaryWriter(buf)
 	if err := m.kh.Write(w, masterKey); err != nil {
 		return nil, err
@@ -70,8 +117,16 @@ func (m masterKeyset) GetKeyset() *keyset.Handle {
 	return m.kh
 }
 
-func getKMSEnvelopeAEAD(secret []byte) (*aead.KMSEnvelopeAEAD, error) {
-	key := hashByteSecret(secret)
+func (m masterKeyset) FormatVersion() int {
+	return m.format
+}
+
+func (m masterKeyset) Destroy() {
+	wipeBytes(m.secret)
+	wipeKeyset(m.kh)
+}
+
+func getKMSEnvelopeAEAD(key []byte) (*aead.KMSEnvelopeAEAD, error) {
 	backend, err := subtle.NewAESGCM(key)
 	if err != nil {
 		return nil, err
@@ -79,8 +134,11 @@ func getKMSEnvelopeAEAD(secret []byte) (*aead.KMSEnvelopeAEAD, error) {
 	return aead.NewKMSEnvelopeAEAD2(aead.AES256GCMKeyTemplate(), backend), nil
 }
 
-// 32 bytes for AES-256
-func hashByteSecret(secret []byte) []byte {
-	r := sha256.Sum256(secret)
-	return r[:]
+// Rewrap returns a copy of the keyset protected by the new secret, the keys are not changed.
+// Destroying either keyset does not affect the other one.
+func Rewrap(mk MasterKeyset, secret []byte, options ...Option) (MasterKeyset, error) {
+	if len(secret) == 0 {
+		return nil, errEmptyMasterSecret
+	}
+	return newMasterKeyset(cloneKeyset(mk.GetKeyset()), secret, FormatCurrent, options...), nil
 }
@@ -1,9 +1,13 @@
 package masterkey
 
 import (
+	"bytes"
+	"encoding/binary"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/google/tink/go/aead"
+	"github.com/google/tink/go/keyset"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,3 +44,123 @@ func TestEmptyMasterSecret(t *testing.T) {
 	_, err := NewMasterKeyset([]byte{})
 	a.Same(errEmptyMasterSecret, err)
 }
+
+var testKDFParams = KDFParams{Time: 1, Memory: 64, Threads: 1}
+
+func TestMasterKeySetFormat(t *testing.T) {
+	a := assert.New(t)
+
+	secret := []byte("testsecret")
+	mks, err := NewMasterKeyset(secret, WithKDFParams(testKDFParams))
+	a.Nil(err)
+	a.Equal(FormatCurrent, mks.FormatVersion())
+
+	encryptedKey1, err := mks.EncryptKeyset()
+	a.Nil(err)
+	encryptedKey2, err := mks.EncryptKeyset()
+	a.Nil(err)
+	a.Equal(FormatArgon2id, FormatVersion(encryptedKey1))
+	a.NotEqual(encryptedKey1, encryptedKey2, "salt must be random")
+
+	mks2, err := DecryptKeyset(encryptedKey2, secret)
+	a.Nil(err)
+	a.Equal(FormatArgon2id, mks2.FormatVersion())
+	a.True(proto.Equal(mks.GetKeyset().KeysetInfo(), mks2.GetKeyset().KeysetInfo()), "key handlers are not equal")
+
+	_, err = DecryptKeyset(encryptedKey2[:10], secret)
+	a.NotNil(err)
+}
+
+func TestMasterKeySetKDFLimits(t *testing.T) {
+	a := assert.New(t)
+
+	secret := []byte("testsecret")
+	for _, params := range []KDFParams{
+		{Time: maxKDFTime + 1, Memory: 64, Threads: 1},
+		{Time: 1, Memory: maxKDFMemory + 1, Threads: 1},
+	} {
+		mks, err := NewMasterKeyset(secret, WithKDFParams(params))
+		a.Nil(err)
+		_, err = mks.EncryptKeyset()
+		a.NotNil(err)
+	}
+
+	mks, err := NewMasterKeyset(secret, WithKDFParams(testKDFParams))
+	a.Nil(err)
+	encryptedKey, err := mks.EncryptKeyset()
+	a.Nil(err)
+
+	// tampered time and memory of the stored header are rejected before the key derivation
+	offset := len(envelopeMagic) + 2
+	tampered := append([]byte{}, encryptedKey...)
+	binary.BigEndian.PutUint32(tampered[offset:], maxKDFTime+1)
+	_, err = DecryptKeyset(tampered, secret)
+	a.NotNil(err)
+	a.Contains(err.Error(), "exceeds the maximum")
+
+	tampered = append([]byte{}, encryptedKey...)
+	binary.BigEndian.PutUint32(tampered[offset+4:], 0xffffffff)
+	_, err = DecryptKeyset(tampered, secret)
+	a.NotNil(err)
+	a.Contains(err.Error(), "exceeds the maximum")
+}
+
+func TestMasterKeySetLegacyUpgrade(t *testing.T) {
+	a := assert.New(t)
+
+	secret := []byte("testsecret")
+	mks, err := NewMasterKeyset(secret)
+	a.Nil(err)
+
+	// encrypt in the legacy format
+	masterKey, err := getKMSEnvelopeAEAD(hashByteSecret(secret))
+	a.Nil(err)
+	buf := new(bytes.Buffer)
+	a.Nil(mks.GetKeyset().Write(keyset.NewBinaryWriter(buf), masterKey))
+	legacyKey := buf.Bytes()
+	a.Equal(FormatLegacy, FormatVersion(legacyKey))
+
+	legacy, err := DecryptKeyset(legacyKey, secret, WithKDFParams(testKDFParams))
+	a.Nil(err)
+	a.Equal(FormatLegacy, legacy.FormatVersion())
+
+	newSecret := []byte("testsecret-new")
+	upgraded, err := Rewrap(legacy, newSecret, WithKDFParams(testKDFParams))
+	a.Nil(err)
+	encryptedKey, err := upgraded.EncryptKeyset()
+	a.Nil(err)
+	a.Equal(FormatArgon2id, FormatVersion(encryptedKey))
+
+	_, err = DecryptKeyset(encryptedKey, secret)
+	a.NotNil(err, "decrypt with old secret should fail")
+	mks2, err := DecryptKeyset(encryptedKey, newSecret)
+	a.Nil(err)
+	a.True(proto.Equal(mks.GetKeyset().KeysetInfo(), mks2.GetKeyset().KeysetInfo()), "key handlers are not equal")
+}
+
+func TestMasterKeySetDestroy(t *testing.T) {
+	a := assert.New(t)
+
+	secret := []byte("testsecret")
+	mks, err := NewMasterKeyset(secret, WithKDFParams(testKDFParams))
+	a.Nil(err)
+	mks.Destroy()
+	a.Equal([]byte("testsecret"), secret, "caller secret must not be wiped")
+	_, err = aead.New(mks.GetKeyset())
+	a.NotNil(err, "wiped keyset should not produce a primitive")
+}
+
+func TestMasterKeySetRewrapDestroy(t *testing.T) {
+	a := assert.New(t)
+
+	mks, err := NewMasterKeyset([]byte("testsecret"), WithKDFParams(testKDFParams))
+	a.Nil(err)
+	rewrapped, err := Rewrap(mks, []byte("newsecret"), WithKDFParams(testKDFParams))
+	a.Nil(err)
+
+	mks.Destroy()
+	_, err = aead.New(rewrapped.GetKeyset())
+	a.Nil(err, "destroying the source keyset must not wipe the rewrapped one")
+	_, err = aead.New(mks.GetKeyset())
+	a.NotNil(err, "wiped keyset should not produce a primitive")
+}
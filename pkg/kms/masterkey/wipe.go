@@ -0,0 +1,33 @@
+package masterkey
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/google/tink/go/insecurecleartextkeyset"
+	"github.com/google/tink/go/keyset"
+	tinkpb "github.com/google/tink/go/proto/tink_go_proto"
+)
+
+// wipeKeyset zeroes the serialized key material held by the handle. Primitives created from the handle
+// keep their own copies until garbage collected.
+func wipeKeyset(h *keyset.Handle) {
+	if h == nil {
+		return
+	}
+	for _, key := range insecurecleartextkeyset.KeysetMaterial(h).GetKey() {
+		if key.GetKeyData() != nil {
+			wipeBytes(key.KeyData.Value)
+		}
+	}
+}
+
+// cloneKeyset returns a handle with its own copy of the key material, so wiping one handle leaves the other usable
+func cloneKeyset(h *keyset.Handle) *keyset.Handle {
+	ks := proto.Clone(insecurecleartextkeyset.KeysetMaterial(h)).(*tinkpb.Keyset)
+	return insecurecleartextkeyset.KeysetHandle(ks)
+}
+
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,61 @@
+package kms
+
+import (
+	"crypto"
+
+	"github.com/google/tink/go/tink"
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/spf13/pflag"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// Provider creates the AEADs which encrypt the JWKS. The key URI stored with a JWKS record is a reference key URI,
+// which does not depend on the deployment e.g. the vault address, and is normalized by the provider.
+type Provider interface {
+	// AEADFromKeyURI returns the AEAD of a stored reference key URI
+	AEADFromKeyURI(refKeyURI string) (tink.AEAD, error)
+	// NewAEAD returns the AEAD for a new JWKS together with the reference key URI to store
+	NewAEAD(jwksID string) (aead tink.AEAD, refKeyURI string, err error)
+	// DeleteKey deletes the key if it is owned by a single JWKS, shared keys are kept
+	DeleteKey(refKeyURI string) error
+	// ToRefKeyURI converts a key URI to the stored reference key URI
+	ToRefKeyURI(keyURI string) string
+	// FromRefKeyURI converts a stored reference key URI to the key URI
+	FromRefKeyURI(refKeyURI string) string
+}
+
+// SigningKeyProvider is implemented by the providers which hold signing key pairs, the private key never leaves the KMS
+type SigningKeyProvider interface {
+	// NewSigningKey creates a key pair for the JWKS and returns its public key and the reference key URI to store
+	NewSigningKey(jwksID string, alg jose.SignatureAlgorithm) (publicKey crypto.PublicKey, refKeyURI string, err error)
+	// Signer returns the signer of the key pair
+	Signer(refKeyURI string, publicKey jose.JSONWebKey) (jose.OpaqueSigner, error)
+}
+
+// BindingPolicy is implemented by the providers created by NewProvider
+type BindingPolicy interface {
+	// StrictBinding reports whether the JWKS encrypted in the legacy format without the record binding are rejected
+	StrictBinding() bool
+}
+
+// StrictBinding reports whether the provider rejects the JWKS encrypted without the record binding
+func StrictBinding(provider Provider) bool {
+	policy, ok := provider.(BindingPolicy)
+	return ok && policy.StrictBinding()
+}
+
+// ProviderConfig holds the provider specific configuration
+type ProviderConfig interface {
+	FlagSet() *pflag.FlagSet
+}
+
+// Factory creates the provider and its configuration. Factories are registered with Register.
+type Factory interface {
+	// Scheme is the URI scheme of the reference key URIs e.g. db or hcvault
+	Scheme() string
+	// NewConfig returns a new provider configuration, its flags are added to the kms flags
+	NewConfig() ProviderConfig
+	// New creates the provider
+	New(logger log.Logger, datastoreConfig *config.DatastoreConfig, providerConfig ProviderConfig) (Provider, error)
+}
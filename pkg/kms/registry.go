@@ -0,0 +1,202 @@
+package kms
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/google/tink/go/tink"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+	aliases   = make(map[string]string)
+)
+
+// Register makes a provider available by name and aliases. It should be called from an init function,
+// so the provider flags are known when the commands are created.
+func Register(name string, factory Factory, alias ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if factory == nil {
+		panic("kms: register factory is nil")
+	}
+	if _, ok := factories[name]; ok {
+		panic("kms: register called twice for provider " + name)
+	}
+	factories[name] = factory
+	for _, a := range alias {
+		aliases[a] = name
+	}
+}
+
+// Providers returns the sorted names of the registered providers
+func Providers() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetFactory returns the factory registered by the name or alias
+func GetFactory(name string) (Factory, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if target, ok := aliases[name]; ok {
+		name = target
+	}
+	factory, ok := factories[name]
+	if !ok {
+		return nil, errors.Errorf("unsupported kms provider %s", name)
+	}
+	return factory, nil
+}
+
+// ProviderName returns the name of the provider registered by the name or alias
+func ProviderName(name string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if target, ok := aliases[name]; ok {
+		return target
+	}
+	return name
+}
+
+// NewProvider creates the configured provider. New keys are created by the configured provider, the stored keys
+// are resolved by the provider registered for the scheme of the key URI.
+func NewProvider(logger log.Logger, kmsConfig *Config) (Provider, error) {
+	name := ProviderName(kmsConfig.Provider)
+	factory, err := GetFactory(name)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := factory.New(logger, kmsConfig.DatastoreConfig, kmsConfig.ProviderConfig(name))
+	if err != nil {
+		return nil, err
+	}
+	if !kmsConfig.StrictBinding {
+		logger.Warnf("kms strict binding is disabled, the JWKS encrypted in the legacy format are not bound to their records. Run jwks reencrypt and enable it")
+	}
+	configured := &configuredProvider{
+		Provider:      provider,
+		logger:        logger,
+		kmsConfig:     kmsConfig,
+		strictBinding: kmsConfig.StrictBinding,
+		providers:     map[string]Provider{factory.Scheme(): provider},
+	}
+	if signingKeyProvider, ok := provider.(SigningKeyProvider); ok {
+		return &configuredSigningKeyProvider{configuredProvider: configured, SigningKeyProvider: signingKeyProvider}, nil
+	}
+	return configured, nil
+}
+
+// factoryByScheme returns the name and the factory of the provider of the key URI scheme
+func factoryByScheme(scheme string) (string, Factory, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for name, factory := range factories {
+		if factory.Scheme() == scheme {
+			return name, factory, nil
+		}
+	}
+	return "", nil, errors.Errorf("no kms provider for key URI scheme %s", scheme)
+}
+
+// configuredProvider adds the kms configuration to the provider and routes the stored key URIs by scheme
+type configuredProvider struct {
+	Provider
+	logger        log.Logger
+	kmsConfig     *Config
+	strictBinding bool
+
+	mu        sync.Mutex
+	providers map[string]Provider
+}
+
+func (p *configuredProvider) StrictBinding() bool {
+	return p.strictBinding
+}
+
+func (p *configuredProvider) AEADFromKeyURI(refKeyURI string) (tink.AEAD, error) {
+	provider, err := p.keyProvider(refKeyURI)
+	if err != nil {
+		return nil, err
+	}
+	return provider.AEADFromKeyURI(refKeyURI)
+}
+
+func (p *configuredProvider) DeleteKey(refKeyURI string) error {
+	provider, err := p.keyProvider(refKeyURI)
+	if err != nil {
+		return err
+	}
+	return provider.DeleteKey(refKeyURI)
+}
+
+// keyProvider returns the provider of the key URI scheme, providers other than the configured one are created on first use
+func (p *configuredProvider) keyProvider(refKeyURI string) (Provider, error) {
+	scheme, err := Scheme(refKeyURI)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if provider, ok := p.providers[scheme]; ok {
+		return provider, nil
+	}
+	name, factory, err := factoryByScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := factory.New(p.logger, p.kmsConfig.DatastoreConfig, p.kmsConfig.ProviderConfig(name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "create kms provider %s for key URI scheme %s failed", name, scheme)
+	}
+	p.providers[scheme] = provider
+	return provider, nil
+}
+
+// configuredSigningKeyProvider keeps the signing keys of the provider available
+type configuredSigningKeyProvider struct {
+	*configuredProvider
+	SigningKeyProvider
+}
+
+// Signer returns the signer of the provider of the key URI scheme
+func (p *configuredSigningKeyProvider) Signer(refKeyURI string, publicKey jose.JSONWebKey) (jose.OpaqueSigner, error) {
+	provider, err := p.keyProvider(refKeyURI)
+	if err != nil {
+		return nil, err
+	}
+	signingKeyProvider, ok := provider.(SigningKeyProvider)
+	if !ok {
+		return nil, errors.Errorf("kms provider of key URI %s does not hold signing keys", refKeyURI)
+	}
+	return signingKeyProvider.Signer(refKeyURI, publicKey)
+}
+
+// Scheme returns the URI scheme of the key URI
+func Scheme(keyURI string) (string, error) {
+	u, err := url.Parse(keyURI)
+	if err != nil {
+		return "", errors.Wrapf(err, "url parse failed: %s", keyURI)
+	}
+	if u.Scheme == "" {
+		return "", errors.Errorf("key URI without scheme: %s", keyURI)
+	}
+	return u.Scheme, nil
+}
@@ -0,0 +1,139 @@
+package kms
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/tink/go/tink"
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfig struct {
+	flagSet *pflag.FlagSet
+	Prefix  string
+}
+
+func (c *testConfig) FlagSet() *pflag.FlagSet {
+	if c.flagSet == nil {
+		c.flagSet = &pflag.FlagSet{}
+		c.flagSet.StringVar(&c.Prefix, "kms-test-prefix", "test://default", "Key URI prefix")
+	}
+	return c.flagSet
+}
+
+type testFactory struct{}
+
+func (testFactory) Scheme() string {
+	return "test"
+}
+
+func (testFactory) NewConfig() ProviderConfig {
+	return &testConfig{}
+}
+
+func (testFactory) New(_ log.Logger, _ *config.DatastoreConfig, providerConfig ProviderConfig) (Provider, error) {
+	return &testProvider{prefix: providerConfig.(*testConfig).Prefix}, nil
+}
+
+// otherFactory provides the keys of the other scheme without a configuration
+type otherFactory struct{}
+
+func (otherFactory) Scheme() string {
+	return "other"
+}
+
+func (otherFactory) NewConfig() ProviderConfig {
+	return &testConfig{flagSet: &pflag.FlagSet{}}
+}
+
+func (otherFactory) New(log.Logger, *config.DatastoreConfig, ProviderConfig) (Provider, error) {
+	return &testProvider{prefix: "other://host"}, nil
+}
+
+type testProvider struct {
+	prefix  string
+	deleted []string
+}
+
+func (p *testProvider) AEADFromKeyURI(string) (tink.AEAD, error) {
+	return nil, nil
+}
+
+func (p *testProvider) NewAEAD(jwksID string) (tink.AEAD, string, error) {
+	return nil, p.ToRefKeyURI(p.prefix + "/" + jwksID), nil
+}
+
+func (p *testProvider) DeleteKey(refKeyURI string) error {
+	p.deleted = append(p.deleted, refKeyURI)
+	return nil
+}
+
+func (p *testProvider) ToRefKeyURI(keyURI string) string {
+	return strings.Replace(keyURI, p.prefix, "test://ref", 1)
+}
+
+func (p *testProvider) FromRefKeyURI(refKeyURI string) string {
+	return strings.Replace(refKeyURI, "test://ref", p.prefix, 1)
+}
+
+func TestRegistry(t *testing.T) {
+	a := assert.New(t)
+	Register("test", testFactory{}, "test-alias")
+	a.Contains(Providers(), "test")
+	a.Panics(func() { Register("test", testFactory{}) })
+
+	kmsConfig := NewConfig(config.NewDatastoreConfig())
+	a.Nil(kmsConfig.FlagSet().Parse([]string{"--kms-provider", "test-alias", "--kms-test-prefix", "test://host"}))
+	a.NotNil(kmsConfig.FlagSet().Lookup("datastore-provider"))
+
+	provider, err := NewProvider(log.DefaultLogger, kmsConfig)
+	a.Nil(err)
+	_, refKeyURI, err := provider.NewAEAD("jwks-1")
+	a.Nil(err)
+	a.Equal("test://ref/jwks-1", refKeyURI)
+	a.Equal("test://host/jwks-1", provider.FromRefKeyURI(refKeyURI))
+	a.True(StrictBinding(provider), "strict binding is the default")
+	_, ok := provider.(SigningKeyProvider)
+	a.False(ok)
+
+	scheme, err := Scheme(refKeyURI)
+	a.Nil(err)
+	a.Equal("test", scheme)
+
+	a.Nil(kmsConfig.FlagSet().Parse([]string{"--kms-strict-binding=false"}))
+	provider, err = NewProvider(log.DefaultLogger, kmsConfig)
+	a.Nil(err)
+	a.False(StrictBinding(provider))
+
+	kmsConfig.Provider = "unknown"
+	_, err = NewProvider(log.DefaultLogger, kmsConfig)
+	a.NotNil(err)
+}
+
+func TestProviderRoutesByScheme(t *testing.T) {
+	a := assert.New(t)
+	Register("test-routing", testFactory{})
+	Register("other", otherFactory{})
+
+	kmsConfig := NewConfig(config.NewDatastoreConfig())
+	kmsConfig.Provider = "test-routing"
+	provider, err := NewProvider(log.DefaultLogger, kmsConfig)
+	a.Nil(err)
+	configured := provider.(*configuredProvider)
+
+	a.Nil(provider.DeleteKey("test://ref/jwks-1"))
+	a.Nil(provider.DeleteKey("other://ref/jwks-2"))
+	a.Equal([]string{"test://ref/jwks-1"}, configured.Provider.(*testProvider).deleted)
+	a.Equal([]string{"other://ref/jwks-2"}, configured.providers["other"].(*testProvider).deleted)
+
+	_, err = provider.AEADFromKeyURI("other://ref/jwks-2")
+	a.Nil(err)
+	a.Equal(2, len(configured.providers))
+
+	a.NotNil(provider.DeleteKey("unknown://ref/jwks-3"))
+	_, err = provider.AEADFromKeyURI("jwks-3")
+	a.NotNil(err)
+}
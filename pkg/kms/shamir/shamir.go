@@ -0,0 +1,187 @@
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+
+	"github.com/pkg/errors"
+)
+
+// Shamir's secret sharing over GF(2^8). The secret is extended with a short checksum, each share holds one
+// polynomial value per byte of the extended secret followed by the threshold and the x coordinate as the last byte.
+// The checksum detects a wrong secret combined from fewer shares than the threshold or from corrupted shares.
+
+const (
+	MaxShares    = 255
+	minThreshold = 2
+
+	checksumSize = 4
+	// threshold and x coordinate
+	shareTrailerSize = 2
+)
+
+// ErrInsufficientShares is returned by Combine if fewer shares than the threshold are provided
+var ErrInsufficientShares = errors.New("shamir: insufficient shares")
+
+var (
+	expTable [255]byte
+	logTable [256]byte
+)
+
+func init() {
+	// generator 0x03 with the AES reduction polynomial x^8 + x^4 + x^3 + x + 1
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		logTable[x] = byte(i)
+		x = x ^ mulNoTable(x, 2)
+	}
+}
+
+func mulNoTable(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 == 1 {
+			p ^= a
+		}
+		hi := a & 0x80
+		a <<= 1
+		if hi != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])+int(logTable[b]))%255]
+}
+
+func div(a, b byte) byte {
+	if b == 0 {
+		panic("shamir: divide by zero")
+	}
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])-int(logTable[b])+255)%255]
+}
+
+// Split divides the secret into parts shares, any threshold of them are required to reconstruct the secret
+func Split(secret []byte, parts, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("shamir: secret must not be empty")
+	}
+	if parts < threshold {
+		return nil, errors.New("shamir: parts cannot be less than threshold")
+	}
+	if parts > MaxShares {
+		return nil, errors.Errorf("shamir: parts cannot exceed %d", MaxShares)
+	}
+	if threshold < minThreshold {
+		return nil, errors.Errorf("shamir: threshold must be at least %d", minThreshold)
+	}
+	extended := append(append(make([]byte, 0, len(secret)+checksumSize), secret...), checksum(secret)...)
+	shares := make([][]byte, parts)
+	for i := range shares {
+		shares[i] = make([]byte, len(extended)+shareTrailerSize)
+		shares[i][len(extended)] = byte(threshold)
+		shares[i][len(extended)+1] = byte(i + 1)
+	}
+	coefficients := make([]byte, threshold)
+	for idx, b := range extended {
+		coefficients[0] = b
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, errors.Wrap(err, "shamir: generate random coefficients failed")
+		}
+		for i := range shares {
+			shares[i][idx] = evaluate(coefficients, byte(i+1))
+		}
+	}
+	for i := range coefficients {
+		coefficients[i] = 0
+	}
+	for i := range extended {
+		extended[i] = 0
+	}
+	return shares, nil
+}
+
+func checksum(secret []byte) []byte {
+	sum := sha256.Sum256(secret)
+	return sum[:checksumSize]
+}
+
+// Combine reconstructs the secret from the shares. ErrInsufficientShares is returned if fewer shares than the threshold are provided.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < minThreshold {
+		return nil, errors.Errorf("shamir: at least %d shares are required", minThreshold)
+	}
+	shareLen := len(shares[0])
+	if shareLen < checksumSize+shareTrailerSize+1 {
+		return nil, errors.New("shamir: shares are too short")
+	}
+	threshold := shares[0][shareLen-2]
+	xs := make([]byte, len(shares))
+	seen := make(map[byte]struct{}, len(shares))
+	for i, share := range shares {
+		if len(share) != shareLen {
+			return nil, errors.New("shamir: all shares must be the same length")
+		}
+		if share[shareLen-2] != threshold {
+			return nil, errors.New("shamir: shares have different thresholds")
+		}
+		x := share[shareLen-1]
+		if _, ok := seen[x]; ok {
+			return nil, errors.New("shamir: duplicate share detected")
+		}
+		seen[x] = struct{}{}
+		xs[i] = x
+	}
+	if len(shares) < int(threshold) {
+		return nil, errors.Wrapf(ErrInsufficientShares, "%d of %d required shares provided", len(shares), threshold)
+	}
+	extended := make([]byte, shareLen-shareTrailerSize)
+	ys := make([]byte, len(shares))
+	for idx := range extended {
+		for i, share := range shares {
+			ys[i] = share[idx]
+		}
+		extended[idx] = interpolateAtZero(xs, ys)
+	}
+	secret := extended[:len(extended)-checksumSize]
+	if !bytes.Equal(checksum(secret), extended[len(secret):]) {
+		return nil, errors.New("shamir: checksum mismatch, the shares are corrupted or belong to different secrets")
+	}
+	return secret, nil
+}
+
+func evaluate(coefficients []byte, x byte) byte {
+	// Horner's method
+	result := coefficients[len(coefficients)-1]
+	for i := len(coefficients) - 2; i >= 0; i-- {
+		result = mul(result, x) ^ coefficients[i]
+	}
+	return result
+}
+
+func interpolateAtZero(xs, ys []byte) byte {
+	var result byte
+	for i := range xs {
+		basis := byte(1)
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			// in GF(2^8) subtraction is xor: (0 - x_j) / (x_i - x_j)
+			basis = mul(basis, div(xs[j], xs[i]^xs[j]))
+		}
+		result ^= mul(ys[i], basis)
+	}
+	return result
+}
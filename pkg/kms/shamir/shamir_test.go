@@ -0,0 +1,90 @@
+package shamir
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("tribe master secret")
+
+	tests := []struct {
+		parts     int
+		threshold int
+	}{
+		{parts: 2, threshold: 2},
+		{parts: 3, threshold: 2},
+		{parts: 5, threshold: 3},
+		{parts: 10, threshold: 10},
+		{parts: 255, threshold: 100},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%d-%d", tc.parts, tc.threshold), func(t *testing.T) {
+			a := assert.New(t)
+			shares, err := Split(secret, tc.parts, tc.threshold)
+			a.Nil(err)
+			a.Equal(tc.parts, len(shares))
+
+			// any threshold of shares reconstructs the secret
+			for offset := 0; offset+tc.threshold <= tc.parts; offset++ {
+				result, err := Combine(shares[offset : offset+tc.threshold])
+				a.Nil(err)
+				a.Equal(secret, result)
+			}
+			result, err := Combine(shares)
+			a.Nil(err)
+			a.Equal(secret, result)
+
+			if tc.threshold > 2 {
+				_, err := Combine(shares[:tc.threshold-1])
+				a.True(errors.Is(err, ErrInsufficientShares))
+			}
+		})
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	a := assert.New(t)
+	_, err := Split([]byte{}, 3, 2)
+	a.NotNil(err)
+	_, err = Split([]byte("secret"), 2, 3)
+	a.NotNil(err)
+	_, err = Split([]byte("secret"), 256, 3)
+	a.NotNil(err)
+	_, err = Split([]byte("secret"), 3, 1)
+	a.NotNil(err)
+}
+
+func TestCombineInvalid(t *testing.T) {
+	a := assert.New(t)
+	shares, err := Split([]byte("secret"), 3, 2)
+	a.Nil(err)
+	_, err = Combine(shares[:1])
+	a.NotNil(err)
+	_, err = Combine([][]byte{shares[0], shares[0]})
+	a.NotNil(err)
+	_, err = Combine([][]byte{shares[0], shares[1][:3]})
+	a.NotNil(err)
+
+	// corrupted share
+	corrupted := append([]byte{}, shares[1]...)
+	corrupted[0] ^= 0xff
+	_, err = Combine([][]byte{shares[0], corrupted})
+	a.NotNil(err)
+
+	// shares of different secrets
+	other, err := Split([]byte("secret"), 3, 2)
+	a.Nil(err)
+	_, err = Combine([][]byte{shares[0], other[1]})
+	a.NotNil(err)
+
+	// the threshold is part of the share
+	shares, err = Split([]byte("secret"), 5, 3)
+	a.Nil(err)
+	_, err = Combine(shares[:2])
+	a.True(errors.Is(err, ErrInsufficientShares))
+	a.Contains(err.Error(), "insufficient shares")
+}
@@ -0,0 +1,76 @@
+package vaultkms
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/google/tink/go/tink"
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+)
+
+// transitAEAD encrypts with a transit key, the additional data is used as the key derivation context
+type transitAEAD struct {
+	logical     *api.Logical
+	encryptPath string
+	decryptPath string
+}
+
+var _ tink.AEAD = (*transitAEAD)(nil)
+
+func newAEAD(logical *api.Logical, keyPath string) (tink.AEAD, error) {
+	mountPath, name, err := splitKeyPath(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return &transitAEAD{
+		logical:     logical,
+		encryptPath: fmt.Sprintf("%s/encrypt/%s", mountPath, name),
+		decryptPath: fmt.Sprintf("%s/decrypt/%s", mountPath, name),
+	}, nil
+}
+
+// Encrypt encrypts the plaintext data using a key stored in HashiCorp Vault.
+func (a *transitAEAD) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	req := map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+		"context":   base64.StdEncoding.EncodeToString(additionalData),
+	}
+	s, err := a.logical.Write(a.encryptPath, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "vault transit encrypt failed")
+	}
+	ciphertext, err := getString(s, "ciphertext")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(ciphertext), nil
+}
+
+// Decrypt decrypts the ciphertext using a key stored in HashiCorp Vault.
+func (a *transitAEAD) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	req := map[string]interface{}{
+		"ciphertext": string(ciphertext),
+		"context":    base64.StdEncoding.EncodeToString(additionalData),
+	}
+	s, err := a.logical.Write(a.decryptPath, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "vault transit decrypt failed")
+	}
+	plaintext64, err := getString(s, "plaintext")
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(plaintext64)
+}
+
+func getString(s *api.Secret, key string) (string, error) {
+	if s == nil || s.Data == nil {
+		return "", errors.Errorf("vault response without data, expected %s", key)
+	}
+	value, ok := s.Data[key].(string)
+	if !ok {
+		return "", errors.Errorf("vault response without %s", key)
+	}
+	return value, nil
+}
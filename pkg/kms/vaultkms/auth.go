@@ -0,0 +1,273 @@
+package vaultkms
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+)
+
+const (
+	AuthMethodToken      = "token"
+	AuthMethodAppRole    = "approle"
+	AuthMethodKubernetes = "kubernetes"
+	AuthMethodCert       = "cert"
+
+	reloginBackoff = 5 * time.Second
+)
+
+// authenticator logs in with the configured auth method and keeps the token renewed in background
+type authenticator struct {
+	logger      log.Logger
+	client      *api.Client
+	vaultConfig *config.VaultConfig
+
+	mu     sync.Mutex
+	secret *api.Secret
+	stopCh chan struct{}
+	once   sync.Once
+}
+
+func newAuthenticator(logger log.Logger, client *api.Client, vaultConfig *config.VaultConfig) (*authenticator, error) {
+	switch vaultConfig.AuthMethod {
+	case "", AuthMethodToken, AuthMethodAppRole, AuthMethodKubernetes, AuthMethodCert:
+	default:
+		return nil, errors.Errorf("unsupported vault auth method %s", vaultConfig.AuthMethod)
+	}
+	return &authenticator{
+		logger:      logger,
+		client:      client,
+		vaultConfig: vaultConfig,
+		stopCh:      make(chan struct{}),
+	}, nil
+}
+
+func (a *authenticator) login() error {
+	var (
+		secret *api.Secret
+		err    error
+	)
+	switch a.vaultConfig.AuthMethod {
+	case "", AuthMethodToken:
+		secret, err = a.loginToken()
+	case AuthMethodAppRole:
+		secret, err = a.loginAppRole()
+	case AuthMethodKubernetes:
+		secret, err = a.loginKubernetes()
+	case AuthMethodCert:
+		secret, err = a.loginCert()
+	}
+	if err != nil {
+		return err
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return errors.Errorf("vault %s login returned no token", a.vaultConfig.AuthMethod)
+	}
+	// the shared client keeps its token until the new one is set, concurrent requests never run without a token
+	a.client.SetToken(secret.Auth.ClientToken)
+
+	a.mu.Lock()
+	a.secret = secret
+	a.mu.Unlock()
+	return nil
+}
+
+// loginToken looks up the static token to find out whether it can be renewed
+func (a *authenticator) loginToken() (*api.Secret, error) {
+	token, err := a.vaultConfig.GetToken()
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, errors.New("vault token is empty")
+	}
+	loginClient, err := a.loginClient()
+	if err != nil {
+		return nil, err
+	}
+	loginClient.SetToken(token)
+	s, err := loginClient.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, errors.Wrap(err, "vault token lookup failed")
+	}
+	ttl, err := s.TokenTTL()
+	if err != nil {
+		return nil, err
+	}
+	renewable, err := s.TokenIsRenewable()
+	if err != nil {
+		return nil, err
+	}
+	return &api.Secret{
+		Auth: &api.SecretAuth{
+			ClientToken:   token,
+			Renewable:     renewable,
+			LeaseDuration: int(ttl.Seconds()),
+		},
+	}, nil
+}
+
+func (a *authenticator) loginAppRole() (*api.Secret, error) {
+	cfg := a.vaultConfig.AppRole
+	if cfg.RoleID == "" {
+		return nil, errors.New("vault approle role id is empty")
+	}
+	secretID, err := cfg.GetSecretID()
+	if err != nil {
+		return nil, err
+	}
+	return a.write(cfg.MountPath, map[string]interface{}{
+		"role_id":   cfg.RoleID,
+		"secret_id": secretID,
+	})
+}
+
+func (a *authenticator) loginKubernetes() (*api.Secret, error) {
+	cfg := a.vaultConfig.Kubernetes
+	if cfg.Role == "" {
+		return nil, errors.New("vault kubernetes role is empty")
+	}
+	jwt, err := ioutil.ReadFile(cfg.JWTPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read kubernetes service account token %s failed", cfg.JWTPath)
+	}
+	return a.write(cfg.MountPath, map[string]interface{}{
+		"role": cfg.Role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+}
+
+// loginCert uses the client certificate from the TLS configuration
+func (a *authenticator) loginCert() (*api.Secret, error) {
+	cfg := a.vaultConfig.Cert
+	if a.vaultConfig.TLSConfig.Cert == "" || a.vaultConfig.TLSConfig.Key == "" {
+		return nil, errors.New("vault cert auth requires vault-tls-cert and vault-tls-key")
+	}
+	data := map[string]interface{}{}
+	if cfg.Role != "" {
+		data["name"] = cfg.Role
+	}
+	return a.write(cfg.MountPath, data)
+}
+
+func (a *authenticator) write(mountPath string, data map[string]interface{}) (*api.Secret, error) {
+	loginClient, err := a.loginClient()
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("auth/%s/login", strings.Trim(mountPath, "/"))
+	s, err := loginClient.Logical().Write(path, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "vault %s login failed", a.vaultConfig.AuthMethod)
+	}
+	return s, nil
+}
+
+// loginClient returns a copy of the client without a token, login requests must not carry a previous token
+// and must not change the token of the shared client
+func (a *authenticator) loginClient() (*api.Client, error) {
+	c, err := a.client.Clone()
+	if err != nil {
+		return nil, errors.Wrap(err, "clone vault client failed")
+	}
+	return c, nil
+}
+
+// startRenewal renews the token in background. When the token cannot be renewed anymore, a new login is made.
+// Static tokens which are not renewable are left as they are.
+func (a *authenticator) startRenewal() {
+	go func() {
+		for {
+			a.mu.Lock()
+			secret := a.secret
+			a.mu.Unlock()
+
+			if !secret.Auth.Renewable {
+				if a.isStaticToken() {
+					return
+				}
+				if !a.waitForExpiry(secret) {
+					return
+				}
+			} else if !a.renew(secret) {
+				return
+			}
+			if a.isStaticToken() {
+				a.logger.Warnf("vault token cannot be renewed anymore")
+				return
+			}
+			for {
+				err := a.login()
+				if err == nil {
+					a.logger.Infof("vault %s login renewed", a.vaultConfig.AuthMethod)
+					break
+				}
+				a.logger.Errorf("vault %s login failed: %v", a.vaultConfig.AuthMethod, err)
+				select {
+				case <-a.stopCh:
+					return
+				case <-time.After(reloginBackoff):
+				}
+			}
+		}
+	}()
+}
+
+// renew returns false if the authenticator was stopped
+func (a *authenticator) renew(secret *api.Secret) bool {
+	renewer, err := a.client.NewRenewer(&api.RenewerInput{Secret: secret})
+	if err != nil {
+		a.logger.Errorf("create vault token renewer failed: %v", err)
+		return a.waitForExpiry(secret)
+	}
+	go renewer.Renew()
+	defer renewer.Stop()
+
+	for {
+		select {
+		case <-a.stopCh:
+			return false
+		case err := <-renewer.DoneCh():
+			if err != nil {
+				a.logger.Warnf("vault token renewal stopped: %v", err)
+			}
+			return true
+		case r := <-renewer.RenewCh():
+			if r != nil && r.Secret != nil && r.Secret.Auth != nil {
+				a.logger.Debugf("vault token renewed, lease duration %ds", r.Secret.Auth.LeaseDuration)
+			}
+		}
+	}
+}
+
+// waitForExpiry returns false if the authenticator was stopped
+func (a *authenticator) waitForExpiry(secret *api.Secret) bool {
+	ttl := time.Duration(secret.Auth.LeaseDuration) * time.Second
+	// re-login shortly before the token expires
+	wait := ttl * 2 / 3
+	if wait < reloginBackoff {
+		wait = reloginBackoff
+	}
+	select {
+	case <-a.stopCh:
+		return false
+	case <-time.After(wait):
+		return true
+	}
+}
+
+func (a *authenticator) isStaticToken() bool {
+	return a.vaultConfig.AuthMethod == "" || a.vaultConfig.AuthMethod == AuthMethodToken
+}
+
+func (a *authenticator) stop() {
+	a.once.Do(func() {
+		close(a.stopCh)
+	})
+}
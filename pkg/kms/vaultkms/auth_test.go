@@ -0,0 +1,49 @@
+package vaultkms
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticatorLoginKeepsSharedToken(t *testing.T) {
+	var (
+		vaultClient *api.Client
+		loginToken  string
+		sharedToken string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loginToken = r.Header.Get("X-Vault-Token")
+		sharedToken = vaultClient.Token()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"auth": map[string]interface{}{"client_token": "new-token", "lease_duration": 60, "renewable": true},
+		})
+	}))
+	defer server.Close()
+
+	vaultClient, err := api.NewClient(&api.Config{Address: server.URL})
+	if !assert.NoError(t, err) {
+		return
+	}
+	vaultClient.SetToken("old-token")
+	vaultConfig := &config.VaultConfig{
+		AuthMethod: AuthMethodAppRole,
+		AppRole:    config.VaultAppRoleConfig{MountPath: "approle", RoleID: "role", SecretID: "secret"},
+	}
+	auth, err := newAuthenticator(log.NewDefaultLogger(), vaultClient, vaultConfig)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, auth.login()) {
+		return
+	}
+	assert.Empty(t, loginToken, "login request must not carry the previous token")
+	assert.Equal(t, "old-token", sharedToken, "login must not clear the token of the shared client")
+	assert.Equal(t, "new-token", vaultClient.Token())
+}
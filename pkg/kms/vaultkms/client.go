@@ -0,0 +1,180 @@
+package vaultkms
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/tink/go/core/registry"
+	"github.com/google/tink/go/tink"
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/grepplabs/tribe/pkg/secret"
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+)
+
+const (
+	vaultPrefix = "hcvault://"
+	// RefKeyURIPrefix replaces the vault host in stored key URIs, so the vault address can change
+	RefKeyURIPrefix = "hcvault://vault"
+
+	// keyVersionParam pins the version of a signing key in the key URI
+	keyVersionParam = "key_version"
+)
+
+var _ registry.KMSClient = (*Client)(nil)
+
+// Client is a tink KMS client for the Vault transit secrets engine supporting several auth methods and token renewal
+type Client struct {
+	keyURIPrefix string
+	logger       log.Logger
+	client       *api.Client
+	transit      config.VaultTransitConfig
+	auth         *authenticator
+}
+
+func NewClient(logger log.Logger, vaultConfig *config.VaultConfig) (*Client, error) {
+	vurl, err := url.Parse(vaultConfig.Address)
+	if err != nil {
+		return nil, err
+	}
+	if vurl.Scheme != "https" && vurl.Scheme != "http" {
+		return nil, errors.Errorf("vault address with http or https schema expected, but got %s", vurl.Scheme)
+	}
+	if vurl.Host == "" {
+		return nil, errors.Errorf("vault address is empty, address %s", vaultConfig.Address)
+	}
+	tlsConfig, err := vaultConfig.TLSConfig.NewClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	apiConfig := api.DefaultConfig()
+	if apiConfig.Error != nil {
+		return nil, apiConfig.Error
+	}
+	apiConfig.Address = vaultConfig.Address
+	apiConfig.HttpClient.Transport.(*http.Transport).TLSClientConfig = tlsConfig
+
+	apiClient, err := api.NewClient(apiConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "create vault client failed")
+	}
+	// the token is set by the authenticator
+	apiClient.ClearToken()
+	if vaultConfig.Namespace != "" {
+		apiClient.SetNamespace(vaultConfig.Namespace)
+	}
+	c := &Client{
+		keyURIPrefix: fmt.Sprintf("%s%s", vaultPrefix, vurl.Host),
+		logger:       logger.WithName("vaultkms-client"),
+		client:       apiClient,
+		transit:      vaultConfig.Transit,
+	}
+	c.auth, err = newAuthenticator(c.logger, apiClient, vaultConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.auth.login(); err != nil {
+		return nil, err
+	}
+	c.auth.startRenewal()
+	return c, nil
+}
+
+// NewSecretReader returns the reader of the vault:// secret references, which uses the vault address, TLS and auth configuration
+func NewSecretReader(logger log.Logger, vaultConfig *config.VaultConfig) (secret.VaultReader, error) {
+	c, err := NewClient(logger, vaultConfig)
+	if err != nil {
+		return nil, err
+	}
+	return c.API().Logical(), nil
+}
+
+// Close stops the background token renewal
+func (c *Client) Close() {
+	c.auth.stop()
+}
+
+// API returns the underlying authenticated vault client
+func (c *Client) API() *api.Client {
+	return c.client
+}
+
+// Supported returns true if this client does support keyURI.
+func (c *Client) Supported(keyURI string) bool {
+	return strings.HasPrefix(keyURI, c.keyURIPrefix)
+}
+
+// GetAEAD gets an AEAD backend by keyURI.
+func (c *Client) GetAEAD(keyURI string) (tink.AEAD, error) {
+	keyPath, err := c.keyPath(keyURI)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(c.client.Logical(), keyPath)
+}
+
+// KeyURI returns the key URI of the transit key name
+func (c *Client) KeyURI(name string) string {
+	return fmt.Sprintf("%s/%s/keys/%s", c.keyURIPrefix, strings.Trim(c.transit.MountPath, "/"), name)
+}
+
+// ToRefKeyURI replaces the vault host by the reference prefix
+func (c *Client) ToRefKeyURI(keyURI string) string {
+	return strings.Replace(keyURI, c.keyURIPrefix, RefKeyURIPrefix, 1)
+}
+
+// FromRefKeyURI replaces the reference prefix by the vault host
+func (c *Client) FromRefKeyURI(refKeyURI string) string {
+	return strings.Replace(refKeyURI, RefKeyURIPrefix, c.keyURIPrefix, 1)
+}
+
+// keyPath returns the vault path e.g. transit/keys/key-foo, the pinned key version is not part of the path
+func (c *Client) keyPath(keyURI string) (string, error) {
+	if !c.Supported(keyURI) {
+		return "", errors.Errorf("unsupported keyURI %s", keyURI)
+	}
+	keyURI, _, err := splitKeyVersion(keyURI)
+	if err != nil {
+		return "", err
+	}
+	keyPath := strings.TrimLeft(strings.TrimPrefix(keyURI, c.keyURIPrefix), "/")
+	if _, _, err := splitKeyPath(keyPath); err != nil {
+		return "", err
+	}
+	return keyPath, nil
+}
+
+// withKeyVersion pins the key version in the key URI e.g. hcvault://vault/transit/keys/key-foo?key_version=1
+func withKeyVersion(keyURI string, version int) string {
+	return fmt.Sprintf("%s?%s=%d", keyURI, keyVersionParam, version)
+}
+
+// splitKeyVersion returns the key URI without the pinned key version and the version, 0 if not pinned
+func splitKeyVersion(keyURI string) (string, int, error) {
+	idx := strings.Index(keyURI, "?")
+	if idx < 0 {
+		return keyURI, 0, nil
+	}
+	query, err := url.ParseQuery(keyURI[idx+1:])
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "malformed key URI %s", keyURI)
+	}
+	version, err := strconv.Atoi(query.Get(keyVersionParam))
+	if err != nil || version <= 0 {
+		return "", 0, errors.Errorf("malformed key version of key URI %s", keyURI)
+	}
+	return keyURI[:idx], version, nil
+}
+
+// splitKeyPath splits transit/keys/key-foo into the mount path and the key name
+func splitKeyPath(keyPath string) (string, string, error) {
+	idx := strings.LastIndex(keyPath, "/keys/")
+	if idx <= 0 || idx+len("/keys/") == len(keyPath) {
+		return "", "", errors.Errorf("malformed transit key path %s", keyPath)
+	}
+	return keyPath[:idx], keyPath[idx+len("/keys/"):], nil
+}
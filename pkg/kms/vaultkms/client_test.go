@@ -0,0 +1,149 @@
+package vaultkms
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2"
+)
+
+func TestSplitKeyPath(t *testing.T) {
+	tests := []struct {
+		keyPath   string
+		mountPath string
+		name      string
+		hasErr    bool
+	}{
+		{keyPath: "transit/keys/tribe-jwks-1", mountPath: "transit", name: "tribe-jwks-1"},
+		{keyPath: "team/transit/keys/tribe-jwks-1", mountPath: "team/transit", name: "tribe-jwks-1"},
+		{keyPath: "transit/keys/", hasErr: true},
+		{keyPath: "keys/tribe-jwks-1", hasErr: true},
+		{keyPath: "transit/tribe-jwks-1", hasErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.keyPath, func(t *testing.T) {
+			a := assert.New(t)
+			mountPath, name, err := splitKeyPath(tc.keyPath)
+			if tc.hasErr {
+				a.NotNil(err)
+			} else {
+				a.Nil(err)
+				a.Equal(tc.mountPath, mountPath)
+				a.Equal(tc.name, name)
+			}
+		})
+	}
+}
+
+func TestSplitKeyVersion(t *testing.T) {
+	a := assert.New(t)
+	keyURI := "hcvault://vault/transit/keys/tribe-jwks-1"
+
+	base, version, err := splitKeyVersion(keyURI)
+	a.Nil(err)
+	a.Equal(keyURI, base)
+	a.Equal(0, version)
+
+	base, version, err = splitKeyVersion(withKeyVersion(keyURI, 3))
+	a.Nil(err)
+	a.Equal(keyURI, base)
+	a.Equal(3, version)
+
+	for _, malformed := range []string{keyURI + "?key_version=", keyURI + "?key_version=0", keyURI + "?key_version=x", keyURI + "?other=1"} {
+		_, _, err = splitKeyVersion(malformed)
+		a.NotNil(err, malformed)
+	}
+
+	client := &Client{keyURIPrefix: RefKeyURIPrefix}
+	keyPath, err := client.keyPath(withKeyVersion(keyURI, 3))
+	a.Nil(err)
+	a.Equal("transit/keys/tribe-jwks-1", keyPath)
+}
+
+// TestVaultDev runs against `vault server -dev -dev-root-token-id=tribe-root-token` with the transit engine enabled, see make vault-dev
+func TestVaultDev(t *testing.T) {
+	a := assert.New(t)
+	client := newTestVaultDevClient(t)
+	defer client.Close()
+
+	keyURI := client.KeyURI(fmt.Sprintf("tribe-test-%d", time.Now().UnixNano()))
+	a.True(client.Supported(keyURI))
+	a.Equal(keyURI, client.FromRefKeyURI(client.ToRefKeyURI(keyURI)))
+
+	a.Nil(client.EnsureKey(keyURI))
+	a.Nil(client.EnsureKey(keyURI), "ensure key must be idempotent")
+
+	aead, err := client.GetAEAD(keyURI)
+	a.Nil(err)
+	ciphertext, err := aead.Encrypt([]byte("plaintext"), []byte("jwks-1"))
+	a.Nil(err)
+	plaintext, err := aead.Decrypt(ciphertext, []byte("jwks-1"))
+	a.Nil(err)
+	a.Equal([]byte("plaintext"), plaintext)
+	_, err = aead.Decrypt(ciphertext, []byte("jwks-2"))
+	a.NotNil(err, "decrypt with a different context should fail")
+
+	a.Nil(client.DeleteKey(keyURI))
+	_, err = aead.Decrypt(ciphertext, []byte("jwks-1"))
+	a.NotNil(err, "decrypt with a deleted key should fail")
+}
+
+func TestVaultDevSign(t *testing.T) {
+	client := newTestVaultDevClient(t)
+	defer client.Close()
+
+	for _, alg := range []jose.SignatureAlgorithm{jose.RS256, jose.PS256, jose.ES256, jose.ES384, jose.EdDSA} {
+		t.Run(string(alg), func(t *testing.T) {
+			a := assert.New(t)
+			keyURI := client.KeyURI(fmt.Sprintf("tribe-test-sign-%d", time.Now().UnixNano()))
+			defer func() { _ = client.DeleteKey(keyURI) }()
+
+			publicKey, versionKeyURI, err := client.CreateSigningKey(keyURI, alg)
+			a.Nil(err)
+			a.Equal(withKeyVersion(keyURI, 1), versionKeyURI)
+			_, _, err = client.CreateSigningKey(keyURI, alg)
+			a.NotNil(err, "signing key must not be overwritten")
+
+			// the signers keep the created version after a rotation of the transit key
+			keyPath, err := client.keyPath(keyURI)
+			a.Nil(err)
+			_, err = client.API().Logical().Write(keyPath+"/rotate", nil)
+			a.Nil(err)
+
+			jwk := jose.JSONWebKey{Key: publicKey, KeyID: "kid-1", Algorithm: string(alg), Use: "sig"}
+			for _, signerKeyURI := range []string{versionKeyURI, keyURI} {
+				opaqueSigner, err := client.NewSigner(signerKeyURI, jwk)
+				a.Nil(err)
+				signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: opaqueSigner}, nil)
+				a.Nil(err)
+				jws, err := signer.Sign([]byte("payload"))
+				a.Nil(err)
+				payload, err := jws.Verify(publicKey)
+				a.Nil(err)
+				a.Equal([]byte("payload"), payload)
+			}
+		})
+	}
+}
+
+func newTestVaultDevClient(t *testing.T) *Client {
+	addr := os.Getenv("TRIBE_TEST_VAULT_ADDR")
+	if addr == "" {
+		t.Skip("TRIBE_TEST_VAULT_ADDR is not set")
+	}
+	vaultConfig := config.NewVaultConfig()
+	assert.Nil(t, vaultConfig.FlagSet().Parse([]string{"--vault-addr", addr}))
+	if token := os.Getenv("TRIBE_TEST_VAULT_TOKEN"); token != "" {
+		vaultConfig.Token = token
+	}
+	client, err := NewClient(log.DefaultLogger, vaultConfig)
+	if err != nil {
+		t.Fatalf("create vault client failed: %v", err)
+	}
+	return client
+}
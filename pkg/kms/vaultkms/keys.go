@@ -0,0 +1,53 @@
+package vaultkms
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// EnsureKey creates the transit key of the key URI if it does not exist and auto creation is enabled
+func (c *Client) EnsureKey(keyURI string) error {
+	keyPath, err := c.keyPath(keyURI)
+	if err != nil {
+		return err
+	}
+	s, err := c.client.Logical().Read(keyPath)
+	if err != nil {
+		return errors.Wrapf(err, "read transit key %s failed", keyPath)
+	}
+	if s != nil {
+		return nil
+	}
+	if !c.transit.AutoCreateKey {
+		return errors.Errorf("transit key %s does not exist and auto creation is disabled", keyPath)
+	}
+	req := map[string]interface{}{
+		"type":    c.transit.KeyType,
+		"derived": c.transit.KeyDerived,
+	}
+	if _, err = c.client.Logical().Write(keyPath, req); err != nil {
+		return errors.Wrapf(err, "create transit key %s failed", keyPath)
+	}
+	c.logger.Infof("transit key %s created, type %s", keyPath, c.transit.KeyType)
+	return nil
+}
+
+// DeleteKey deletes the transit key of the key URI, deletion is allowed on the key before
+func (c *Client) DeleteKey(keyURI string) error {
+	keyPath, err := c.keyPath(keyURI)
+	if err != nil {
+		return err
+	}
+	_, err = c.client.Logical().Write(fmt.Sprintf("%s/config", keyPath), map[string]interface{}{
+		"deletion_allowed": true,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "allow deletion of transit key %s failed", keyPath)
+	}
+	if _, err = c.client.Logical().Delete(keyPath); err != nil {
+		return errors.Wrapf(err, "delete transit key %s failed", keyPath)
+	}
+	c.logger.Infof("transit key %s deleted", keyPath)
+	return nil
+}
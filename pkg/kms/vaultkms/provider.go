@@ -0,0 +1,93 @@
+package vaultkms
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/google/tink/go/tink"
+	"github.com/grepplabs/tribe/config"
+	"github.com/grepplabs/tribe/pkg/kms"
+	"github.com/grepplabs/tribe/pkg/log"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+const ProviderName = "vault"
+
+func init() {
+	kms.Register(ProviderName, factory{}, "hcvault")
+}
+
+type factory struct{}
+
+func (factory) Scheme() string {
+	return "hcvault"
+}
+
+func (factory) NewConfig() kms.ProviderConfig {
+	return config.NewVaultConfig()
+}
+
+func (factory) New(logger log.Logger, _ *config.DatastoreConfig, providerConfig kms.ProviderConfig) (kms.Provider, error) {
+	cfg, ok := providerConfig.(*config.VaultConfig)
+	if !ok {
+		return nil, errors.Errorf("unexpected %s kms provider config %T", ProviderName, providerConfig)
+	}
+	vaultClient, err := NewClient(logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &provider{client: vaultClient}, nil
+}
+
+// provider encrypts every JWKS with its own transit key. The reference key URIs do not contain the vault address.
+type provider struct {
+	client *Client
+}
+
+var _ kms.SigningKeyProvider = (*provider)(nil)
+
+func (p *provider) AEADFromKeyURI(refKeyURI string) (tink.AEAD, error) {
+	return p.client.GetAEAD(p.client.FromRefKeyURI(refKeyURI))
+}
+
+func (p *provider) NewAEAD(jwksID string) (tink.AEAD, string, error) {
+	keyURI := p.client.KeyURI(jwksKeyName(jwksID))
+	if err := p.client.EnsureKey(keyURI); err != nil {
+		return nil, "", err
+	}
+	aead, err := p.client.GetAEAD(keyURI)
+	if err != nil {
+		return nil, "", err
+	}
+	return aead, p.client.ToRefKeyURI(keyURI), nil
+}
+
+func (p *provider) DeleteKey(refKeyURI string) error {
+	return p.client.DeleteKey(p.client.FromRefKeyURI(refKeyURI))
+}
+
+func (p *provider) ToRefKeyURI(keyURI string) string {
+	return p.client.ToRefKeyURI(keyURI)
+}
+
+func (p *provider) FromRefKeyURI(refKeyURI string) string {
+	return p.client.FromRefKeyURI(refKeyURI)
+}
+
+func (p *provider) NewSigningKey(jwksID string, alg jose.SignatureAlgorithm) (crypto.PublicKey, string, error) {
+	keyURI := p.client.KeyURI(jwksKeyName(jwksID))
+	publicKey, keyURI, err := p.client.CreateSigningKey(keyURI, alg)
+	if err != nil {
+		return nil, "", err
+	}
+	return publicKey, p.client.ToRefKeyURI(keyURI), nil
+}
+
+func (p *provider) Signer(refKeyURI string, publicKey jose.JSONWebKey) (jose.OpaqueSigner, error) {
+	return p.client.NewSigner(p.client.FromRefKeyURI(refKeyURI), publicKey)
+}
+
+func jwksKeyName(jwksID string) string {
+	return fmt.Sprintf("tribe-jwks-%s", jwksID)
+}
@@ -0,0 +1,295 @@
+package vaultkms
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// transit key types of the signature algorithms, RSA keys use the default key size of the key generator (keygen.RSADefaultKeySize)
+var signingKeyTypes = map[jose.SignatureAlgorithm]string{
+	jose.RS256: "rsa-4096",
+	jose.RS384: "rsa-4096",
+	jose.RS512: "rsa-4096",
+	jose.PS256: "rsa-4096",
+	jose.PS384: "rsa-4096",
+	jose.PS512: "rsa-4096",
+	jose.ES256: "ecdsa-p256",
+	jose.ES384: "ecdsa-p384",
+	jose.ES512: "ecdsa-p521",
+	jose.EdDSA: "ed25519",
+}
+
+// SigningKeyType returns the transit key type for the signature algorithm
+func SigningKeyType(alg jose.SignatureAlgorithm) (string, error) {
+	keyType, ok := signingKeyTypes[alg]
+	if !ok {
+		return "", errors.Errorf("alg %s is not supported by vault transit signing", alg)
+	}
+	return keyType, nil
+}
+
+// CreateSigningKey creates a non exportable transit key pair for the signature algorithm and returns its public key
+// together with the key URI pinned to the created key version
+func (c *Client) CreateSigningKey(keyURI string, alg jose.SignatureAlgorithm) (crypto.PublicKey, string, error) {
+	keyType, err := SigningKeyType(alg)
+	if err != nil {
+		return nil, "", err
+	}
+	keyPath, err := c.keyPath(keyURI)
+	if err != nil {
+		return nil, "", err
+	}
+	s, err := c.client.Logical().Read(keyPath)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "read transit key %s failed", keyPath)
+	}
+	if s != nil {
+		return nil, "", errors.Errorf("transit key %s already exists", keyPath)
+	}
+	_, err = c.client.Logical().Write(keyPath, map[string]interface{}{
+		"type":       keyType,
+		"exportable": false,
+	})
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "create transit key %s failed", keyPath)
+	}
+	c.logger.Infof("transit signing key %s created, type %s", keyPath, keyType)
+	s, err = c.readSigningKey(keyPath)
+	if err != nil {
+		return nil, "", err
+	}
+	version, err := jsonInt(s.Data["latest_version"])
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "transit key %s latest version", keyPath)
+	}
+	publicKey, err := transitPublicKey(s, keyPath, version)
+	if err != nil {
+		return nil, "", err
+	}
+	return publicKey, withKeyVersion(keyURI, version), nil
+}
+
+// PublicKey returns the public key of the pinned or the latest version of the transit key pair
+func (c *Client) PublicKey(keyURI string) (crypto.PublicKey, error) {
+	keyPath, err := c.keyPath(keyURI)
+	if err != nil {
+		return nil, err
+	}
+	_, version, err := splitKeyVersion(keyURI)
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.readSigningKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		if version, err = jsonInt(s.Data["latest_version"]); err != nil {
+			return nil, errors.Wrapf(err, "transit key %s latest version", keyPath)
+		}
+	}
+	return transitPublicKey(s, keyPath, version)
+}
+
+func (c *Client) readSigningKey(keyPath string) (*api.Secret, error) {
+	s, err := c.client.Logical().Read(keyPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read transit key %s failed", keyPath)
+	}
+	if s == nil || s.Data == nil {
+		return nil, errors.Errorf("transit key %s not found", keyPath)
+	}
+	return s, nil
+}
+
+// signingKeyVersion returns the version of the transit key pair holding the public key
+func (c *Client) signingKeyVersion(keyPath string, publicKey crypto.PublicKey) (int, error) {
+	s, err := c.readSigningKey(keyPath)
+	if err != nil {
+		return 0, err
+	}
+	keys, ok := s.Data["keys"].(map[string]interface{})
+	if !ok {
+		return 0, errors.Errorf("transit key %s has no keys", keyPath)
+	}
+	for name := range keys {
+		version, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		versionKey, err := transitPublicKey(s, keyPath, version)
+		if err != nil {
+			return 0, err
+		}
+		if key, ok := versionKey.(interface{ Equal(crypto.PublicKey) bool }); ok && key.Equal(publicKey) {
+			return version, nil
+		}
+	}
+	return 0, errors.Errorf("transit key %s has no version of the public key", keyPath)
+}
+
+// transitPublicKey returns the public key of the transit key version
+func transitPublicKey(s *api.Secret, keyPath string, version int) (crypto.PublicKey, error) {
+	keys, ok := s.Data["keys"].(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("transit key %s has no keys", keyPath)
+	}
+	versionKey, ok := keys[strconv.Itoa(version)].(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("transit key %s has no version %d", keyPath, version)
+	}
+	publicKeyPEM, ok := versionKey["public_key"].(string)
+	if !ok || publicKeyPEM == "" {
+		return nil, errors.Errorf("transit key %s is not an asymmetric key", keyPath)
+	}
+	if s.Data["type"] == "ed25519" {
+		// ed25519 public keys are base64 encoded raw keys
+		raw, err := base64.StdEncoding.DecodeString(publicKeyPEM)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, errors.Errorf("transit key %s has invalid ed25519 public key", keyPath)
+		}
+		return ed25519.PublicKey(raw), nil
+	}
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, errors.Errorf("transit key %s public key is not PEM encoded", keyPath)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// NewSigner returns a signer which signs with the transit key pair, the private key never leaves vault.
+// The public key must be the key returned by CreateSigningKey. Every signature is made by the key version pinned
+// in the key URI, so a rotation of the transit key does not change the signing key.
+// Key URIs without the version are pinned to the version holding the public key.
+func (c *Client) NewSigner(keyURI string, publicKey jose.JSONWebKey) (jose.OpaqueSigner, error) {
+	keyPath, err := c.keyPath(keyURI)
+	if err != nil {
+		return nil, err
+	}
+	_, version, err := splitKeyVersion(keyURI)
+	if err != nil {
+		return nil, err
+	}
+	mountPath, name, err := splitKeyPath(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if !publicKey.IsPublic() {
+		return nil, errors.New("transit signer requires a public key")
+	}
+	alg := jose.SignatureAlgorithm(publicKey.Algorithm)
+	if _, err = SigningKeyType(alg); err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		if version, err = c.signingKeyVersion(keyPath, publicKey.Key); err != nil {
+			return nil, err
+		}
+	}
+	return &transitSigner{
+		logical:    c.client.Logical(),
+		signPath:   fmt.Sprintf("%s/sign/%s", mountPath, name),
+		publicKey:  publicKey,
+		alg:        alg,
+		keyVersion: version,
+	}, nil
+}
+
+type transitSigner struct {
+	logical    *api.Logical
+	signPath   string
+	publicKey  jose.JSONWebKey
+	alg        jose.SignatureAlgorithm
+	keyVersion int
+}
+
+var _ jose.OpaqueSigner = (*transitSigner)(nil)
+
+func (s *transitSigner) Public() *jose.JSONWebKey {
+	key := s.publicKey
+	return &key
+}
+
+func (s *transitSigner) Algs() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{s.alg}
+}
+
+// SignPayload signs with transit/sign. ECDSA signatures are requested in the JWS format (r || s), RSA PSS uses the hash length as salt length.
+// Ed25519 signs the payload itself, the hash algorithm is not used.
+func (s *transitSigner) SignPayload(payload []byte, alg jose.SignatureAlgorithm) ([]byte, error) {
+	if alg != s.alg {
+		return nil, errors.Errorf("transit signer supports %s, but %s was requested", s.alg, alg)
+	}
+	req := map[string]interface{}{
+		"input":       base64.StdEncoding.EncodeToString(payload),
+		"key_version": s.keyVersion,
+	}
+	var hashAlg string
+	switch alg {
+	case jose.RS256, jose.PS256, jose.ES256:
+		hashAlg = "sha2-256"
+	case jose.RS384, jose.PS384, jose.ES384:
+		hashAlg = "sha2-384"
+	case jose.RS512, jose.PS512, jose.ES512:
+		hashAlg = "sha2-512"
+	}
+	switch alg {
+	case jose.RS256, jose.RS384, jose.RS512:
+		req["signature_algorithm"] = "pkcs1v15"
+	case jose.PS256, jose.PS384, jose.PS512:
+		req["signature_algorithm"] = "pss"
+		req["salt_length"] = "hash"
+	case jose.ES256, jose.ES384, jose.ES512:
+		req["marshaling_algorithm"] = "jws"
+	}
+	signPath := s.signPath
+	if hashAlg != "" {
+		signPath = fmt.Sprintf("%s/%s", s.signPath, hashAlg)
+	}
+	resp, err := s.logical.Write(signPath, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "vault transit sign failed")
+	}
+	signature, err := getString(resp, "signature")
+	if err != nil {
+		return nil, err
+	}
+	// vault:v1:<signature>
+	parts := strings.SplitN(signature, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, errors.New("malformed vault transit signature")
+	}
+	if parts[1] != fmt.Sprintf("v%d", s.keyVersion) {
+		return nil, errors.Errorf("vault transit signed with key %s, but version %d was requested", parts[1], s.keyVersion)
+	}
+	switch alg {
+	case jose.ES256, jose.ES384, jose.ES512:
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	default:
+		return base64.StdEncoding.DecodeString(parts[2])
+	}
+}
+
+func jsonInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case float64:
+		return int(v), nil
+	case interface{ Int64() (int64, error) }:
+		i, err := v.Int64()
+		return int(i), err
+	default:
+		return 0, errors.Errorf("unexpected number type %T", value)
+	}
+}
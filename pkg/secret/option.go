@@ -0,0 +1,21 @@
+package secret
+
+import (
+	"io"
+)
+
+type Option func(*Resolver)
+
+func WithStdin(stdin io.Reader) Option {
+	return func(r *Resolver) {
+		r.stdin = stdin
+	}
+}
+
+func WithVaultReader(vaultReader VaultReader) Option {
+	return func(r *Resolver) {
+		r.newVaultReader = func() (VaultReader, error) {
+			return vaultReader, nil
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package secret
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+)
+
+// Secret reference schemes. A value without one of these prefixes is used literally.
+const (
+	FileScheme   = "file://"
+	EnvScheme    = "env://"
+	StdinScheme  = "stdin://"
+	PromptScheme = "prompt://"
+	VaultScheme  = "vault://"
+)
+
+// VaultReader reads a secret from the Vault logical backend
+type VaultReader interface {
+	Read(path string) (*api.Secret, error)
+}
+
+type Resolver struct {
+	mu    sync.Mutex
+	cache map[string]string
+	// stdinConsumer is the name of the secret which has read stdin
+	stdinConsumer string
+	// vaultReader is created once by newVaultReader, which can resolve secrets itself e.g. the vault token
+	vaultReader    VaultReader
+	vaultCreating  bool
+	newVaultReader func() (VaultReader, error)
+
+	stdin     io.Reader
+	stdinFd   int
+	promptOut io.Writer
+}
+
+// DefaultResolver is used by the configuration to resolve secret flags
+var DefaultResolver = NewResolver()
+
+func NewResolver(options ...Option) *Resolver {
+	r := &Resolver{
+		cache:          make(map[string]string),
+		stdin:          os.Stdin,
+		stdinFd:        int(os.Stdin.Fd()),
+		promptOut:      os.Stderr,
+		newVaultReader: newEnvVaultReader,
+	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// Resolve returns the secret value of the reference, the name identifies the secret e.g. the flag name. Resolved values
+// are cached, so prompt:// is asked only once for the same reference. stdin:// can be read by one secret only.
+func Resolve(name, ref string) (string, error) {
+	return DefaultResolver.Resolve(name, ref)
+}
+
+// SetVaultReader sets how the vault client of the vault:// references is created, by default it is configured
+// with the standard VAULT_* env variables
+func SetVaultReader(newVaultReader func() (VaultReader, error)) {
+	DefaultResolver.SetVaultReader(newVaultReader)
+}
+
+// IsReference returns true if the value uses one of the supported reference schemes
+func IsReference(value string) bool {
+	for _, scheme := range []string{FileScheme, EnvScheme, StdinScheme, PromptScheme, VaultScheme} {
+		if strings.HasPrefix(value, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Resolver) SetVaultReader(newVaultReader func() (VaultReader, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.vaultReader = nil
+	r.newVaultReader = newVaultReader
+}
+
+func (r *Resolver) Resolve(name, ref string) (string, error) {
+	if !IsReference(ref) {
+		return ref, nil
+	}
+	if strings.HasPrefix(ref, VaultScheme) {
+		return r.resolveVaultRef(ref)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if strings.HasPrefix(ref, StdinScheme) {
+		if r.stdinConsumer != "" && r.stdinConsumer != name {
+			return "", errors.Errorf("%s cannot be read from stdin, stdin is already read by %s", name, r.stdinConsumer)
+		}
+		r.stdinConsumer = name
+	}
+	if value, ok := r.cache[ref]; ok {
+		return value, nil
+	}
+	value, err := r.resolve(ref)
+	if err != nil {
+		return "", err
+	}
+	r.cache[ref] = value
+	return value, nil
+}
+
+func (r *Resolver) resolve(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, FileScheme):
+		return r.resolveFile(strings.TrimPrefix(ref, FileScheme))
+	case strings.HasPrefix(ref, EnvScheme):
+		return r.resolveEnv(strings.TrimPrefix(ref, EnvScheme))
+	case strings.HasPrefix(ref, StdinScheme):
+		return r.resolveStdin()
+	case strings.HasPrefix(ref, PromptScheme):
+		return r.resolvePrompt(strings.TrimPrefix(ref, PromptScheme))
+	default:
+		return ref, nil
+	}
+}
+
+// resolveVaultRef resolves the vault reference without the lock, the creation of the vault client resolves its own secrets
+func (r *Resolver) resolveVaultRef(ref string) (string, error) {
+	r.mu.Lock()
+	if value, ok := r.cache[ref]; ok {
+		r.mu.Unlock()
+		return value, nil
+	}
+	r.mu.Unlock()
+
+	reader, err := r.getVaultReader()
+	if err != nil {
+		return "", err
+	}
+	value, err := r.resolveVault(reader, strings.TrimPrefix(ref, VaultScheme))
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache[ref] = value
+	return value, nil
+}
+
+func (r *Resolver) getVaultReader() (VaultReader, error) {
+	r.mu.Lock()
+	if r.vaultReader != nil {
+		defer r.mu.Unlock()
+		return r.vaultReader, nil
+	}
+	if r.vaultCreating {
+		r.mu.Unlock()
+		return nil, errors.New("vault secret reference cannot be resolved while the vault client is created, the vault credentials must not be vault references")
+	}
+	r.vaultCreating = true
+	newVaultReader := r.newVaultReader
+	r.mu.Unlock()
+
+	reader, err := newVaultReader()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.vaultCreating = false
+	if err != nil {
+		return nil, err
+	}
+	r.vaultReader = reader
+	return reader, nil
+}
+
+func (r *Resolver) resolveFile(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("secret file path is empty")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "read secret file %s failed", path)
+	}
+	return trimNewline(string(data)), nil
+}
+
+func (r *Resolver) resolveEnv(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("secret env variable name is empty")
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", errors.Errorf("secret env variable %s is not set", name)
+	}
+	return value, nil
+}
+
+func (r *Resolver) resolveStdin() (string, error) {
+	data, err := ioutil.ReadAll(bufio.NewReader(r.stdin))
+	if err != nil {
+		return "", errors.Wrap(err, "read secret from stdin failed")
+	}
+	return trimNewline(string(data)), nil
+}
+
+func (r *Resolver) resolvePrompt(label string) (string, error) {
+	if !term.IsTerminal(r.stdinFd) {
+		return "", errors.New("secret prompt requires a terminal on stdin")
+	}
+	if label == "" {
+		label = "Secret"
+	}
+	_, _ = fmt.Fprintf(r.promptOut, "%s: ", label)
+	data, err := term.ReadPassword(r.stdinFd)
+	_, _ = fmt.Fprintln(r.promptOut)
+	if err != nil {
+		return "", errors.Wrap(err, "read secret from terminal failed")
+	}
+	return string(data), nil
+}
+
+// resolveVault reads kv/path#field. KV version 2 responses are unwrapped, the path must contain the data/ segment e.g. secret/data/tribe#token
+func (r *Resolver) resolveVault(reader VaultReader, pathField string) (string, error) {
+	idx := strings.LastIndex(pathField, "#")
+	if idx <= 0 || idx == len(pathField)-1 {
+		return "", errors.Errorf("vault secret reference must have format vault://path#field, but got %s%s", VaultScheme, pathField)
+	}
+	path, field := pathField[:idx], pathField[idx+1:]
+
+	s, err := reader.Read(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "read vault secret %s failed", path)
+	}
+	if s == nil || s.Data == nil {
+		return "", errors.Errorf("vault secret not found: %s", path)
+	}
+	data := s.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", errors.Errorf("vault secret %s has no field %s", path, field)
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", errors.Errorf("vault secret %s field %s is not a string", path, field)
+	}
+	return str, nil
+}
+
+// newEnvVaultReader creates a vault client configured with the standard VAULT_ADDR, VAULT_TOKEN, VAULT_CACERT, ... env variables
+func newEnvVaultReader() (VaultReader, error) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return nil, errors.Wrap(err, "create vault client failed")
+	}
+	return client.Logical(), nil
+}
+
+func trimNewline(s string) string {
+	return strings.TrimRight(s, "\r\n")
+}